@@ -3,6 +3,8 @@ package clients
 import (
 	"errors"
 	"fmt"
+	"io"
+	"os"
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
 	"github.com/cyberark/conjur-api-go/conjurapi/authn"
@@ -20,17 +22,42 @@ func Login(conjurClient ConjurClient) (ConjurClient, error) {
 		return nil, err
 	}
 
-	return conjurapi.NewClientFromKey(conjurClient.GetConfig(), *authenticatePair)
+	client, err := conjurapi.NewClientFromKey(conjurClient.GetConfig(), *authenticatePair)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHttpClient(conjurClient.GetHttpClient())
+	return client, nil
 }
 
+// isInteractive is overridden in tests
+var isInteractive = prompts.IsInteractive
+
+// errNonInteractiveLogin is returned when credentials are missing and the user can't be prompted for them
+var errNonInteractiveLogin = errors.New(
+	"Unable to prompt for credentials in a non-interactive session. Provide the identity with --id or " +
+		"CONJUR_AUTHN_LOGIN, and the password or API key with --password-stdin, --api-key-file or CONJUR_AUTHN_API_KEY",
+)
+
 // LoginWithPromptFallback attempts to login to Conjur using the username and password provided.
-// If either the username or password is missing then a prompt is presented to interactively request
-// the missing information from the user
+// Missing values are taken from the CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY environment variables.
+// If either the username or password is still missing then a prompt is presented to interactively
+// request the missing information from the user, unless the session is non-interactive.
 func LoginWithPromptFallback(
 	client ConjurClient,
 	username string,
 	password string,
 ) (*authn.LoginPair, error) {
+	if username == "" {
+		username = os.Getenv("CONJUR_AUTHN_LOGIN")
+	}
+	if password == "" {
+		password = os.Getenv("CONJUR_AUTHN_API_KEY")
+	}
+	if (username == "" || password == "") && !isInteractive() {
+		return nil, errNonInteractiveLogin
+	}
+
 	username, password, err := prompts.MaybeAskForCredentials(username, password)
 	if err != nil {
 		return nil, err
@@ -51,8 +78,16 @@ func JWTAuthenticate(conjurClient ConjurClient) error {
 	return err
 }
 
-// oidcLogin attempts to login to Conjur using the OIDC flow
-func oidcLogin(conjurClient ConjurClient, oidcPromptHandler func(string) error) (ConjurClient, error) {
+// OidcManualLogin attempts to login to Conjur using the OIDC flow without opening a browser or starting a
+// local callback server. The user opens the authorization URL and pastes back the URL they're redirected to.
+func OidcManualLogin(conjurClient ConjurClient, out io.Writer) (ConjurClient, error) {
+	return oidcLogin(conjurClient, nil, out)
+}
+
+// oidcLogin attempts to login to Conjur using the OIDC flow. When oidcPromptHandler is nil or fails, the
+// user is asked to paste back the redirect URL instead. The authorization URL and the reason for falling back
+// are written to out.
+func oidcLogin(conjurClient ConjurClient, oidcPromptHandler func(string) error, out io.Writer) (ConjurClient, error) {
 	config := conjurClient.GetConfig()
 
 	oidcProvider, err := getOidcProviderInfo(conjurClient, config.ServiceID)
@@ -60,15 +95,21 @@ func oidcLogin(conjurClient ConjurClient, oidcPromptHandler func(string) error)
 		return nil, err
 	}
 
-	code, err := handleOpenIDFlow(oidcProvider.RedirectURI, generateState, oidcPromptHandler)
+	askForOidcRedirect := func(authURL string) (string, error) {
+		return prompts.AskForOidcRedirect(out, authURL)
+	}
+	code, err := handleOpenIDFlow(oidcProvider.RedirectURI, generateState, oidcPromptHandler, askForOidcRedirect, out)
 	if err != nil {
 		return nil, err
 	}
 
+	httpClient := conjurClient.GetHttpClient()
 	conjurClient, err = conjurapi.NewClientFromOidcCode(config, code, oidcProvider.Nonce, oidcProvider.CodeVerifier)
 	if err != nil {
 		return nil, err
 	}
+	// Keep the proxy, client certificate and pin of the original client
+	conjurClient.SetHttpClient(httpClient)
 
 	// Refreshes the access token and caches it locally
 	err = conjurClient.ForceRefreshToken()
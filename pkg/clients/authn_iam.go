@@ -0,0 +1,131 @@
+package clients
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/cyberark/conjur-api-go/conjurapi/response"
+)
+
+// stsEmptyPayloadHash is the SHA-256 hash of the empty body of the GetCallerIdentity request
+const stsEmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// stsDefaultRegion is the region used to sign the request when none is configured
+const stsDefaultRegion = "us-east-1"
+
+// NewIAMClient creates a client that authenticates with authn-iam. Each authentication signs a new
+// STS GetCallerIdentity request with credentials from the standard AWS credential chain, which covers
+// environment variables, shared config and credentials files, web identity, ECS and EC2 instance roles.
+func NewIAMClient(config conjurapi.Config) (*conjurapi.Client, error) {
+	client, err := conjurapi.NewClient(APIConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	client.SetAuthenticator(&authn.IAMAuthenticator{
+		Authenticate: func() ([]byte, error) {
+			return iamAuthenticate(client)
+		},
+	})
+	return client, nil
+}
+
+// IAMLogin attempts to login to Conjur using authn-iam. The HTTP client of conjurClient is reused, so that
+// the proxy, client certificate and pin it is set up with also apply to the login.
+func IAMLogin(conjurClient ConjurClient) (ConjurClient, error) {
+	client, err := NewIAMClient(conjurClient.GetConfig())
+	if err != nil {
+		return nil, err
+	}
+	client.SetHttpClient(conjurClient.GetHttpClient())
+	if _, err = client.GetAuthenticator().RefreshToken(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+func iamAuthenticate(client *conjurapi.Client) ([]byte, error) {
+	signedHeaders, err := iamSignedHeaders(context.Background())
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := client.IAMAuthenticateRequest(signedHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	return response.DataResponse(res)
+}
+
+// iamSignedHeaders signs an STS GetCallerIdentity request and returns its headers as JSON, which is
+// what authn-iam expects as the request body
+func iamSignedHeaders(ctx context.Context) ([]byte, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load the AWS configuration: %w", err)
+	}
+	if cfg.Region == "" {
+		cfg.Region = stsDefaultRegion
+	}
+
+	credentials, err := cfg.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to obtain AWS credentials: %w", err)
+	}
+
+	req, err := http.NewRequest(
+		http.MethodGet,
+		stsEndpoint(cfg.Region, cfg.BaseEndpoint)+"/?Action=GetCallerIdentity&Version=2011-06-15",
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Host", req.Host)
+
+	// The global endpoint is signed for us-east-1
+	signingRegion := cfg.Region
+	if signingRegion == "global" {
+		signingRegion = stsDefaultRegion
+	}
+	err = v4.NewSigner().SignHTTP(ctx, credentials, req, stsEmptyPayloadHash, "sts", signingRegion, time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("Unable to sign the AWS STS request: %w", err)
+	}
+
+	headers := map[string]string{}
+	for name, values := range req.Header {
+		if len(values) == 1 {
+			headers[name] = values[0]
+		}
+	}
+	return json.Marshal(headers)
+}
+
+// stsEndpoint returns the STS endpoint to sign the request for. Like the AWS SDKs, it can be overridden
+// with AWS_ENDPOINT_URL_STS or AWS_ENDPOINT_URL (passed in as baseEndpoint).
+func stsEndpoint(region string, baseEndpoint *string) string {
+	if endpoint := os.Getenv("AWS_ENDPOINT_URL_STS"); endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/")
+	}
+	if baseEndpoint != nil && *baseEndpoint != "" {
+		return strings.TrimSuffix(*baseEndpoint, "/")
+	}
+	if region == "global" {
+		return "https://sts.amazonaws.com"
+	}
+	return fmt.Sprintf("https://sts.%s.amazonaws.com", region)
+}
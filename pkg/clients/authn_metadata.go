@@ -0,0 +1,166 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+)
+
+const (
+	// azureMetadataURL is the base URL of the Azure Instance Metadata Service
+	azureMetadataURL = "http://169.254.169.254"
+	// gcpMetadataURL is the base URL of the GCP metadata server
+	gcpMetadataURL = "http://metadata.google.internal"
+	// azureTokenResource is the resource the Azure managed identity token is requested for, as expected by authn-azure
+	azureTokenResource = "https://management.azure.com/"
+)
+
+// metadataRequestTimeout bounds requests to the instance metadata service, which is local to the VM
+const metadataRequestTimeout = 10 * time.Second
+
+type azureMetadataJWTSource struct {
+	httpClient  *http.Client
+	metadataURL string
+	clientID    string
+}
+
+func (s azureMetadataJWTSource) JWT() (string, error) {
+	query := url.Values{}
+	query.Set("api-version", "2018-02-01")
+	query.Set("resource", azureTokenResource)
+	if s.clientID != "" {
+		query.Set("client_id", s.clientID)
+	}
+
+	body, err := getMetadata(s.httpClient, s.metadataURL+"/metadata/identity/oauth2/token?"+query.Encode(), "Metadata", "true")
+	if err != nil {
+		return "", err
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if err = json.Unmarshal(body, &token); err != nil {
+		return "", fmt.Errorf("unable to parse the response of the Azure Instance Metadata Service: %w", err)
+	}
+	if token.AccessToken == "" {
+		return "", fmt.Errorf("the Azure Instance Metadata Service did not return an access token")
+	}
+	return token.AccessToken, nil
+}
+
+type gcpMetadataJWTSource struct {
+	httpClient  *http.Client
+	metadataURL string
+	audience    string
+}
+
+func (s gcpMetadataJWTSource) JWT() (string, error) {
+	query := url.Values{}
+	query.Set("audience", s.audience)
+	query.Set("format", "full")
+
+	body, err := getMetadata(
+		s.httpClient,
+		s.metadataURL+"/computeMetadata/v1/instance/service-accounts/default/identity?"+query.Encode(),
+		"Metadata-Flavor", "Google",
+	)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+func getMetadata(httpClient *http.Client, metadataURL string, header string, value string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, metadataURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set(header, value)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("unable to reach the instance metadata service: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from the instance metadata service: %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
+
+// NewInstanceMetadataClient creates a client that authenticates with authn-azure or authn-gcp, depending
+// on the authn type. The identity token is fetched from the instance metadata service of the VM, whose
+// URL can be overridden with the metadata_url setting.
+func NewInstanceMetadataClient(config conjurapi.Config, cliConfig CLIConfig) (*conjurapi.Client, error) {
+	client, err := conjurapi.NewClient(APIConfig(config))
+	if err != nil {
+		return nil, err
+	}
+
+	// The metadata service is link-local, so it must never be reached through a proxy
+	httpClient := &http.Client{
+		Timeout:   metadataRequestTimeout,
+		Transport: &http.Transport{Proxy: nil},
+	}
+	metadataURL := strings.TrimSuffix(cliConfig.MetadataURL, "/")
+
+	var authenticator *jwtSourceAuthenticator
+	switch config.AuthnType {
+	case "azure":
+		if metadataURL == "" {
+			metadataURL = azureMetadataURL
+		}
+		authenticator = &jwtSourceAuthenticator{
+			source: azureMetadataJWTSource{
+				httpClient:  httpClient,
+				metadataURL: metadataURL,
+				clientID:    config.AzureClientID,
+			},
+			authenticate: client.AzureAuthenticate,
+		}
+	case "gcp":
+		if metadataURL == "" {
+			metadataURL = gcpMetadataURL
+		}
+		authenticator = &jwtSourceAuthenticator{
+			source: gcpMetadataJWTSource{
+				httpClient:  httpClient,
+				metadataURL: metadataURL,
+				audience:    fmt.Sprintf("conjur/%s/host/%s", config.Account, strings.TrimPrefix(config.JWTHostID, "host/")),
+			},
+			authenticate: client.GCPAuthenticate,
+		}
+	default:
+		return nil, fmt.Errorf("authentication type %q does not use instance metadata", config.AuthnType)
+	}
+
+	authenticator.source = newCachedJWTSource(authenticator.source)
+	client.SetAuthenticator(authenticator)
+	return client, nil
+}
+
+// InstanceMetadataLogin attempts to login to Conjur using authn-azure or authn-gcp. Like IAMLogin, the HTTP
+// client of conjurClient is reused for the requests to Conjur.
+func InstanceMetadataLogin(conjurClient ConjurClient, cliConfig CLIConfig) (ConjurClient, error) {
+	client, err := NewInstanceMetadataClient(conjurClient.GetConfig(), cliConfig)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHttpClient(conjurClient.GetHttpClient())
+	if _, err = client.GetAuthenticator().RefreshToken(); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
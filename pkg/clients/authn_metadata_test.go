@@ -0,0 +1,111 @@
+package clients
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// newStubMetadataConjur starts a stand-in for Conjur that accepts the given identity token at the
+// given authenticate path
+func newStubMetadataConjur(t *testing.T, path string, token string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.EscapedPath() != path {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("jwt") != token {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("conjur-access-token"))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestInstanceMetadataLogin(t *testing.T) {
+	t.Run("authenticates with an Azure managed identity token", func(t *testing.T) {
+		imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/metadata/identity/oauth2/token", r.URL.Path)
+			assert.Equal(t, "true", r.Header.Get("Metadata"))
+			assert.Equal(t, "https://management.azure.com/", r.URL.Query().Get("resource"))
+			assert.Equal(t, "client-id", r.URL.Query().Get("client_id"))
+			w.Write([]byte(`{"access_token":"azure-token","token_type":"Bearer"}`))
+		}))
+		defer imds.Close()
+		conjur := newStubMetadataConjur(t, "/authn-azure/prod/test-account/host%2Fapp/authenticate", "azure-token")
+
+		client, err := conjurapi.NewClient(conjurapi.Config{
+			Account:       "test-account",
+			ApplianceURL:  conjur.URL,
+			AuthnType:     "azure",
+			ServiceID:     "prod",
+			JWTHostID:     "app",
+			AzureClientID: "client-id",
+		})
+		assert.NoError(t, err)
+
+		metadataClient, err := InstanceMetadataLogin(client, CLIConfig{MetadataURL: imds.URL + "/"})
+		assert.NoError(t, err)
+
+		token, err := metadataClient.GetAuthenticator().RefreshToken()
+		assert.NoError(t, err)
+		assert.Equal(t, "conjur-access-token", string(token))
+	})
+
+	t.Run("authenticates with a GCP identity token", func(t *testing.T) {
+		metadataServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/computeMetadata/v1/instance/service-accounts/default/identity", r.URL.Path)
+			assert.Equal(t, "Google", r.Header.Get("Metadata-Flavor"))
+			assert.Equal(t, "conjur/test-account/host/gcp-apps/app", r.URL.Query().Get("audience"))
+			assert.Equal(t, "full", r.URL.Query().Get("format"))
+			w.Write([]byte("gcp-token\n"))
+		}))
+		defer metadataServer.Close()
+		conjur := newStubMetadataConjur(t, "/authn-gcp/test-account/authenticate", "gcp-token")
+
+		client, err := conjurapi.NewClient(conjurapi.Config{
+			Account:      "test-account",
+			ApplianceURL: conjur.URL,
+			AuthnType:    "gcp",
+			JWTHostID:    "host/gcp-apps/app",
+		})
+		assert.NoError(t, err)
+
+		_, err = InstanceMetadataLogin(client, CLIConfig{MetadataURL: metadataServer.URL})
+		assert.NoError(t, err)
+	})
+
+	t.Run("returns an error from the metadata service", func(t *testing.T) {
+		imds := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid_request","error_description":"Identity not found"}`))
+		}))
+		defer imds.Close()
+
+		client, err := conjurapi.NewClient(conjurapi.Config{
+			Account:      "test-account",
+			ApplianceURL: "http://127.0.0.1:0",
+			AuthnType:    "azure",
+			ServiceID:    "prod",
+			JWTHostID:    "app",
+		})
+		assert.NoError(t, err)
+
+		_, err = InstanceMetadataLogin(client, CLIConfig{MetadataURL: imds.URL})
+		assert.EqualError(t, err, "Failed to obtain JWT: unexpected response from the instance metadata service: "+
+			`400 Bad Request: {"error":"invalid_request","error_description":"Identity not found"}`)
+	})
+
+	t.Run("returns an error for other authn types", func(t *testing.T) {
+		_, err := NewInstanceMetadataClient(conjurapi.Config{
+			Account:      "test-account",
+			ApplianceURL: "http://127.0.0.1:0",
+		}, CLIConfig{})
+		assert.EqualError(t, err, `authentication type "" does not use instance metadata`)
+	})
+}
@@ -3,8 +3,10 @@
 
 package clients
 
+import "io"
+
 // OidcLogin attempts to login to Conjur using the OIDC flow. Username and password are ignored - they are
-// only used for testing (see the dev build tag - authn_oidc_dev.go)
-func OidcLogin(conjurClient ConjurClient, username string, password string) (ConjurClient, error) {
-	return oidcLogin(conjurClient, openBrowser)
+// only used for testing (see the dev build tag - authn_oidc_dev.go). Fallback notices are written to out.
+func OidcLogin(conjurClient ConjurClient, username string, password string, out io.Writer) (ConjurClient, error) {
+	return oidcLogin(conjurClient, openBrowser, out)
 }
@@ -25,7 +25,7 @@ import (
 // OidcLogin attempts to login to Conjur using the OIDC flow. Username and password can be provided to
 // bypass the browser and use the username and password to fetch an OIDC code. This option is meant for testing
 // purposes only and will print a warning.
-func OidcLogin(conjurClient ConjurClient, username string, password string) (ConjurClient, error) {
+func OidcLogin(conjurClient ConjurClient, username string, password string, out io.Writer) (ConjurClient, error) {
 	username, password, err := prompts.MaybeAskForCredentials(username, password)
 	if err != nil {
 		return nil, err
@@ -39,7 +39,7 @@ func OidcLogin(conjurClient ConjurClient, username string, password string) (Con
 		oidcPromptHandler = fetchOidcCodeFromProvider(username, password)
 	}
 
-	return oidcLogin(conjurClient, oidcPromptHandler)
+	return oidcLogin(conjurClient, oidcPromptHandler, out)
 }
 
 // fetchOidcCodeFromProvider attempts to bypass the browser by using the username and password to fetch
@@ -0,0 +1,303 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients/qr"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// deviceCodeDefaultInterval is the polling interval used when the provider doesn't specify one.
+// It's also the amount the interval grows by when the provider asks to slow down (RFC 8628 section 3.5).
+var deviceCodeDefaultInterval = 5 * time.Second
+
+// deviceCodeDefaultExpiry is the lifetime of the device code when the provider doesn't specify one
+var deviceCodeDefaultExpiry = 10 * time.Minute
+
+// deviceAuthorization is the response of an OIDC provider to a device authorization request
+type deviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type oidcProviderMetadata struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+}
+
+type oidcTokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OidcDeviceLogin attempts to login to Conjur using the OAuth 2.0 device authorization grant. Unlike
+// OidcLogin it doesn't need a browser on the local machine, so it works over SSH and in remote containers.
+// The issuer is used to discover the provider's endpoints. When empty, it's derived from the
+// authorization endpoint of the Conjur OIDC authenticator. The verification URI and code are written to
+// out, which is stderr so that they don't mix with the output of the command.
+func OidcDeviceLogin(conjurClient ConjurClient, cliConfig CLIConfig, issuer string, out io.Writer) (ConjurClient, error) {
+	httpClient, err := oidcProviderHTTPClient(conjurClient.GetConfig(), cliConfig)
+	if err != nil {
+		return nil, err
+	}
+	return oidcDeviceLogin(conjurClient, httpClient, issuer, displayDeviceAuthorization(out))
+}
+
+// oidcProviderHTTPClient returns the client used for the requests to the OIDC provider. Like the Conjur
+// client, it trusts cert_file, goes through the proxy (identity_proxy when set) and presents the client
+// certificate, but it isn't pinned to the appliance.
+func oidcProviderHTTPClient(config conjurapi.Config, cliConfig CLIConfig) (*http.Client, error) {
+	client, err := conjurapi.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if err = UseProxyForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	if err = UseClientCertificateForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	return client.GetHttpClient(), nil
+}
+
+func oidcDeviceLogin(
+	conjurClient ConjurClient,
+	httpClient *http.Client,
+	issuer string,
+	promptHandler func(deviceAuthorization) error,
+) (ConjurClient, error) {
+	config := conjurClient.GetConfig()
+
+	oidcProvider, err := getOidcProviderInfo(conjurClient, config.ServiceID)
+	if err != nil {
+		return nil, err
+	}
+
+	idToken, err := fetchOidcDeviceToken(httpClient, oidcProvider.RedirectURI, issuer, promptHandler)
+	if err != nil {
+		return nil, err
+	}
+
+	conjurHttpClient := conjurClient.GetHttpClient()
+	conjurClient, err = conjurapi.NewClientFromOidcToken(config, idToken)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the proxy, client certificate and pin of the original client
+	conjurClient.SetHttpClient(conjurHttpClient)
+
+	// Refreshes the access token and caches it locally
+	err = conjurClient.ForceRefreshToken()
+	if err != nil {
+		return nil, errors.New(
+			"You have successfully authenticated with OIDC, but your access was denied by Secrets Manager. " +
+				"Please verify your authenticator configuration in Secrets Manager or contact your administrator " +
+				"for assistance.",
+		)
+	}
+
+	return conjurClient, nil
+}
+
+// fetchOidcDeviceToken runs the device authorization grant against the provider behind the given
+// authorization URL and returns the ID token issued once the user approves the request
+func fetchOidcDeviceToken(
+	httpClient *http.Client,
+	authorizationURL string,
+	issuer string,
+	promptHandler func(deviceAuthorization) error,
+) (string, error) {
+	authURL, err := url.Parse(authorizationURL)
+	if err != nil {
+		return "", err
+	}
+	clientID := authURL.Query().Get("client_id")
+	if clientID == "" {
+		return "", errors.New("OIDC authorization URL does not contain a client_id")
+	}
+	scope := authURL.Query().Get("scope")
+	if scope == "" {
+		scope = "openid"
+	}
+
+	metadata, err := discoverOidcProvider(httpClient, authURL, issuer)
+	if err != nil {
+		return "", err
+	}
+	if metadata.DeviceAuthorizationEndpoint == "" {
+		return "", fmt.Errorf("OIDC provider %s does not support the device authorization grant", metadata.Issuer)
+	}
+
+	var authorization deviceAuthorization
+	err = postOidcForm(httpClient, metadata.DeviceAuthorizationEndpoint, url.Values{
+		"client_id": {clientID},
+		"scope":     {scope},
+	}, &authorization)
+	if err != nil {
+		return "", fmt.Errorf("Unable to start the device authorization: %w", err)
+	}
+	if authorization.DeviceCode == "" || authorization.UserCode == "" || authorization.VerificationURI == "" {
+		return "", errors.New("Unable to start the device authorization: incomplete response from the OIDC provider")
+	}
+
+	if err = promptHandler(authorization); err != nil {
+		return "", err
+	}
+
+	return pollOidcDeviceToken(httpClient, metadata.TokenEndpoint, clientID, authorization)
+}
+
+// discoverOidcProvider fetches the provider metadata. Without an explicit issuer, each parent path of
+// the authorization endpoint is tried in turn and the first document that advertises the same
+// authorization endpoint is used.
+func discoverOidcProvider(httpClient *http.Client, authURL *url.URL, issuer string) (*oidcProviderMetadata, error) {
+	if issuer != "" {
+		return fetchOidcProviderMetadata(httpClient, issuer)
+	}
+
+	authEndpoint := authURL.Scheme + "://" + authURL.Host + authURL.Path
+	segments := strings.Split(strings.Trim(authURL.Path, "/"), "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		candidate := authURL.Scheme + "://" + authURL.Host
+		if i > 0 {
+			candidate += "/" + strings.Join(segments[:i], "/")
+		}
+		metadata, err := fetchOidcProviderMetadata(httpClient, candidate)
+		if err == nil && metadata.AuthorizationEndpoint == authEndpoint {
+			return metadata, nil
+		}
+	}
+
+	return nil, fmt.Errorf(
+		"Unable to discover the OIDC provider configuration from %s. Please provide the issuer URL with --oidc-issuer",
+		authEndpoint,
+	)
+}
+
+func fetchOidcProviderMetadata(httpClient *http.Client, issuer string) (*oidcProviderMetadata, error) {
+	resp, err := httpClient.Get(strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from the OIDC discovery endpoint: %s", resp.Status)
+	}
+
+	var metadata oidcProviderMetadata
+	if err = json.NewDecoder(resp.Body).Decode(&metadata); err != nil {
+		return nil, err
+	}
+	if metadata.TokenEndpoint == "" {
+		return nil, errors.New("OIDC provider configuration does not contain a token endpoint")
+	}
+	return &metadata, nil
+}
+
+func pollOidcDeviceToken(httpClient *http.Client, tokenEndpoint string, clientID string, authorization deviceAuthorization) (string, error) {
+	interval := deviceCodeDefaultInterval
+	if authorization.Interval > 0 {
+		interval = time.Duration(authorization.Interval) * time.Second
+	}
+	expiry := deviceCodeDefaultExpiry
+	if authorization.ExpiresIn > 0 {
+		expiry = time.Duration(authorization.ExpiresIn) * time.Second
+	}
+	deadline := time.Now().Add(expiry)
+
+	for time.Now().Before(deadline) {
+		time.Sleep(interval)
+
+		var token oidcTokenResponse
+		err := postOidcForm(httpClient, tokenEndpoint, url.Values{
+			"grant_type":  {deviceCodeGrantType},
+			"device_code": {authorization.DeviceCode},
+			"client_id":   {clientID},
+		}, &token)
+		if err != nil && token.Error == "" {
+			return "", err
+		}
+
+		switch token.Error {
+		case "":
+			if token.IDToken == "" {
+				return "", errors.New("OIDC provider did not return an ID token. Please make sure the 'openid' scope is configured")
+			}
+			return token.IDToken, nil
+		case "authorization_pending":
+		case "slow_down":
+			interval += deviceCodeDefaultInterval
+		case "access_denied":
+			return "", errors.New("The device authorization request was denied")
+		case "expired_token":
+			return "", errors.New("The device code has expired. Please login again")
+		default:
+			if token.ErrorDescription != "" {
+				return "", fmt.Errorf("OIDC provider returned an error: %s: %s", token.Error, token.ErrorDescription)
+			}
+			return "", fmt.Errorf("OIDC provider returned an error: %s", token.Error)
+		}
+	}
+
+	return "", errors.New("The device code has expired. Please login again")
+}
+
+// postOidcForm posts a form to an OIDC provider endpoint and decodes the JSON response into v. Error
+// responses are decoded as well so that OAuth error codes can be inspected by the caller.
+func postOidcForm(httpClient *http.Client, endpoint string, form url.Values, v interface{}) error {
+	resp, err := httpClient.PostForm(endpoint, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if decodeErr := json.Unmarshal(body, v); decodeErr != nil {
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("unexpected response from %s: %s", endpoint, resp.Status)
+		}
+		return decodeErr
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected response from %s: %s", endpoint, resp.Status)
+	}
+	return nil
+}
+
+// displayDeviceAuthorization returns a function showing the user, on out, where and how to approve
+// the device authorization request
+func displayDeviceAuthorization(out io.Writer) func(authorization deviceAuthorization) error {
+	return func(authorization deviceAuthorization) error {
+		fmt.Fprintf(out, "To login, open %s and enter the code %s\n", authorization.VerificationURI, authorization.UserCode)
+
+		qrContent := authorization.VerificationURIComplete
+		if qrContent == "" {
+			qrContent = authorization.VerificationURI
+		}
+		fmt.Fprintln(out, "Or scan the QR code below with your mobile device:")
+		if err := qr.DisplayQRCodeForText(out, qrContent); err != nil {
+			return err
+		}
+		fmt.Fprintln(out, "Waiting for the request to be approved...")
+		return nil
+	}
+}
@@ -0,0 +1,248 @@
+package clients
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/stretchr/testify/assert"
+)
+
+type stubIdP struct {
+	server *httptest.Server
+	// url is the base URL of the endpoints the provider advertises, which is the URL of the server by default
+	url             string
+	deviceEndpoint  bool
+	pendingPolls    int
+	tokenError      string
+	polls           int
+	deviceCodeForms []map[string]string
+}
+
+// newStubIdP starts a minimal OIDC provider, with its issuer at /realms/dev as in Keycloak
+func newStubIdP(t *testing.T) *stubIdP {
+	idp := &stubIdP{deviceEndpoint: true}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/realms/dev/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		metadata := map[string]string{
+			"issuer":                 idp.url + "/realms/dev",
+			"authorization_endpoint": idp.url + "/realms/dev/protocol/openid-connect/auth",
+			"token_endpoint":         idp.url + "/realms/dev/protocol/openid-connect/token",
+		}
+		if idp.deviceEndpoint {
+			metadata["device_authorization_endpoint"] = idp.url + "/realms/dev/protocol/openid-connect/auth/device"
+		}
+		json.NewEncoder(w).Encode(metadata)
+	})
+	mux.HandleFunc("/realms/dev/protocol/openid-connect/auth/device", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		idp.deviceCodeForms = append(idp.deviceCodeForms, map[string]string{
+			"client_id": r.PostForm.Get("client_id"),
+			"scope":     r.PostForm.Get("scope"),
+		})
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"device_code":               "device-code",
+			"user_code":                 "ABCD-EFGH",
+			"verification_uri":          idp.url + "/device",
+			"verification_uri_complete": idp.url + "/device?user_code=ABCD-EFGH",
+			"expires_in":                60,
+		})
+	})
+	mux.HandleFunc("/realms/dev/protocol/openid-connect/token", func(w http.ResponseWriter, r *http.Request) {
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, deviceCodeGrantType, r.PostForm.Get("grant_type"))
+		assert.Equal(t, "device-code", r.PostForm.Get("device_code"))
+		assert.Equal(t, "conjur-cli", r.PostForm.Get("client_id"))
+
+		idp.polls++
+		switch {
+		case idp.polls <= idp.pendingPolls:
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "authorization_pending"})
+		case idp.tokenError != "":
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": idp.tokenError})
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"id_token": "id-token", "access_token": "access-token"})
+		}
+	})
+	idp.server = httptest.NewServer(mux)
+	idp.url = idp.server.URL
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *stubIdP) authorizationURL() string {
+	return idp.url + "/realms/dev/protocol/openid-connect/auth?client_id=conjur-cli&scope=openid+profile" +
+		"&response_type=code&redirect_uri=http%3A%2F%2F127.0.0.1%3A8888%2Fcallback"
+}
+
+func TestFetchOidcDeviceToken(t *testing.T) {
+	originalInterval := deviceCodeDefaultInterval
+	deviceCodeDefaultInterval = time.Millisecond
+	defer func() { deviceCodeDefaultInterval = originalInterval }()
+
+	t.Run("returns the ID token once the request is approved", func(t *testing.T) {
+		idp := newStubIdP(t)
+		idp.pendingPolls = 2
+
+		var prompted deviceAuthorization
+		token, err := fetchOidcDeviceToken(idp.server.Client(), idp.authorizationURL(), "", func(authorization deviceAuthorization) error {
+			prompted = authorization
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "id-token", token)
+		assert.Equal(t, 3, idp.polls)
+		assert.Equal(t, "ABCD-EFGH", prompted.UserCode)
+		assert.Equal(t, idp.server.URL+"/device", prompted.VerificationURI)
+		assert.Equal(t, []map[string]string{{"client_id": "conjur-cli", "scope": "openid profile"}}, idp.deviceCodeForms)
+	})
+
+	t.Run("uses the provided issuer", func(t *testing.T) {
+		idp := newStubIdP(t)
+
+		token, err := fetchOidcDeviceToken(idp.server.Client(), idp.server.URL+"/other/auth?client_id=conjur-cli", idp.server.URL+"/realms/dev/", func(deviceAuthorization) error {
+			return nil
+		})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "id-token", token)
+	})
+
+	t.Run("returns an error when the request is denied", func(t *testing.T) {
+		idp := newStubIdP(t)
+		idp.tokenError = "access_denied"
+
+		_, err := fetchOidcDeviceToken(idp.server.Client(), idp.authorizationURL(), "", func(deviceAuthorization) error {
+			return nil
+		})
+
+		assert.EqualError(t, err, "The device authorization request was denied")
+	})
+
+	t.Run("returns an error when the device code expires", func(t *testing.T) {
+		idp := newStubIdP(t)
+		idp.tokenError = "expired_token"
+
+		_, err := fetchOidcDeviceToken(idp.server.Client(), idp.authorizationURL(), "", func(deviceAuthorization) error {
+			return nil
+		})
+
+		assert.EqualError(t, err, "The device code has expired. Please login again")
+	})
+
+	t.Run("returns an error when the provider doesn't support the device grant", func(t *testing.T) {
+		idp := newStubIdP(t)
+		idp.deviceEndpoint = false
+
+		_, err := fetchOidcDeviceToken(idp.server.Client(), idp.authorizationURL(), "", func(deviceAuthorization) error {
+			t.Error("the user should not be prompted")
+			return nil
+		})
+
+		assert.EqualError(t, err, "OIDC provider "+idp.server.URL+"/realms/dev does not support the device authorization grant")
+	})
+
+	t.Run("returns an error when the provider can't be discovered", func(t *testing.T) {
+		idp := newStubIdP(t)
+
+		_, err := fetchOidcDeviceToken(idp.server.Client(), idp.server.URL+"/unknown/auth?client_id=conjur-cli", "", func(deviceAuthorization) error {
+			return nil
+		})
+
+		assert.EqualError(t, err, "Unable to discover the OIDC provider configuration from "+idp.server.URL+
+			"/unknown/auth. Please provide the issuer URL with --oidc-issuer")
+	})
+
+	t.Run("returns an error when the client ID is missing", func(t *testing.T) {
+		_, err := fetchOidcDeviceToken(http.DefaultClient, "https://idp.example.com/auth", "", func(deviceAuthorization) error {
+			return nil
+		})
+
+		assert.EqualError(t, err, "OIDC authorization URL does not contain a client_id")
+	})
+}
+
+func TestOidcProviderHTTPClient(t *testing.T) {
+	originalInterval := deviceCodeDefaultInterval
+	deviceCodeDefaultInterval = time.Millisecond
+	defer func() { deviceCodeDefaultInterval = originalInterval }()
+	t.Setenv("NO_PROXY", "")
+	t.Setenv("no_proxy", "")
+
+	// The provider advertises a host that only the proxy can reach
+	idp := newStubIdP(t)
+	idp.url = "http://idp.example.com"
+	idpURL, _ := url.Parse(idp.server.URL)
+
+	var proxied []string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = append(proxied, r.Method+" "+r.URL.String())
+		r.URL.Host = idpURL.Host
+		r.RequestURI = ""
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer proxy.Close()
+
+	testCases := []struct {
+		name      string
+		config    conjurapi.Config
+		cliConfig CLIConfig
+	}{
+		{
+			name:   "goes through the proxy",
+			config: conjurapi.Config{Account: "conjur", ApplianceURL: "http://conjur.example.com", Proxy: proxy.URL},
+		},
+		{
+			name:      "goes through identity_proxy",
+			config:    conjurapi.Config{Account: "conjur", ApplianceURL: "http://conjur.example.com", Proxy: "http://conjur-egress.invalid:3128"},
+			cliConfig: CLIConfig{IdentityProxy: proxy.URL},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			proxied = nil
+			httpClient, err := oidcProviderHTTPClient(tc.config, tc.cliConfig)
+			assert.NoError(t, err)
+
+			token, err := fetchOidcDeviceToken(httpClient, idp.authorizationURL(), "", func(deviceAuthorization) error {
+				return nil
+			})
+
+			assert.NoError(t, err)
+			assert.Equal(t, "id-token", token)
+			assert.Contains(t, proxied, "GET http://idp.example.com/realms/dev/.well-known/openid-configuration")
+			assert.Contains(t, proxied, "POST http://idp.example.com/realms/dev/protocol/openid-connect/auth/device")
+			assert.Contains(t, proxied, "POST http://idp.example.com/realms/dev/protocol/openid-connect/token")
+		})
+	}
+}
+
+func TestDisplayDeviceAuthorization(t *testing.T) {
+	out := &bytes.Buffer{}
+	err := displayDeviceAuthorization(out)(deviceAuthorization{
+		UserCode:        "ABCD-EFGH",
+		VerificationURI: "https://idp.example.com/device",
+	})
+
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(out.String(), "To login, open https://idp.example.com/device and enter the code ABCD-EFGH\n"))
+	assert.Contains(t, out.String(), "Or scan the QR code below with your mobile device:\n")
+	assert.True(t, strings.HasSuffix(out.String(), "Waiting for the request to be approved...\n"))
+}
@@ -1,8 +1,15 @@
 package clients
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"testing"
+	"time"
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
 	"github.com/stretchr/testify/assert"
@@ -72,3 +79,97 @@ func TestGetOidcProviderInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestLoginWithPromptFallback(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || r.URL.Path != "/authn/dev/login" || username != "alice" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("api-key"))
+	}))
+	defer server.Close()
+
+	client, err := conjurapi.NewClient(conjurapi.Config{Account: "dev", ApplianceURL: server.URL})
+	assert.NoError(t, err)
+
+	originalIsInteractive := isInteractive
+	isInteractive = func() bool { return false }
+	defer func() { isInteractive = originalIsInteractive }()
+
+	t.Run("uses the credentials from the environment", func(t *testing.T) {
+		t.Setenv("CONJUR_AUTHN_LOGIN", "alice")
+		t.Setenv("CONJUR_AUTHN_API_KEY", "secret")
+
+		pair, err := LoginWithPromptFallback(client, "", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", pair.Login)
+		assert.Equal(t, "api-key", pair.APIKey)
+	})
+
+	t.Run("prefers the provided credentials", func(t *testing.T) {
+		t.Setenv("CONJUR_AUTHN_LOGIN", "bob")
+		t.Setenv("CONJUR_AUTHN_API_KEY", "other")
+
+		pair, err := LoginWithPromptFallback(client, "alice", "secret")
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", pair.Login)
+	})
+
+	t.Run("doesn't prompt in a non-interactive session", func(t *testing.T) {
+		t.Setenv("CONJUR_AUTHN_LOGIN", "")
+		t.Setenv("CONJUR_AUTHN_API_KEY", "")
+
+		_, err := LoginWithPromptFallback(client, "alice", "")
+		assert.Equal(t, errNonInteractiveLogin, err)
+	})
+}
+
+func TestOidcLogin(t *testing.T) {
+	redirectURI := fmt.Sprintf("http://127.0.0.1:%d/callback", randomPort())
+	_, config := newMutualTLSServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/authn-oidc/test-account/providers":
+			json.NewEncoder(w).Encode([]conjurapi.OidcProvider{{
+				ServiceID:    "okta",
+				RedirectURI:  "https://idp.example.com/authorize?redirect_uri=" + url.QueryEscape(redirectURI),
+				Nonce:        "nonce",
+				CodeVerifier: "verifier",
+			}})
+		case "/authn-oidc/okta/test-account/authenticate":
+			if r.URL.Query().Get("code") != "1234" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write(testAccessToken("alice", time.Now()))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	config.AuthnType, config.ServiceID = "oidc", "okta"
+
+	// Stands in for the browser, which is redirected to the local callback server after logging in
+	openBrowser := func(authURL string) error {
+		parsed, err := url.Parse(authURL)
+		if err != nil {
+			return err
+		}
+		go httpClient.Get(redirectURI + "?code=1234&state=" + url.QueryEscape(parsed.Query().Get("state")))
+		return nil
+	}
+
+	t.Run("authenticates with the client certificate of the client", func(t *testing.T) {
+		client, err := oidcLogin(newMutualTLSClient(t, config), openBrowser, io.Discard)
+		assert.NoError(t, err)
+		assert.NotNil(t, client)
+	})
+
+	t.Run("fails without the client certificate", func(t *testing.T) {
+		client, err := conjurapi.NewClient(config)
+		assert.NoError(t, err)
+
+		_, err = oidcLogin(client, openBrowser, io.Discard)
+		assert.ErrorContains(t, err, "certificate required")
+	})
+}
@@ -0,0 +1,57 @@
+package clients
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+)
+
+// DefaultCertExpiryWarningDays is how many days before the stored server certificate expires
+// commands start warning about it, unless cert_expiry_warning_days is set
+const DefaultCertExpiryWarningDays = 30
+
+// CertExpiryWarning returns a warning when a certificate stored for the server expires within the
+// warning period, or an empty string. A negative cert_expiry_warning_days disables the warning.
+func CertExpiryWarning(config conjurapi.Config, cliConfig CLIConfig, now time.Time) string {
+	days := cliConfig.CertExpiryWarningDays
+	if days == 0 {
+		days = DefaultCertExpiryWarningDays
+	}
+	if days < 0 || !config.IsHttps() {
+		return ""
+	}
+
+	data, err := config.ReadSSLCert()
+	if err != nil {
+		// Unreadable certificates are reported when the client is created
+		return ""
+	}
+	certs, err := utils.ParseCertificates(data)
+	if err != nil {
+		return ""
+	}
+
+	// The certificates of SaaS include the one of Identity, the first one to expire matters
+	notAfter := certs[0].NotAfter
+	for _, cert := range certs[1:] {
+		if cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+
+	source := "the CONJUR_SSL_CERTIFICATE environment variable"
+	if config.SSLCertPath != "" {
+		source = config.SSLCertPath
+	}
+	remaining := notAfter.Sub(now)
+	switch {
+	case remaining <= 0:
+		return fmt.Sprintf("Warning: The server certificate stored in %s expired on %s. Run 'conjur cert refresh' to trust the server's new certificate.", source, notAfter.Format(time.RFC1123))
+	case remaining <= time.Duration(days)*24*time.Hour:
+		return fmt.Sprintf("Warning: The server certificate stored in %s expires on %s, in %d days. Run 'conjur cert refresh' once the server's certificate has been renewed.", source, notAfter.Format(time.RFC1123), int(remaining.Hours()/24))
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func testCertExpiring(t *testing.T, notAfter time.Time) string {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := x509.Certificate{SerialNumber: big.NewInt(1), NotBefore: notAfter.Add(-24 * time.Hour), NotAfter: notAfter}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	assert.NoError(t, err)
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestCertExpiryWarning(t *testing.T) {
+	now := time.Now()
+	later := testCertExpiring(t, now.Add(90*24*time.Hour))
+	soon := testCertExpiring(t, now.Add(20*24*time.Hour+time.Hour))
+	expired := testCertExpiring(t, now.Add(-time.Hour))
+
+	assert.Empty(t, CertExpiryWarning(conjurapi.Config{}, CLIConfig{}, now))
+	assert.Empty(t, CertExpiryWarning(conjurapi.Config{SSLCert: later}, CLIConfig{}, now))
+
+	warning := CertExpiryWarning(conjurapi.Config{SSLCert: soon}, CLIConfig{}, now)
+	assert.Contains(t, warning, "Warning: The server certificate stored in the CONJUR_SSL_CERTIFICATE environment variable expires on")
+	assert.Contains(t, warning, "in 20 days")
+	assert.Empty(t, CertExpiryWarning(conjurapi.Config{SSLCert: soon}, CLIConfig{CertExpiryWarningDays: 10}, now))
+	assert.Empty(t, CertExpiryWarning(conjurapi.Config{SSLCert: soon}, CLIConfig{CertExpiryWarningDays: -1}, now))
+
+	// The first certificate of a chain to expire matters
+	warning = CertExpiryWarning(conjurapi.Config{SSLCert: later + expired}, CLIConfig{}, now)
+	assert.Contains(t, warning, "expired on")
+}
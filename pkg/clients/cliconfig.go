@@ -0,0 +1,215 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"gopkg.in/yaml.v3"
+)
+
+// CLIConfig contains the settings that are only used by the CLI. They are stored in .conjurrc next
+// to the settings of conjurapi.Config, which ignores the keys it doesn't know about.
+type CLIConfig struct {
+	// JWTSource is where to obtain the JWT for authn-jwt from, see ParseJWTSource
+	JWTSource string `yaml:"jwt_source,omitempty"`
+	// MetadataURL overrides the base URL of the instance metadata service used by authn-azure and authn-gcp
+	MetadataURL string `yaml:"metadata_url,omitempty"`
+	// CredentialsFile is the path of the encrypted credentials file, see CredentialsFilePath
+	CredentialsFile string `yaml:"credentials_file,omitempty"`
+	// Identities lists the identities whose credentials are cached, see SaveActiveIdentity
+	Identities []string `yaml:"identities,omitempty"`
+	// Redact adds redaction rules to the HTTP logs of --debug and --http-log, see Redactor
+	Redact RedactConfig `yaml:"redact,omitempty"`
+	// CertFingerprint is the SHA-256 fingerprint the certificate of the server must have, set with
+	// init --cert-fingerprint
+	CertFingerprint string `yaml:"cert_fingerprint,omitempty"`
+	// CertExpiryWarningDays is how many days before the stored server certificate expires commands
+	// start warning about it, see CertExpiryWarning
+	CertExpiryWarningDays int `yaml:"cert_expiry_warning_days,omitempty"`
+	// ClientCertBundle is the PKCS#12 bundle holding the client certificate for mutual TLS, as an
+	// alternative to client_cert_file and client_cert_key_file, see ClientCertificate
+	ClientCertBundle string `yaml:"client_cert_bundle,omitempty"`
+	// IdentityProxy is the proxy for the Identity tenant of Secrets Manager SaaS, when it differs
+	// from the proxy of Conjur, see IdentityProxy
+	IdentityProxy string `yaml:"identity_proxy,omitempty"`
+	// FollowerURLs are the URLs of the followers serving the read-only operations instead of the
+	// appliance URL, see UseFollowersForClient
+	FollowerURLs []string `yaml:"follower_urls,omitempty"`
+}
+
+// RedactConfig lists what to redact from every logged request and response, on top of the
+// built-in rules
+type RedactConfig struct {
+	// Headers are the names of the headers whose values are redacted
+	Headers []string `yaml:"headers,omitempty"`
+	// JSONPaths are the dot-separated paths of the values redacted from JSON bodies, * matches any key
+	JSONPaths []string `yaml:"json_paths,omitempty"`
+	// Patterns are regular expressions redacting the parts of the bodies they match
+	Patterns []string `yaml:"patterns,omitempty"`
+}
+
+// ConjurrcPath returns the path of the .conjurrc file, which is $CONJURRC or ~/.conjurrc
+func ConjurrcPath() string {
+	if conjurrc := os.Getenv("CONJURRC"); conjurrc != "" {
+		return conjurrc
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".conjurrc")
+}
+
+// LoadCLIConfig loads the CLI settings from .conjurrc and the environment. Environment variables
+// take precedence over the file.
+func LoadCLIConfig() (CLIConfig, error) {
+	cliConfig, err := loadCLIConfigFile()
+	if err != nil {
+		return cliConfig, err
+	}
+
+	if jwtSource := os.Getenv("CONJUR_AUTHN_JWT_SOURCE"); jwtSource != "" {
+		cliConfig.JWTSource = jwtSource
+	}
+	if metadataURL := os.Getenv("CONJUR_AUTHN_METADATA_URL"); metadataURL != "" {
+		cliConfig.MetadataURL = metadataURL
+	}
+	if certFingerprint := os.Getenv("CONJUR_CERT_FINGERPRINT"); certFingerprint != "" {
+		cliConfig.CertFingerprint = certFingerprint
+	}
+	if clientCertBundle := os.Getenv("CONJUR_CLIENT_CERT_BUNDLE"); clientCertBundle != "" {
+		cliConfig.ClientCertBundle = clientCertBundle
+	}
+	if identityProxy := os.Getenv("CONJUR_IDENTITY_PROXY"); identityProxy != "" {
+		cliConfig.IdentityProxy = identityProxy
+	}
+	if followerURLs := os.Getenv("CONJUR_FOLLOWER_URLS"); followerURLs != "" {
+		cliConfig.FollowerURLs = nil
+		for _, followerURL := range strings.Split(followerURLs, ",") {
+			if followerURL = strings.TrimSpace(followerURL); followerURL != "" {
+				cliConfig.FollowerURLs = append(cliConfig.FollowerURLs, followerURL)
+			}
+		}
+	}
+
+	return cliConfig, nil
+}
+
+func loadCLIConfigFile() (CLIConfig, error) {
+	cliConfig := CLIConfig{}
+
+	if conjurrc := ConjurrcPath(); conjurrc != "" {
+		data, err := os.ReadFile(conjurrc)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return cliConfig, err
+		}
+		if err = yaml.Unmarshal(data, &cliConfig); err != nil {
+			return cliConfig, err
+		}
+	}
+	return cliConfig, nil
+}
+
+// UpdateCLIConfig applies update to the CLI settings in .conjurrc and writes them back. Only the
+// keys of CLIConfig are rewritten, the rest of the file is left as is. Environment variables are
+// not taken into account, so that they aren't persisted.
+func UpdateCLIConfig(update func(cliConfig *CLIConfig)) error {
+	cliConfig, err := loadCLIConfigFile()
+	if err != nil {
+		return err
+	}
+	update(&cliConfig)
+
+	return updateConjurrc(func(mapping *yaml.Node) error {
+		var values yaml.Node
+		if err := values.Encode(&cliConfig); err != nil {
+			return err
+		}
+		for _, key := range cliConfigKeys() {
+			removeYAMLKey(mapping, key)
+		}
+		mapping.Content = append(mapping.Content, values.Content...)
+		return nil
+	})
+}
+
+// SetConjurrcValue sets a key of .conjurrc, leaving the rest of the file as is
+func SetConjurrcValue(key string, value string) error {
+	return updateConjurrc(func(mapping *yaml.Node) error {
+		removeYAMLKey(mapping, key)
+		mapping.Content = append(mapping.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: key},
+			&yaml.Node{Kind: yaml.ScalarNode, Value: value},
+		)
+		return nil
+	})
+}
+
+// updateConjurrc applies update to the top-level mapping of .conjurrc, which is created if it
+// doesn't exist, and writes it back
+func updateConjurrc(update func(mapping *yaml.Node) error) error {
+	conjurrc := ConjurrcPath()
+	if conjurrc == "" {
+		return errors.New("Unable to determine the location of .conjurrc")
+	}
+
+	var doc yaml.Node
+	data, err := os.ReadFile(conjurrc)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	mapping := doc.Content[0]
+	if mapping.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a valid configuration file", conjurrc)
+	}
+	if err = update(mapping); err != nil {
+		return err
+	}
+
+	data, err = yaml.Marshal(&doc)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(conjurrc, data, 0600)
+}
+
+// cliConfigKeys returns the .conjurrc keys of the CLI settings
+func cliConfigKeys() []string {
+	var keys []string
+	fields := reflect.TypeOf(CLIConfig{})
+	for i := 0; i < fields.NumField(); i++ {
+		name, _, _ := strings.Cut(fields.Field(i).Tag.Get("yaml"), ",")
+		keys = append(keys, name)
+	}
+	return keys
+}
+
+func removeYAMLKey(mapping *yaml.Node, key string) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return
+		}
+	}
+}
+
+// Conjurrc returns the contents of a .conjurrc file holding both the Conjur configuration and the CLI settings
+func Conjurrc(config conjurapi.Config, cliConfig CLIConfig) []byte {
+	contents := config.Conjurrc()
+	if reflect.DeepEqual(cliConfig, CLIConfig{}) {
+		return contents
+	}
+	data, _ := yaml.Marshal(&cliConfig)
+	return append(contents, data...)
+}
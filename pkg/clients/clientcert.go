@@ -0,0 +1,97 @@
+package clients
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/prompts"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+)
+
+const clientKeyPassphraseEnvVar = "CONJUR_CLIENT_KEY_PASSPHRASE"
+
+// loadedClientCerts holds the client certificates already loaded by the invocation, by file, so that
+// the passphrase of an encrypted key is only asked once
+var loadedClientCerts sync.Map
+
+// ClientCertificate loads the client certificate presented to the servers requiring mutual TLS, from
+// client_cert_file and client_cert_key_file or from the PKCS#12 bundle of client_cert_bundle. It
+// returns nil when none is configured.
+func ClientCertificate(config conjurapi.Config, cliConfig CLIConfig) (*tls.Certificate, error) {
+	return clientCertificate(config, cliConfig, isInteractive)
+}
+
+// ClientCertificateWithoutPrompt loads the client certificate like ClientCertificate, but fails
+// instead of prompting when the key is encrypted and CONJUR_CLIENT_KEY_PASSPHRASE isn't set
+func ClientCertificateWithoutPrompt(config conjurapi.Config, cliConfig CLIConfig) (*tls.Certificate, error) {
+	return clientCertificate(config, cliConfig, func() bool { return false })
+}
+
+func clientCertificate(config conjurapi.Config, cliConfig CLIConfig, interactive func() bool) (*tls.Certificate, error) {
+	var cacheKey string
+	var load func() (tls.Certificate, error)
+	switch {
+	case cliConfig.ClientCertBundle != "":
+		cacheKey = cliConfig.ClientCertBundle
+		load = func() (tls.Certificate, error) {
+			return utils.LoadPKCS12ClientCertificate(cliConfig.ClientCertBundle, clientKeyPassphrase(cliConfig.ClientCertBundle, interactive))
+		}
+	case config.ClientCertFile != "" && config.ClientCertKeyFile != "":
+		cacheKey = config.ClientCertFile + "\n" + config.ClientCertKeyFile
+		load = func() (tls.Certificate, error) {
+			return utils.LoadClientCertificate(config.ClientCertFile, config.ClientCertKeyFile, clientKeyPassphrase(config.ClientCertKeyFile, interactive))
+		}
+	default:
+		return nil, nil
+	}
+
+	if cert, ok := loadedClientCerts.Load(cacheKey); ok {
+		return cert.(*tls.Certificate), nil
+	}
+	cert, err := load()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to load the client certificate: %s", err)
+	}
+	loadedClientCerts.Store(cacheKey, &cert)
+	return &cert, nil
+}
+
+func clientKeyPassphrase(path string, interactive func() bool) func() (string, error) {
+	return func() (string, error) {
+		if passphrase := os.Getenv(clientKeyPassphraseEnvVar); passphrase != "" {
+			return passphrase, nil
+		}
+		if !interactive() {
+			return "", fmt.Errorf("The private key in %s is encrypted. Provide its passphrase with %s", path, clientKeyPassphraseEnvVar)
+		}
+		return prompts.AskForClientKeyPassphrase(path)
+	}
+}
+
+// UseClientCertificateForClient makes a Conjur client present the configured client certificate to
+// the servers requiring mutual TLS
+func UseClientCertificateForClient(client ConjurClient, cliConfig CLIConfig) error {
+	if client == nil {
+		return nil
+	}
+	cert, err := ClientCertificate(client.GetConfig(), cliConfig)
+	if err != nil || cert == nil {
+		return err
+	}
+
+	httpClient := client.GetHttpClient()
+	if httpClient == nil {
+		return errors.New("Unable to present the client certificate")
+	}
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("Unable to present the client certificate")
+	}
+	utils.UseClientCertificate(transport, *cert)
+	return nil
+}
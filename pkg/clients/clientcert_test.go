@@ -0,0 +1,146 @@
+package clients
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestClientCert writes a self-signed client certificate and its private key, encrypted when
+// a passphrase is given
+func writeTestClientCert(t *testing.T, passphrase string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	keyBlock := &pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}
+	if passphrase != "" {
+		keyBlock, err = x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", keyDer, []byte(passphrase), x509.PEMCipherAES256)
+		assert.NoError(t, err)
+	}
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	keyFile := filepath.Join(dir, "client.key")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(keyBlock), 0600))
+	return certFile, keyFile
+}
+
+// newMutualTLSServer starts a TLS server that requires the client certificate of the returned config
+func newMutualTLSServer(t *testing.T, handler http.Handler) (*httptest.Server, conjurapi.Config) {
+	certFile, keyFile := writeTestClientCert(t, "")
+	clientCert, err := os.ReadFile(certFile)
+	assert.NoError(t, err)
+	clientCAs := x509.NewCertPool()
+	assert.True(t, clientCAs.AppendCertsFromPEM(clientCert))
+
+	server := httptest.NewUnstartedServer(handler)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return server, conjurapi.Config{
+		Account:           "test-account",
+		ApplianceURL:      server.URL,
+		SSLCert:           string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})),
+		ClientCertFile:    certFile,
+		ClientCertKeyFile: keyFile,
+		CredentialStorage: conjurapi.CredentialStorageNone,
+	}
+}
+
+// newMutualTLSClient creates a client presenting the client certificate of the config
+func newMutualTLSClient(t *testing.T, config conjurapi.Config) *conjurapi.Client {
+	client, err := conjurapi.NewClient(config)
+	assert.NoError(t, err)
+	assert.NoError(t, UseClientCertificateForClient(client, CLIConfig{}))
+	return client
+}
+
+func TestClientCertificate(t *testing.T) {
+	originalIsInteractive := isInteractive
+	isInteractive = func() bool { return false }
+	defer func() { isInteractive = originalIsInteractive }()
+
+	t.Run("returns nil without a client certificate", func(t *testing.T) {
+		cert, err := ClientCertificate(conjurapi.Config{}, CLIConfig{})
+		assert.NoError(t, err)
+		assert.Nil(t, cert)
+	})
+
+	t.Run("loads the certificate and its key", func(t *testing.T) {
+		certFile, keyFile := writeTestClientCert(t, "")
+		cert, err := ClientCertificate(conjurapi.Config{ClientCertFile: certFile, ClientCertKeyFile: keyFile}, CLIConfig{})
+		assert.NoError(t, err)
+		assert.NotNil(t, cert)
+	})
+
+	t.Run("decrypts the key with the passphrase from the environment", func(t *testing.T) {
+		certFile, keyFile := writeTestClientCert(t, "secret")
+		t.Setenv(clientKeyPassphraseEnvVar, "secret")
+		cert, err := ClientCertificate(conjurapi.Config{ClientCertFile: certFile, ClientCertKeyFile: keyFile}, CLIConfig{})
+		assert.NoError(t, err)
+		assert.NotNil(t, cert)
+
+		// The certificate is only loaded once
+		t.Setenv(clientKeyPassphraseEnvVar, "")
+		again, err := ClientCertificate(conjurapi.Config{ClientCertFile: certFile, ClientCertKeyFile: keyFile}, CLIConfig{})
+		assert.NoError(t, err)
+		assert.Same(t, cert, again)
+	})
+
+	t.Run("fails for an encrypted key without a passphrase", func(t *testing.T) {
+		certFile, keyFile := writeTestClientCert(t, "secret")
+		_, err := ClientCertificate(conjurapi.Config{ClientCertFile: certFile, ClientCertKeyFile: keyFile}, CLIConfig{})
+		assert.EqualError(t, err, "Unable to load the client certificate: The private key in "+keyFile+" is encrypted. Provide its passphrase with CONJUR_CLIENT_KEY_PASSPHRASE")
+	})
+
+	t.Run("fails for a missing bundle", func(t *testing.T) {
+		_, err := ClientCertificate(conjurapi.Config{}, CLIConfig{ClientCertBundle: filepath.Join(t.TempDir(), "client.p12")})
+		assert.ErrorContains(t, err, "Unable to load the client certificate")
+	})
+}
+
+func TestUseClientCertificateForClient(t *testing.T) {
+	certFile, keyFile := writeTestClientCert(t, "")
+	config := conjurapi.Config{Account: "conjur", ApplianceURL: "https://conjur.com", ClientCertFile: certFile, ClientCertKeyFile: keyFile}
+	client, _ := conjurapi.NewClientFromKey(config, authn.LoginPair{Login: "username", APIKey: "password"})
+
+	t.Run("presents the client certificate", func(t *testing.T) {
+		transport := &http.Transport{}
+		client.SetHttpClient(&http.Client{Transport: transport})
+		assert.NoError(t, UseClientCertificateForClient(client, CLIConfig{}))
+		assert.Len(t, transport.TLSClientConfig.Certificates, 1)
+	})
+
+	t.Run("fails when the transport can't present it", func(t *testing.T) {
+		client.SetHttpClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, nil
+		})})
+		assert.EqualError(t, UseClientCertificateForClient(client, CLIConfig{}), "Unable to present the client certificate")
+	})
+}
@@ -1,12 +1,16 @@
 package clients
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
 	"time"
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -40,6 +44,7 @@ type ConjurClient interface {
 	RefreshToken() error
 	ForceRefreshToken() error
 	GetHttpClient() *http.Client
+	SetHttpClient(httpClient *http.Client)
 	RoleExists(roleID string) (bool, error)
 	Role(roleID string) (role map[string]interface{}, err error)
 	RoleMembers(roleID string) (members []map[string]interface{}, err error)
@@ -71,11 +76,71 @@ func LoadAndValidateConjurConfig(timeout time.Duration) (conjurapi.Config, error
 		// do not overwrite the value set in the env or .conjurrc file
 		config.HTTPTimeout = int(timeout.Seconds())
 	}
-	err = config.Validate()
+
+	cliConfig, err := LoadCLIConfig()
+	if err != nil {
+		return config, err
+	}
+	err = ValidateConfig(config, cliConfig)
 
 	return config, err
 }
 
+// ValidateConfig validates the Conjur configuration, taking into account the CLI settings that
+// conjurapi.Config doesn't know about
+func ValidateConfig(config conjurapi.Config, cliConfig CLIConfig) error {
+	if config.AuthnType == "jwt" && cliConfig.JWTSource != "" && config.JWTContent == "" && config.JWTFilePath == "" {
+		// The JWT is only obtained from the source when authenticating (see NewJWTClient)
+		config.JWTContent = cliConfig.JWTSource
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	// conjurapi caches the OIDC access token in the credential storage, which it can't do in the encrypted file
+	if config.CredentialStorage == CredentialStorageEncryptedFile && config.AuthnType == "oidc" {
+		return errors.New("The encrypted-file credential storage is not supported with OIDC authentication")
+	}
+	if _, err := cliConfig.Redact.rule(); err != nil {
+		return err
+	}
+	if cliConfig.CertFingerprint != "" {
+		if _, err := utils.NormalizeFingerprint(cliConfig.CertFingerprint); err != nil {
+			return fmt.Errorf("Invalid cert_fingerprint: %s", err)
+		}
+		// Only the certificate of the appliance URL is pinned, the other servers would be rejected
+		if config.IsSaaS() {
+			return errors.New("cert_fingerprint is only supported in Secrets Manager Self-Hosted")
+		}
+		if len(cliConfig.FollowerURLs) > 0 {
+			return errors.New("follower_urls can't be used with cert_fingerprint, which only pins the certificate of the leader")
+		}
+	}
+	// conjurapi only checks the client certificate for authn-cert, the CLI presents it to every server
+	if config.AuthnType != "cert" && (config.ClientCertFile == "") != (config.ClientCertKeyFile == "") {
+		return errors.New("Must specify both client_cert_file and client_cert_key_file to use a client certificate")
+	}
+	if cliConfig.ClientCertBundle != "" && config.ClientCertFile != "" {
+		return errors.New("Must not specify both client_cert_bundle and client_cert_file")
+	}
+	if _, err := parseProxy("proxy", config.Proxy); err != nil {
+		return err
+	}
+	if _, err := parseProxy("identity_proxy", cliConfig.IdentityProxy); err != nil {
+		return err
+	}
+	if len(cliConfig.FollowerURLs) > 0 && config.IsSaaS() {
+		return errors.New("follower_urls are only supported in Secrets Manager Self-Hosted")
+	}
+	if _, err := followerURLs(cliConfig); err != nil {
+		return err
+	}
+	// The host ID is part of the audience of the GCP identity token
+	if config.AuthnType == "gcp" && config.JWTHostID == "" {
+		return errors.New("Must specify a HostID when using gcp authentication")
+	}
+	return nil
+}
+
 // LoadConfigOrDefault loads the Conjur configuration or returns a default configuration
 // in case the configuration is not found or invalid, this method is needed for initialization
 // of commands which depends on the environment
@@ -86,7 +151,15 @@ func LoadConfigOrDefault() conjurapi.Config {
 
 // AuthenticatedConjurClientForCommand attempts to get an authenticated Conjur client by iterating through
 // configuration, environment variables and then ultimately falling back on prompting the user for credentials.
+// The client is reused by the other commands of the invocation when the context holds a ClientProvider.
 func AuthenticatedConjurClientForCommand(cmd *cobra.Command) (ConjurClient, error) {
+	if provider := clientProviderForCommand(cmd); provider != nil {
+		return provider.Client(cmd)
+	}
+	return newAuthenticatedConjurClient(cmd)
+}
+
+func newAuthenticatedConjurClient(cmd *cobra.Command) (ConjurClient, error) {
 	var err error
 	var debug bool
 
@@ -108,33 +181,105 @@ func AuthenticatedConjurClientForCommand(cmd *cobra.Command) (ConjurClient, erro
 	if err != nil {
 		return nil, err
 	}
+	// Use the credentials of another cached identity if requested with --as
+	config, err = ApplyIdentity(config, GetIdentityFlag(cmd))
+	if err != nil {
+		return nil, err
+	}
+
+	storageConfig := config
+	config = APIConfig(config)
+
+	if _, _, err = GetRetryFlags(cmd); err != nil {
+		return nil, err
+	}
 
-	// TODO: This is called multiple time because each operation potentially uses a new HTTP client bound to the
-	// temporary Conjur client being created at that point in time. We should really not be creating so many Conjur clients
-	// we should just have one then the rest is an attempt to get an authenticator
-	decorateConjurClient := func(client ConjurClient) {
+	cliConfig, err := LoadCLIConfig()
+	if err != nil {
+		return nil, err
+	}
+	if warning := CertExpiryWarning(config, cliConfig, time.Now()); warning != "" {
+		cmd.PrintErrln(warning)
+	}
+
+	// Several Conjur clients may be created while authenticating. They all share the HTTP client of the
+	// first one, so that connections are pooled and the transport is only decorated once.
+	var httpClient *http.Client
+	decorateConjurClient := func(client ConjurClient) error {
+		if httpClient == nil {
+			httpClient = client.GetHttpClient()
+			// The proxy, the client certificate and the pin are set on the *http.Transport, before it gets
+			// wrapped
+			if err := UseProxyForClient(client, cliConfig); err != nil {
+				return err
+			}
+			if err := UseClientCertificateForClient(client, cliConfig); err != nil {
+				return err
+			}
+			if err := PinCertificateForClient(client, cliConfig); err != nil {
+				return err
+			}
+			if err := UseFollowersForClient(cmd, client, cliConfig); err != nil {
+				return err
+			}
+			// Each attempt is recorded
+			RecordHTTPForClient(cmd, client)
+			if err := RetryTransientErrorsForClient(cmd, client); err != nil {
+				return err
+			}
+		} else if client.GetHttpClient() != httpClient {
+			client.SetHttpClient(httpClient)
+		}
 		MaybeDebugLoggingForClient(debug, cmd, client)
+		cacheAccessTokens(client, storageConfig)
+		return nil
 	}
 
 	var client ConjurClient
+	if !authnTokenInEnvironment() {
+		switch {
+		case credentialStorageType(storageConfig) == CredentialStorageEncryptedFile && supportsIdentities(config) && !loginPairInEnvironment():
+			client, err = newClientFromEncryptedFile(storageConfig)
+		case config.AuthnType == "jwt" && cliConfig.JWTSource != "":
+			client, err = NewJWTClient(config, cliConfig)
+		case config.AuthnType == "iam":
+			client, err = NewIAMClient(config)
+		case config.AuthnType == "azure" || config.AuthnType == "gcp":
+			client, err = NewInstanceMetadataClient(config, cliConfig)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if client != nil {
+			if err = decorateConjurClient(client); err != nil {
+				return nil, err
+			}
+			return client, nil
+		}
+	}
+
 	client, err = conjurapi.NewClientFromEnvironment(config)
 	if err != nil {
 		return nil, err
 	}
-	decorateConjurClient(client)
+	if err = decorateConjurClient(client); err != nil {
+		return nil, err
+	}
 
 	if client.GetAuthenticator() == nil {
 		client, err = conjurapi.NewClient(config)
 		if err != nil {
 			return nil, err
 		}
-		decorateConjurClient(client)
+		if err = decorateConjurClient(client); err != nil {
+			return nil, err
+		}
 
 		switch config.AuthnType {
 		case "", "authn", "ldap":
 			client, err = Login(client)
 		case "oidc":
-			client, err = OidcLogin(client, "", "")
+			client, err = OidcLogin(client, "", "", os.Stderr)
 		case "cloud":
 			client, err = CloudLogin(client, "", "")
 		case "jwt":
@@ -146,12 +291,57 @@ func AuthenticatedConjurClientForCommand(cmd *cobra.Command) (ConjurClient, erro
 		if err != nil {
 			return nil, err
 		}
-		decorateConjurClient(client)
+		if err = decorateConjurClient(client); err != nil {
+			return nil, err
+		}
 	}
 
 	return client, nil
 }
 
+// loginPairInEnvironment returns whether the login and API key are provided through the environment,
+// which take precedence over the stored credentials
+func loginPairInEnvironment() bool {
+	return os.Getenv("CONJUR_AUTHN_LOGIN") != "" && os.Getenv("CONJUR_AUTHN_API_KEY") != ""
+}
+
+// APIConfig returns the config to pass to conjurapi, which doesn't know about the encrypted
+// credentials file. Credential storage is disabled instead, the CLI reads and stores the
+// credentials itself.
+func APIConfig(config conjurapi.Config) conjurapi.Config {
+	if config.CredentialStorage == CredentialStorageEncryptedFile {
+		config.CredentialStorage = conjurapi.CredentialStorageNone
+	}
+	return config
+}
+
+// newClientFromEncryptedFile creates a client authenticating with the credentials in the encrypted file
+func newClientFromEncryptedFile(config conjurapi.Config) (*conjurapi.Client, error) {
+	login, apiKey, err := readIdentity(config)
+	if err != nil {
+		return nil, err
+	}
+	if login == "" {
+		return nil, errors.New("No valid credentials found. Please login again.")
+	}
+	return conjurapi.NewClientFromKey(APIConfig(config), authn.LoginPair{Login: login, APIKey: apiKey})
+}
+
+// StoreCredentials stores the credentials obtained on login in the encrypted file. The other
+// credential storages are written by conjurapi itself, so nothing is done for them.
+func StoreCredentials(config conjurapi.Config, loginPair authn.LoginPair) error {
+	if credentialStorageType(config) != CredentialStorageEncryptedFile || loginPair.Login == "" {
+		return nil
+	}
+	return storeIdentity(config, loginPair.Login, loginPair.APIKey)
+}
+
+// authnTokenInEnvironment returns whether a Conjur access token is provided through the environment,
+// which takes precedence over any configured authenticator
+func authnTokenInEnvironment() bool {
+	return os.Getenv("CONJUR_AUTHN_TOKEN") != "" || os.Getenv("CONJUR_AUTHN_TOKEN_FILE") != ""
+}
+
 // GetTimeout extracts the timeout from the command flags only if explicitly set
 func GetTimeout(cmd *cobra.Command) (timeout time.Duration, err error) {
 	if cmd.Flags().Changed("timeout") {
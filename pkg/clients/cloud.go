@@ -10,6 +10,7 @@ import (
 	"strings"
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
 	"github.com/cyberark/conjur-api-go/conjurapi/response"
 	"github.com/cyberark/conjur-cli-go/pkg/prompts"
 )
@@ -24,12 +25,27 @@ func CloudLogin(conjurClient ConjurClient, username string, password string) (Co
 func cloudHostLogin(conjurClient ConjurClient, username string, password string) (ConjurClient, error) {
 	config := conjurClient.GetConfig()
 
+	if password == "" && !isInteractive() {
+		return nil, errNonInteractiveLogin
+	}
 	username, password, err := prompts.MaybeAskForCredentials(username, password)
 	if err != nil {
 		return nil, err
 	}
 
-	return conjurapi.NewClientFromCloudHost(config, username, password)
+	// Hosts authenticate with their API key through authn. Unlike conjurapi.NewClientFromCloudHost, this
+	// validates the API key with the HTTP client of conjurClient, which carries its proxy, client certificate
+	// and pin.
+	loginPair := authn.LoginPair{Login: username, APIKey: password}
+	client, err := conjurapi.NewClientFromKey(config, loginPair)
+	if err != nil {
+		return nil, err
+	}
+	client.SetHttpClient(conjurClient.GetHttpClient())
+	if _, err = client.Authenticate(loginPair); err != nil {
+		return nil, fmt.Errorf("unable to authenticate with Secrets Manager: %w", err)
+	}
+	return client, nil
 }
 
 func cloudIdentityLogin(client ConjurClient, username, password string) (ConjurClient, error) {
@@ -46,10 +62,13 @@ func cloudIdentityLogin(client ConjurClient, username, password string) (ConjurC
 		return nil, err
 	}
 
+	httpClient := client.GetHttpClient()
 	client, err = conjurapi.NewClientFromOidcToken(client.GetConfig(), authToken)
 	if err != nil {
 		return nil, err
 	}
+	// Keep the proxy, client certificate and pin of the original client
+	client.SetHttpClient(httpClient)
 
 	// Refreshes the access token and caches it locally
 	err = client.ForceRefreshToken()
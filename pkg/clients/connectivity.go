@@ -0,0 +1,47 @@
+package clients
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+)
+
+// CheckConnectivity sends an unauthenticated request to the appliance URL, the way the other
+// commands reach the server: through the configured proxy, presenting the client certificate and
+// verifying the server's certificate. Any response from the server succeeds, whatever its status.
+func CheckConnectivity(config conjurapi.Config, cliConfig CLIConfig) error {
+	client, err := unauthenticatedClient(config, cliConfig)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, config.ApplianceURL, nil)
+	if err != nil {
+		return err
+	}
+	res, err := client.GetHttpClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("Unable to connect to %s: %s", config.ApplianceURL, err)
+	}
+	return res.Body.Close()
+}
+
+// unauthenticatedClient returns a client that reaches the server the way the authenticated clients
+// do, for the endpoints that don't require an access token
+func unauthenticatedClient(config conjurapi.Config, cliConfig CLIConfig) (*conjurapi.Client, error) {
+	client, err := conjurapi.NewClient(APIConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	if err = UseProxyForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	if err = UseClientCertificateForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	if err = PinCertificateForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
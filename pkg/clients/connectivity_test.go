@@ -0,0 +1,43 @@
+package clients
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConnectivity(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+	certFile := filepath.Join(t.TempDir(), "conjur-server.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("https_proxy", "")
+
+	config := conjurapi.Config{Account: "conjur", ApplianceURL: server.URL, SSLCertPath: certFile, CredentialStorage: conjurapi.CredentialStorageNone}
+
+	t.Run("succeeds whatever the status of the response", func(t *testing.T) {
+		assert.NoError(t, CheckConnectivity(config, CLIConfig{}))
+	})
+
+	t.Run("fails when the server's certificate isn't trusted", func(t *testing.T) {
+		config := config
+		config.SSLCertPath = ""
+		err := CheckConnectivity(config, CLIConfig{})
+		assert.ErrorContains(t, err, "Unable to connect to "+server.URL)
+		assert.ErrorContains(t, err, "certificate")
+	})
+
+	t.Run("fails when the server's certificate doesn't match the pin", func(t *testing.T) {
+		err := CheckConnectivity(config, CLIConfig{CertFingerprint: "AB:CD:" + "00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00:00"})
+		assert.ErrorContains(t, err, "Unable to connect to "+server.URL)
+	})
+}
@@ -0,0 +1,85 @@
+package clients
+
+import (
+	"fmt"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/storage"
+)
+
+// credentialStorageNames maps the names of the credential storages to their credential_storage value
+var credentialStorageNames = map[string]string{
+	"keyring":                      conjurapi.CredentialStorageKeyring,
+	"netrc":                        conjurapi.CredentialStorageFile,
+	CredentialStorageEncryptedFile: CredentialStorageEncryptedFile,
+}
+
+// MigrateCredentials moves the cached credentials of the logged in identity and of the other cached
+// identities to another credential storage, and selects it in .conjurrc. The credentials are only
+// removed from the previous storage once .conjurrc is updated. It returns the number of credentials moved.
+func MigrateCredentials(config conjurapi.Config, to string) (int, error) {
+	target, ok := credentialStorageNames[to]
+	if !ok {
+		return 0, fmt.Errorf("Unknown credential storage %q. Must be one of keyring, netrc or encrypted-file", to)
+	}
+	source := credentialStorageType(config)
+	switch {
+	case source == target:
+		return 0, fmt.Errorf("Credentials are already stored in %s", to)
+	case source == conjurapi.CredentialStorageNone:
+		return 0, fmt.Errorf("Credential storage is disabled, there are no credentials to migrate")
+	case target == conjurapi.CredentialStorageKeyring && !storage.IsKeyringAvailable():
+		return 0, fmt.Errorf("Keyring is not available")
+	case target == CredentialStorageEncryptedFile && config.AuthnType == "oidc":
+		return 0, fmt.Errorf("The encrypted-file credential storage is not supported with OIDC authentication")
+	}
+
+	targetConfig := config
+	targetConfig.CredentialStorage = target
+	sources := []conjurapi.Config{config}
+	targets := []conjurapi.Config{targetConfig}
+
+	cliConfig, err := loadCLIConfigFile()
+	if err != nil {
+		return 0, err
+	}
+	for _, identity := range cliConfig.Identities {
+		sourceSlot, err := identityConfig(config, identity)
+		if err != nil {
+			return 0, err
+		}
+		targetSlot, err := identityConfig(targetConfig, identity)
+		if err != nil {
+			return 0, err
+		}
+		sources = append(sources, sourceSlot)
+		targets = append(targets, targetSlot)
+	}
+
+	var migrated []conjurapi.Config
+	for i, sourceSlot := range sources {
+		provider, err := credentialStorage(sourceSlot)
+		if err != nil {
+			return 0, err
+		}
+		// Access tokens cached for OIDC are moved as they are, like API keys
+		login, password, err := provider.ReadCredentials()
+		if err != nil || login == "" {
+			continue
+		}
+		if err = storeIdentity(targets[i], login, password); err != nil {
+			return 0, err
+		}
+		migrated = append(migrated, sourceSlot)
+	}
+
+	if err = SetConjurrcValue("credential_storage", target); err != nil {
+		return 0, err
+	}
+	for _, sourceSlot := range migrated {
+		if err = purgeCredentials(sourceSlot); err != nil {
+			return 0, err
+		}
+	}
+	return len(migrated), nil
+}
@@ -0,0 +1,84 @@
+package clients
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrateCredentials(t *testing.T) {
+	t.Run("moves the cached identities to the encrypted file", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		setTestCredentialsFile(t)
+		loginAs(t, config, "alice", "alice-api-key")
+		loginAs(t, config, "host/app/web-01", "host-api-key")
+
+		migrated, err := MigrateCredentials(config, "encrypted-file")
+		assert.NoError(t, err)
+		assert.Equal(t, 3, migrated)
+
+		// The previous storage is emptied
+		active, err := ActiveIdentity(config)
+		assert.NoError(t, err)
+		assert.Empty(t, active)
+
+		data, err := os.ReadFile(os.Getenv("CONJURRC"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "credential_storage: encrypted-file\n")
+
+		config.CredentialStorage = CredentialStorageEncryptedFile
+		active, err = ActiveIdentity(config)
+		assert.NoError(t, err)
+		assert.Equal(t, "host/app/web-01", active)
+		asConfig, err := ApplyIdentity(config, "alice")
+		assert.NoError(t, err)
+		login, apiKey, err := readIdentity(asConfig)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", login)
+		assert.Equal(t, "alice-api-key", apiKey)
+	})
+
+	t.Run("rejects the current credential storage", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		_, err := MigrateCredentials(config, "netrc")
+		assert.EqualError(t, err, "Credentials are already stored in netrc")
+	})
+
+	t.Run("rejects an unknown credential storage", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		_, err := MigrateCredentials(config, "vault")
+		assert.EqualError(t, err, `Unknown credential storage "vault". Must be one of keyring, netrc or encrypted-file`)
+	})
+
+	t.Run("rejects the encrypted file with OIDC", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		config.AuthnType = "oidc"
+		_, err := MigrateCredentials(config, "encrypted-file")
+		assert.EqualError(t, err, "The encrypted-file credential storage is not supported with OIDC authentication")
+	})
+}
+
+func TestStoreCredentials(t *testing.T) {
+	config := testIdentitiesConfig(t)
+	setTestCredentialsFile(t)
+	loginPair := authn.LoginPair{Login: "alice", APIKey: "alice-api-key"}
+
+	// conjurapi stores the credentials in the other storages
+	assert.NoError(t, StoreCredentials(config, loginPair))
+	active, err := ActiveIdentity(config)
+	assert.NoError(t, err)
+	assert.Empty(t, active)
+
+	config.CredentialStorage = CredentialStorageEncryptedFile
+	assert.NoError(t, StoreCredentials(config, loginPair))
+	active, err = ActiveIdentity(config)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", active)
+
+	client, err := newClientFromEncryptedFile(config)
+	assert.NoError(t, err)
+	assert.Equal(t, conjurapi.CredentialStorageNone, client.GetConfig().CredentialStorage)
+}
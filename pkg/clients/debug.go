@@ -8,13 +8,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
-// MaybeDebugLoggingForClient optionally carries out debug logging of HTTP requests and responses on a Conjur client
+// MaybeDebugLoggingForClient optionally carries out debug logging of HTTP requests and responses on a Conjur client.
+// The credentials and secrets are redacted from the logs, unless --debug-unsafe is set.
 func MaybeDebugLoggingForClient(
 	debug bool,
 	cmd *cobra.Command,
 	client ConjurClient,
 ) {
-	if !debug {
+	unsafe := GetDebugUnsafeFlag(cmd)
+	if !debug && !unsafe {
 		return
 	}
 
@@ -27,16 +29,32 @@ func MaybeDebugLoggingForClient(
 		return
 	}
 
-	// TODO: check to see if the transport has already been decorated to make this idempotent
 	transport := httpClient.Transport
 	if transport == nil {
 		transport = http.DefaultTransport
 	}
+	// Clients may share an HTTP client, don't log its requests twice
+	if utils.IsDumpTransport(transport) {
+		return
+	}
+
+	redactor := httpLogRedactor()
+	if unsafe {
+		cmd.PrintErrln("Warning: --debug-unsafe logs credentials and secret values without redaction, don't share these logs")
+		redactor = utils.NewRedactor()
+	}
 	httpClient.Transport = utils.NewDumpTransport(
 		transport,
 		func(dump []byte) {
 			cmd.PrintErrln(string(dump))
 			cmd.PrintErrln()
 		},
+		redactor,
 	)
 }
+
+// GetDebugUnsafeFlag returns whether --debug-unsafe is set
+func GetDebugUnsafeFlag(cmd *cobra.Command) bool {
+	flag := cmd.Flags().Lookup("debug-unsafe")
+	return flag != nil && flag.Value.String() == "true"
+}
@@ -23,6 +23,15 @@ func TestMaybeDebugLoggingForClient(t *testing.T) {
 				assert.NotNil(t, client.GetHttpClient().Transport)
 			},
 		},
+		{
+			name:  "debug is true only decorates the transport once",
+			debug: true,
+			assert: func(t *testing.T, client *conjurapi.Client) {
+				transport := client.GetHttpClient().Transport
+				MaybeDebugLoggingForClient(true, &cobra.Command{}, client)
+				assert.Same(t, transport, client.GetHttpClient().Transport)
+			},
+		},
 		{
 			name:  "debug is false uses the default transport",
 			debug: false,
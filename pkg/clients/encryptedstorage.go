@@ -0,0 +1,337 @@
+package clients
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/cyberark/conjur-api-go/conjurapi/storage"
+	"github.com/cyberark/conjur-cli-go/pkg/prompts"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+)
+
+// CredentialStorageEncryptedFile stores credentials in a file encrypted with a key derived from a
+// passphrase or from the machine secret. conjurapi doesn't know about it, see APIConfig. Any local
+// user can derive the key from the machine secret, it only prevents copying the file to another
+// machine. Only the passphrase and the file permissions protect it from the other users.
+const CredentialStorageEncryptedFile = "encrypted-file"
+
+const (
+	encryptedFileVersion = 1
+	// Keys the credentials file can be encrypted with
+	encryptionKeyMachine    = "machine"
+	encryptionKeyPassphrase = "passphrase"
+	// passphraseIterations is the PBKDF2 work factor recommended by OWASP for HMAC-SHA256
+	passphraseIterations = 600000
+	passphraseEnvVar     = "CONJUR_CREDENTIALS_PASSPHRASE"
+)
+
+// machineIDPaths are the files holding the machine secret, which is generated when the OS is
+// installed. They are overridden in tests.
+var machineIDPaths = []string{"/etc/machine-id", "/var/lib/dbus/machine-id"}
+
+var (
+	// credentialsPassphrase caches the passphrase, so that it is asked for at most once per command
+	credentialsPassphrase string
+	// derivedKeys caches the keys derived from the passphrase or machine secret by salt
+	derivedKeys sync.Map
+)
+
+// SetCredentialsPassphrase sets the passphrase to encrypt a new credentials file with, instead of
+// the machine secret
+func SetCredentialsPassphrase(passphrase string) {
+	credentialsPassphrase = passphrase
+}
+
+// encryptedFile is the format of the encrypted credentials file
+type encryptedFile struct {
+	Version int    `json:"version"`
+	Key     string `json:"key"`
+	Salt    []byte `json:"salt"`
+	Nonce   []byte `json:"nonce"`
+	Data    []byte `json:"data"`
+}
+
+type encryptedCredentials struct {
+	Login    string `json:"login"`
+	Password string `json:"password"`
+}
+
+// encryptedFileStorage implements conjurapi.CredentialStorageProvider. All the machines share the
+// file, which maps each machine name to its credentials.
+type encryptedFileStorage struct {
+	path        string
+	machineName string
+}
+
+// CredentialsFilePath returns the path of the encrypted credentials file, which is set with
+// credentials_file in .conjurrc or $CONJUR_CREDENTIALS_FILE, or defaults to ~/.conjur-credentials
+func CredentialsFilePath() (string, error) {
+	if path := os.Getenv("CONJUR_CREDENTIALS_FILE"); path != "" {
+		return path, nil
+	}
+	cliConfig, err := loadCLIConfigFile()
+	if err != nil {
+		return "", err
+	}
+	if cliConfig.CredentialsFile != "" {
+		return cliConfig.CredentialsFile, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".conjur-credentials"), nil
+}
+
+func newEncryptedFileStorage(machineName string) (*encryptedFileStorage, error) {
+	path, err := CredentialsFilePath()
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedFileStorage{path: path, machineName: machineName}, nil
+}
+
+// StoreCredentials stores the credentials of the machine in the encrypted file
+func (s *encryptedFileStorage) StoreCredentials(login string, password string) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	header, credentials, err := s.read()
+	if err != nil {
+		return err
+	}
+	credentials[s.machineName] = encryptedCredentials{Login: login, Password: password}
+	return s.write(header, credentials)
+}
+
+// ReadCredentials reads the credentials of the machine from the encrypted file
+func (s *encryptedFileStorage) ReadCredentials() (string, string, error) {
+	_, credentials, err := s.read()
+	if err != nil {
+		return "", "", err
+	}
+	machine, ok := credentials[s.machineName]
+	if !ok {
+		return "", "", fmt.Errorf("credentials for machine %s were not found in %s", s.machineName, s.path)
+	}
+	return machine.Login, machine.Password, nil
+}
+
+// ReadAuthnToken reads the cached access token, which is stored like in the .netrc file
+func (s *encryptedFileStorage) ReadAuthnToken() ([]byte, error) {
+	_, token, err := s.ReadCredentials()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(token), nil
+}
+
+// StoreAuthnToken caches the access token, which is stored like in the .netrc file
+func (s *encryptedFileStorage) StoreAuthnToken(token []byte) error {
+	return s.StoreCredentials(storage.OidcStorageMarker, string(token))
+}
+
+// PurgeCredentials removes the credentials of the machine from the encrypted file
+func (s *encryptedFileStorage) PurgeCredentials() error {
+	if _, err := os.Stat(s.path); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	header, credentials, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := credentials[s.machineName]; !ok {
+		return nil
+	}
+	delete(credentials, s.machineName)
+	return s.write(header, credentials)
+}
+
+// lock keeps the concurrent invocations from losing each other's updates to the file. The file
+// itself is replaced on every write, so the lock is taken on a file next to it.
+func (s *encryptedFileStorage) lock() (func(), error) {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return nil, err
+	}
+	unlock, err := utils.LockFile(s.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("Unable to lock %s: %s", s.path, err)
+	}
+	return unlock, nil
+}
+
+// read decrypts the file. The header of a new file is returned if it doesn't exist.
+func (s *encryptedFileStorage) read() (encryptedFile, map[string]encryptedCredentials, error) {
+	credentials := map[string]encryptedCredentials{}
+
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		header, err := newEncryptedFileHeader()
+		return header, credentials, err
+	}
+	if err != nil {
+		return encryptedFile{}, nil, err
+	}
+
+	var header encryptedFile
+	if err = json.Unmarshal(data, &header); err != nil || header.Version != encryptedFileVersion {
+		return header, nil, fmt.Errorf("%s is not a valid encrypted credentials file", s.path)
+	}
+	aead, err := encryptedFileCipher(header)
+	if err != nil {
+		return header, nil, err
+	}
+	plaintext, err := aead.Open(nil, header.Nonce, header.Data, []byte(header.Key))
+	if err != nil {
+		return header, nil, fmt.Errorf("Unable to decrypt %s. The passphrase or machine secret it was encrypted with has changed", s.path)
+	}
+	if err = json.Unmarshal(plaintext, &credentials); err != nil {
+		return header, nil, err
+	}
+	return header, credentials, nil
+}
+
+// write encrypts the credentials with a new nonce and atomically replaces the file
+func (s *encryptedFileStorage) write(header encryptedFile, credentials map[string]encryptedCredentials) error {
+	plaintext, err := json.Marshal(credentials)
+	if err != nil {
+		return err
+	}
+	aead, err := encryptedFileCipher(header)
+	if err != nil {
+		return err
+	}
+	header.Nonce = make([]byte, aead.NonceSize())
+	if _, err = rand.Read(header.Nonce); err != nil {
+		return err
+	}
+	header.Data = aead.Seal(nil, header.Nonce, plaintext, []byte(header.Key))
+
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// newEncryptedFileHeader returns the header of a new file, which is encrypted with the passphrase
+// if one was provided, and with the machine secret otherwise
+func newEncryptedFileHeader() (encryptedFile, error) {
+	header := encryptedFile{Version: encryptedFileVersion, Key: encryptionKeyMachine, Salt: make([]byte, 16)}
+	if credentialsPassphrase != "" || os.Getenv(passphraseEnvVar) != "" {
+		header.Key = encryptionKeyPassphrase
+	}
+	_, err := rand.Read(header.Salt)
+	return header, err
+}
+
+func encryptedFileCipher(header encryptedFile) (cipher.AEAD, error) {
+	key, err := encryptionKey(header)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encryptionKey derives the AES-256 key of the file
+func encryptionKey(header encryptedFile) ([]byte, error) {
+	cacheKey := header.Key + ":" + string(header.Salt)
+	if key, ok := derivedKeys.Load(cacheKey); ok {
+		return key.([]byte), nil
+	}
+
+	var key []byte
+	switch header.Key {
+	case encryptionKeyPassphrase:
+		passphrase, err := getCredentialsPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		key, err = pbkdf2.Key(sha256.New, passphrase, header.Salt, passphraseIterations, 32)
+		if err != nil {
+			return nil, err
+		}
+	case encryptionKeyMachine:
+		secret, err := machineSecret()
+		if err != nil {
+			return nil, err
+		}
+		// The machine secret is readable by all users, the file permissions keep the others out
+		key, err = hkdf.Key(sha256.New, secret, header.Salt, fmt.Sprintf("conjur-cli credentials %d", os.Getuid()), 32)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported encryption key %q", header.Key)
+	}
+
+	derivedKeys.Store(cacheKey, key)
+	return key, nil
+}
+
+func getCredentialsPassphrase() (string, error) {
+	if credentialsPassphrase != "" {
+		return credentialsPassphrase, nil
+	}
+	if passphrase := os.Getenv(passphraseEnvVar); passphrase != "" {
+		return passphrase, nil
+	}
+	if !isInteractive() {
+		return "", fmt.Errorf("The credentials file is encrypted with a passphrase. Provide it with %s", passphraseEnvVar)
+	}
+	passphrase, err := prompts.AskForPassphrase()
+	if err != nil {
+		return "", err
+	}
+	credentialsPassphrase = passphrase
+	return passphrase, nil
+}
+
+func machineSecret() ([]byte, error) {
+	for _, path := range machineIDPaths {
+		data, err := os.ReadFile(path)
+		if err == nil && strings.TrimSpace(string(data)) != "" {
+			return []byte(strings.TrimSpace(string(data))), nil
+		}
+	}
+	return nil, fmt.Errorf("No machine secret is available to encrypt the credentials file. Provide a passphrase with %s instead", passphraseEnvVar)
+}
@@ -0,0 +1,128 @@
+package clients
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setTestCredentialsFile points the encrypted credentials file and the machine secret to temporary files
+func setTestCredentialsFile(t *testing.T) string {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "credentials")
+	t.Setenv("CONJUR_CREDENTIALS_FILE", path)
+	t.Setenv(passphraseEnvVar, "")
+
+	machineID := filepath.Join(dir, "machine-id")
+	assert.NoError(t, os.WriteFile(machineID, []byte("0123456789abcdef0123456789abcdef\n"), 0644))
+	originalPaths := machineIDPaths
+	machineIDPaths = []string{machineID}
+	t.Cleanup(func() {
+		machineIDPaths = originalPaths
+		SetCredentialsPassphrase("")
+	})
+	return path
+}
+
+func TestEncryptedFileStorage(t *testing.T) {
+	t.Run("stores credentials encrypted with the machine secret", func(t *testing.T) {
+		path := setTestCredentialsFile(t)
+		alice, err := newEncryptedFileStorage("https://conjur/authn")
+		assert.NoError(t, err)
+		ldap, err := newEncryptedFileStorage("https://conjur/authn-ldap/corp")
+		assert.NoError(t, err)
+
+		assert.NoError(t, alice.StoreCredentials("alice", "alice-api-key"))
+		assert.NoError(t, ldap.StoreCredentials("bob", "bob-api-key"))
+
+		login, apiKey, err := alice.ReadCredentials()
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", login)
+		assert.Equal(t, "alice-api-key", apiKey)
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "alice-api-key")
+		assert.Contains(t, string(data), `"key":"machine"`)
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		assert.NoError(t, alice.PurgeCredentials())
+		_, _, err = alice.ReadCredentials()
+		assert.ErrorContains(t, err, "credentials for machine https://conjur/authn were not found")
+		login, _, err = ldap.ReadCredentials()
+		assert.NoError(t, err)
+		assert.Equal(t, "bob", login)
+	})
+
+	t.Run("stores credentials encrypted with a passphrase", func(t *testing.T) {
+		path := setTestCredentialsFile(t)
+		t.Setenv(passphraseEnvVar, "correct horse battery staple")
+		provider, err := newEncryptedFileStorage("https://conjur/authn")
+		assert.NoError(t, err)
+
+		assert.NoError(t, provider.StoreCredentials("alice", "alice-api-key"))
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), `"key":"passphrase"`)
+
+		_, apiKey, err := provider.ReadCredentials()
+		assert.NoError(t, err)
+		assert.Equal(t, "alice-api-key", apiKey)
+
+		// Derived keys are cached, clear them to derive the key from the wrong passphrase
+		t.Setenv(passphraseEnvVar, "wrong")
+		derivedKeys.Clear()
+		_, _, err = provider.ReadCredentials()
+		assert.ErrorContains(t, err, "Unable to decrypt")
+	})
+
+	t.Run("keeps the credentials stored concurrently", func(t *testing.T) {
+		setTestCredentialsFile(t)
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				provider, err := newEncryptedFileStorage(fmt.Sprintf("https://conjur/authn-ldap/%d", i))
+				assert.NoError(t, err)
+				assert.NoError(t, provider.StoreCredentials(fmt.Sprintf("user-%d", i), "api-key"))
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < 10; i++ {
+			provider, err := newEncryptedFileStorage(fmt.Sprintf("https://conjur/authn-ldap/%d", i))
+			assert.NoError(t, err)
+			login, _, err := provider.ReadCredentials()
+			assert.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf("user-%d", i), login)
+		}
+	})
+
+	t.Run("fails without a machine secret", func(t *testing.T) {
+		setTestCredentialsFile(t)
+		machineIDPaths = []string{filepath.Join(t.TempDir(), "missing")}
+		provider, err := newEncryptedFileStorage("https://conjur/authn")
+		assert.NoError(t, err)
+
+		err = provider.StoreCredentials("alice", "alice-api-key")
+		assert.ErrorContains(t, err, "No machine secret is available")
+	})
+
+	t.Run("rejects an invalid file", func(t *testing.T) {
+		path := setTestCredentialsFile(t)
+		assert.NoError(t, os.WriteFile(path, []byte("machine conjur login alice"), 0600))
+		provider, err := newEncryptedFileStorage("https://conjur/authn")
+		assert.NoError(t, err)
+
+		_, _, err = provider.ReadCredentials()
+		assert.EqualError(t, err, path+" is not a valid encrypted credentials file")
+	})
+}
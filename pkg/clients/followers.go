@@ -0,0 +1,96 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+// followerPaths are the endpoints of the read-only operations that followers serve: retrieving
+// secrets, listing and showing resources, checking permissions and showing roles
+var followerPaths = []string{"/secrets", "/resources", "/roles"}
+
+// followerURLs parses follower_urls
+func followerURLs(cliConfig CLIConfig) ([]*url.URL, error) {
+	followers := make([]*url.URL, 0, len(cliConfig.FollowerURLs))
+	for _, followerURL := range cliConfig.FollowerURLs {
+		if _, err := normalizeURL(followerURL); err != nil {
+			return nil, fmt.Errorf("Invalid follower_urls: %s %s", followerURL, err)
+		}
+		follower, _ := url.Parse(followerURL)
+		followers = append(followers, follower)
+	}
+	return followers, nil
+}
+
+// isFollowerHost returns whether host is the host of one of the followers
+func isFollowerHost(followers []*url.URL, host string) bool {
+	for _, follower := range followers {
+		if follower.Host == host {
+			return true
+		}
+	}
+	return false
+}
+
+// isReadRequest returns a function telling whether a request to the appliance is a read-only
+// operation that a follower can serve
+func isReadRequest(applianceURL *url.URL) func(req *http.Request) bool {
+	basePath := strings.TrimSuffix(applianceURL.Path, "/")
+	return func(req *http.Request) bool {
+		if req.Method != http.MethodGet {
+			return false
+		}
+		path := strings.TrimPrefix(req.URL.Path, basePath)
+		for _, followerPath := range followerPaths {
+			if path == followerPath || strings.HasPrefix(path, followerPath+"/") {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// UseFollowersForClient makes a Conjur client send the read-only operations to the followers of
+// follower_urls, failing over to the next follower and then to the leader, while the other
+// requests go to the appliance URL. The failovers are logged with --debug.
+func UseFollowersForClient(cmd *cobra.Command, client ConjurClient, cliConfig CLIConfig) error {
+	if len(cliConfig.FollowerURLs) == 0 || client == nil {
+		return nil
+	}
+	followers, err := followerURLs(cliConfig)
+	if err != nil {
+		return err
+	}
+	applianceURL, err := url.Parse(client.GetConfig().ApplianceURL)
+	if err != nil {
+		return err
+	}
+	httpClient := client.GetHttpClient()
+	if httpClient == nil {
+		return errors.New("Unable to route requests to the followers")
+	}
+	if utils.IsFollowerTransport(httpClient.Transport) {
+		return nil
+	}
+	debug, _ := cmd.Flags().GetBool("debug")
+
+	httpClient.Transport = utils.NewFollowerTransport(
+		httpClient.Transport,
+		applianceURL,
+		followers,
+		isReadRequest(applianceURL),
+		func(message string) {
+			if debug {
+				cmd.PrintErrln(message)
+			}
+		},
+	)
+	return nil
+}
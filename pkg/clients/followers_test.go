@@ -0,0 +1,105 @@
+package clients
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+// newStubConjur returns a server standing for a leader or follower, which records the requests
+// sent to it and answers them all with the given body
+func newStubConjur(t *testing.T, body string) (*httptest.Server, *[]string) {
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestUseFollowersForClient(t *testing.T) {
+	t.Setenv("HTTP_PROXY", "")
+	t.Setenv("http_proxy", "")
+
+	newClient := func(t *testing.T, leaderURL string, cliConfig CLIConfig) (*conjurapi.Client, *bytes.Buffer) {
+		config := conjurapi.Config{Account: "conjur", ApplianceURL: leaderURL, CredentialStorage: conjurapi.CredentialStorageNone}
+		client, err := conjurapi.NewClientFromToken(config, `{"protected":"eyJhbGciOiJjb25qdXIub3JnL3Nsb3NpbG8vdjIiLCJraWQiOiI5M2VjNTEwODRmZTM3Zjc3M2I1ODhlNTYyYWVjZGMxMSJ9","payload":"eyJzdWIiOiJhZG1pbiIsImlhdCI6MTUxMDc1MzI1OX0=","signature":"raCufKOf7sKzciZInQTphu1mBbLhAdIJM72ChLB4m5wKWxFnNz_7LawQ9iYEI_we1-tdZtTXoopn_V1qoD4BTg"}`)
+		assert.NoError(t, err)
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("debug", true, "")
+		logs := &bytes.Buffer{}
+		cmd.SetErr(logs)
+		assert.NoError(t, UseFollowersForClient(cmd, client, cliConfig))
+		return client, logs
+	}
+
+	t.Run("sends reads to the follower and writes to the leader", func(t *testing.T) {
+		leader, leaderRequests := newStubConjur(t, `[]`)
+		follower, followerRequests := newStubConjur(t, `[]`)
+		client, _ := newClient(t, leader.URL, CLIConfig{FollowerURLs: []string{follower.URL}})
+
+		_, err := client.RetrieveSecret("db/password")
+		assert.NoError(t, err)
+		_, err = client.Resources(&conjurapi.ResourceFilter{Kind: "variable"})
+		assert.NoError(t, err)
+		_, err = client.CheckPermission("conjur:variable:db/password", "read")
+		assert.NoError(t, err)
+		_, err = client.RoleMembers("conjur:group:admins")
+		assert.NoError(t, err)
+		assert.NoError(t, client.AddSecret("db/password", "secret"))
+
+		assert.Equal(t, []string{
+			"GET /secrets/conjur/variable/db/password",
+			"GET /resources/conjur/",
+			"GET /resources/conjur/variable/db/password",
+			"GET /roles/conjur/group/admins",
+		}, *followerRequests)
+		assert.Equal(t, []string{"POST /secrets/conjur/variable/db/password"}, *leaderRequests)
+	})
+
+	t.Run("fails over to the leader when the follower is down", func(t *testing.T) {
+		leader, leaderRequests := newStubConjur(t, `secret`)
+		follower, followerRequests := newStubConjur(t, `secret`)
+		follower.Close()
+		client, logs := newClient(t, leader.URL, CLIConfig{FollowerURLs: []string{follower.URL}})
+
+		value, err := client.RetrieveSecret("db/password")
+		assert.NoError(t, err)
+		assert.Equal(t, "secret", string(value))
+		assert.Empty(t, *followerRequests)
+		assert.Equal(t, []string{"GET /secrets/conjur/variable/db/password"}, *leaderRequests)
+		assert.Contains(t, logs.String(), "Follower "+follower.URL+" failed")
+		assert.Contains(t, logs.String(), "No follower is available, sending GET "+leader.URL+"/secrets/conjur/variable/db%2Fpassword to the leader")
+	})
+
+	t.Run("does nothing without followers", func(t *testing.T) {
+		leader, leaderRequests := newStubConjur(t, `secret`)
+		client, _ := newClient(t, leader.URL, CLIConfig{})
+
+		_, err := client.RetrieveSecret("db/password")
+		assert.NoError(t, err)
+		assert.Len(t, *leaderRequests, 1)
+	})
+
+	t.Run("fails for an invalid follower URL", func(t *testing.T) {
+		client, _ := conjurapi.NewClient(conjurapi.Config{Account: "conjur", ApplianceURL: "https://conjur.example.com"})
+		err := UseFollowersForClient(&cobra.Command{}, client, CLIConfig{FollowerURLs: []string{"follower.example.com"}})
+		assert.EqualError(t, err, "Invalid follower_urls: follower.example.com must be an http:// or https:// URL")
+	})
+}
+
+func TestLoadCLIConfigFollowerURLs(t *testing.T) {
+	t.Setenv("CONJURRC", "/does/not/exist")
+	t.Setenv("CONJUR_FOLLOWER_URLS", " https://follower1.example.com, https://follower2.example.com,")
+
+	cliConfig, err := LoadCLIConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"https://follower1.example.com", "https://follower2.example.com"}, cliConfig.FollowerURLs)
+}
@@ -0,0 +1,31 @@
+package clients
+
+import (
+	"sync"
+
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"github.com/cyberark/conjur-cli-go/pkg/version"
+
+	"github.com/spf13/cobra"
+)
+
+// harRecorders holds the recorder of each HAR file, so that the clients of an invocation all add
+// their requests to the same file
+var harRecorders sync.Map
+
+// RecordHTTPForClient records the HTTP requests and responses of a Conjur client in the HAR file given
+// with --http-log. They are always redacted, even with --debug-unsafe, since the file is meant to be shared.
+func RecordHTTPForClient(cmd *cobra.Command, client ConjurClient) {
+	flag := cmd.Flags().Lookup("http-log")
+	if flag == nil || flag.Value.String() == "" || client == nil {
+		return
+	}
+	httpClient := client.GetHttpClient()
+	if httpClient == nil || utils.IsHARTransport(httpClient.Transport) {
+		return
+	}
+
+	path := flag.Value.String()
+	recorder, _ := harRecorders.LoadOrStore(path, utils.NewHARRecorder(path, version.FullVersionName, httpLogRedactor()))
+	httpClient.Transport = recorder.(*utils.HARRecorder).Transport(httpClient.Transport)
+}
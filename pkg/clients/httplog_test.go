@@ -0,0 +1,32 @@
+package clients
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecordHTTPForClient(t *testing.T) {
+	client, _ := conjurapi.NewClientFromKey(conjurapi.Config{Account: "conjur", ApplianceURL: "http://conjur.com"}, authn.LoginPair{Login: "username", APIKey: "password"})
+	client.SetHttpClient(&http.Client{})
+
+	cmd := &cobra.Command{}
+	cmd.Flags().String("http-log", "", "")
+	RecordHTTPForClient(cmd, client)
+	assert.Nil(t, client.GetHttpClient().Transport)
+
+	cmd.ParseFlags([]string{"--http-log", filepath.Join(t.TempDir(), "conjur.har")})
+	RecordHTTPForClient(cmd, client)
+	transport := client.GetHttpClient().Transport
+	assert.True(t, utils.IsHARTransport(transport))
+
+	// Decorating the client again doesn't record the requests twice
+	RecordHTTPForClient(cmd, client)
+	assert.Same(t, transport, client.GetHttpClient().Transport)
+}
@@ -0,0 +1,325 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/storage"
+	"github.com/spf13/cobra"
+)
+
+// The credentials of the logged in identity are kept where conjurapi and other tools look for them.
+// The other cached identities are each kept in a slot of their own: a keychain namespace when the
+// keyring or the encrypted file is used, or a separate .netrc file next to the configured one.
+
+// identitiesDir is the directory next to the .netrc file holding the .netrc files of the cached identities
+const identitiesDir = ".conjur-identities"
+
+var errIdentitiesNotSupported = errors.New("Cached identities are only supported when authenticating with a password or API key")
+
+// supportsIdentities returns whether the authn type caches the credentials of a named identity
+func supportsIdentities(config conjurapi.Config) bool {
+	switch config.AuthnType {
+	case "", "authn", "ldap", "cloud":
+		return true
+	}
+	return false
+}
+
+// credentialStorage returns the credential storage selected by the config, the same way conjurapi
+// selects it, or the encrypted file. It returns nil when credentials aren't stored.
+func credentialStorage(config conjurapi.Config) (conjurapi.CredentialStorageProvider, error) {
+	machineName := config.ApplianceURL + "/authn"
+	if config.AuthnType != "" && config.AuthnType != "authn" {
+		machineName = fmt.Sprintf("%s/authn-%s/%s", config.ApplianceURL, config.AuthnType, config.ServiceID)
+	}
+
+	switch credentialStorageType(config) {
+	case conjurapi.CredentialStorageFile:
+		return storage.NewNetrcStorageProvider(config.NetRCPath, machineName)
+	case conjurapi.CredentialStorageKeyring:
+		if !storage.IsKeyringAvailable() {
+			return nil, fmt.Errorf("Keyring is not available")
+		}
+		if config.KeychainNamespace != "" {
+			machineName = fmt.Sprintf("%s:%s", machineName, config.KeychainNamespace)
+		}
+		return storage.NewKeyringStorageProvider(machineName), nil
+	case CredentialStorageEncryptedFile:
+		if config.KeychainNamespace != "" {
+			machineName = fmt.Sprintf("%s:%s", machineName, config.KeychainNamespace)
+		}
+		return newEncryptedFileStorage(machineName)
+	case conjurapi.CredentialStorageNone:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("Unknown credential storage type")
+	}
+}
+
+func credentialStorageType(config conjurapi.Config) string {
+	if config.CredentialStorage != "" {
+		return config.CredentialStorage
+	}
+	if storage.IsKeyringAvailable() {
+		return conjurapi.CredentialStorageKeyring
+	}
+	return conjurapi.CredentialStorageFile
+}
+
+// identityConfig returns the config whose credential storage is the slot of the given identity
+func identityConfig(config conjurapi.Config, identity string) (conjurapi.Config, error) {
+	// Identities contain slashes, which neither keychain namespaces nor file names may contain
+	slot := url.QueryEscape(identity)
+
+	switch credentialStorageType(config) {
+	case conjurapi.CredentialStorageKeyring, CredentialStorageEncryptedFile:
+		if config.KeychainNamespace != "" {
+			slot = config.KeychainNamespace + "." + slot
+		}
+		config.KeychainNamespace = slot
+		config.SetKeychainNamespaceResolved(true)
+	case conjurapi.CredentialStorageFile:
+		netrcPath := config.NetRCPath
+		if netrcPath == "" {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return config, err
+			}
+			netrcPath = filepath.Join(home, ".netrc")
+		}
+		dir := filepath.Join(filepath.Dir(netrcPath), identitiesDir)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return config, err
+		}
+		config.NetRCPath = filepath.Join(dir, slot)
+	case conjurapi.CredentialStorageNone:
+		return config, errors.New("Cached identities are not available when credential storage is disabled")
+	}
+	return config, nil
+}
+
+// readIdentity returns the identity and API key cached in the credential storage of the config, or
+// an empty identity if there is none
+func readIdentity(config conjurapi.Config) (string, string, error) {
+	provider, err := credentialStorage(config)
+	if err != nil || provider == nil {
+		return "", "", err
+	}
+	login, apiKey, err := provider.ReadCredentials()
+	if err != nil || login == storage.OidcStorageMarker || apiKey == "" {
+		// Nothing is cached, or only an access token that doesn't name the identity
+		return "", "", nil
+	}
+	return login, apiKey, nil
+}
+
+func storeIdentity(config conjurapi.Config, login string, apiKey string) error {
+	provider, err := credentialStorage(config)
+	if err != nil || provider == nil {
+		return err
+	}
+	return provider.StoreCredentials(login, apiKey)
+}
+
+// ActiveIdentity returns the identity that is logged in, or an empty string if there is none
+func ActiveIdentity(config conjurapi.Config) (string, error) {
+	if !supportsIdentities(config) {
+		return "", nil
+	}
+	identity, _, err := readIdentity(config)
+	return identity, err
+}
+
+// CredentialsSource describes where the credentials to authenticate with are taken from, or returns
+// an empty string when there are none and they must be entered with 'conjur login'. Nothing is
+// prompted for, except the passphrase of the encrypted file.
+func CredentialsSource(config conjurapi.Config, cliConfig CLIConfig) (string, error) {
+	switch {
+	case authnTokenInEnvironment():
+		return "an access token provided by the environment", nil
+	case loginPairInEnvironment():
+		return "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY", nil
+	}
+
+	switch config.AuthnType {
+	case "jwt":
+		switch {
+		case cliConfig.JWTSource != "":
+			return fmt.Sprintf("the JWT source %s", cliConfig.JWTSource), nil
+		case config.JWTFilePath != "":
+			return fmt.Sprintf("the JWT in %s", config.JWTFilePath), nil
+		case config.JWTContent != "":
+			return "CONJUR_AUTHN_JWT_TOKEN", nil
+		}
+		return "", nil
+	case "iam":
+		return "the AWS credentials of the environment", nil
+	case "azure", "gcp":
+		return "the instance metadata service", nil
+	case "cert":
+		return "the client certificate", nil
+	}
+
+	provider, err := credentialStorage(config)
+	if err != nil || provider == nil {
+		return "", err
+	}
+	login, apiKey, err := provider.ReadCredentials()
+	if err != nil || apiKey == "" {
+		// Nothing is cached
+		return "", nil
+	}
+	if login == storage.OidcStorageMarker {
+		return fmt.Sprintf("an access token cached in the %s credential storage", credentialStorageType(config)), nil
+	}
+	return fmt.Sprintf("the credentials of %s cached in the %s credential storage", login, credentialStorageType(config)), nil
+}
+
+// SaveActiveIdentity caches the credentials of the logged in identity under its own name, and
+// records it in the identities of .conjurrc. This allows logging in as another identity without
+// losing them, and switching back with UseIdentity.
+func SaveActiveIdentity(config conjurapi.Config) error {
+	if !supportsIdentities(config) {
+		return nil
+	}
+	identity, apiKey, err := readIdentity(config)
+	if err != nil || identity == "" {
+		return err
+	}
+
+	slotConfig, err := identityConfig(config, identity)
+	if err != nil {
+		return err
+	}
+	if err = storeIdentity(slotConfig, identity, apiKey); err != nil {
+		return err
+	}
+
+	return UpdateCLIConfig(func(cliConfig *CLIConfig) {
+		if !slices.Contains(cliConfig.Identities, identity) {
+			cliConfig.Identities = append(cliConfig.Identities, identity)
+		}
+	})
+}
+
+// UseIdentity makes the given cached identity the logged in identity
+func UseIdentity(config conjurapi.Config, identity string) error {
+	if !supportsIdentities(config) {
+		return errIdentitiesNotSupported
+	}
+	if err := SaveActiveIdentity(config); err != nil {
+		return err
+	}
+
+	slotConfig, err := identityConfig(config, identity)
+	if err != nil {
+		return err
+	}
+	login, apiKey, err := readIdentity(slotConfig)
+	if err != nil {
+		return err
+	}
+	if login == "" {
+		return noCachedIdentityError(identity)
+	}
+	return storeIdentity(config, login, apiKey)
+}
+
+// ApplyIdentity returns the config to authenticate as the given cached identity, without changing
+// the logged in identity. The config is returned as is if the identity is empty or logged in.
+func ApplyIdentity(config conjurapi.Config, identity string) (conjurapi.Config, error) {
+	if identity == "" {
+		return config, nil
+	}
+	if !supportsIdentities(config) {
+		return config, errIdentitiesNotSupported
+	}
+
+	active, _, err := readIdentity(config)
+	if err != nil || active == identity {
+		return config, err
+	}
+
+	slotConfig, err := identityConfig(config, identity)
+	if err != nil {
+		return config, err
+	}
+	login, _, err := readIdentity(slotConfig)
+	if err != nil {
+		return config, err
+	}
+	if login == "" {
+		return config, noCachedIdentityError(identity)
+	}
+	return slotConfig, nil
+}
+
+// PurgeIdentity deletes the cached credentials of the given identity, or of the logged in identity
+// if it is empty, and removes it from the identities of .conjurrc
+func PurgeIdentity(config conjurapi.Config, identity string) error {
+	if !supportsIdentities(config) {
+		return purgeCredentials(config)
+	}
+
+	active, _, err := readIdentity(config)
+	if err != nil {
+		return err
+	}
+	if identity == "" || identity == active {
+		if err = purgeCredentials(config); err != nil {
+			return err
+		}
+		identity = active
+	}
+	if identity == "" {
+		return nil
+	}
+	if err = purgeAccessToken(config, identity); err != nil {
+		return err
+	}
+
+	slotConfig, err := identityConfig(config, identity)
+	if err != nil {
+		return err
+	}
+	if err = purgeCredentials(slotConfig); err != nil {
+		return err
+	}
+
+	cliConfig, err := loadCLIConfigFile()
+	if err != nil || !slices.Contains(cliConfig.Identities, identity) {
+		return err
+	}
+	return UpdateCLIConfig(func(cliConfig *CLIConfig) {
+		cliConfig.Identities = slices.DeleteFunc(cliConfig.Identities, func(cached string) bool {
+			return cached == identity
+		})
+	})
+}
+
+func purgeCredentials(config conjurapi.Config) error {
+	provider, err := credentialStorage(config)
+	if err != nil || provider == nil {
+		return err
+	}
+	return provider.PurgeCredentials()
+}
+
+func noCachedIdentityError(identity string) error {
+	return fmt.Errorf("No credentials are cached for %s. Run 'conjur login -i %s' to cache them", identity, identity)
+}
+
+// GetIdentityFlag returns the identity passed with the global --as flag, if any
+func GetIdentityFlag(cmd *cobra.Command) string {
+	flag := cmd.Flags().Lookup("as")
+	if flag == nil {
+		return ""
+	}
+	return flag.Value.String()
+}
@@ -0,0 +1,158 @@
+package clients
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/stretchr/testify/assert"
+)
+
+// testIdentitiesConfig returns a config storing credentials in a temporary .netrc file, with a
+// temporary .conjurrc holding a setting that must be preserved
+func testIdentitiesConfig(t *testing.T) conjurapi.Config {
+	dir := t.TempDir()
+	conjurrc := filepath.Join(dir, ".conjurrc")
+	assert.NoError(t, os.WriteFile(conjurrc, []byte("account: dev\n"), 0600))
+	t.Setenv("CONJURRC", conjurrc)
+
+	return conjurapi.Config{
+		Account:           "dev",
+		ApplianceURL:      "https://conjur",
+		CredentialStorage: conjurapi.CredentialStorageFile,
+		NetRCPath:         filepath.Join(dir, ".netrc"),
+	}
+}
+
+func loginAs(t *testing.T, config conjurapi.Config, login string, apiKey string) {
+	assert.NoError(t, SaveActiveIdentity(config))
+	assert.NoError(t, storeIdentity(config, login, apiKey))
+	assert.NoError(t, SaveActiveIdentity(config))
+}
+
+func TestIdentities(t *testing.T) {
+	t.Run("keeps the previous identity on login", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		loginAs(t, config, "alice", "alice-api-key")
+		loginAs(t, config, "host/app/web-01", "host-api-key")
+
+		active, err := ActiveIdentity(config)
+		assert.NoError(t, err)
+		assert.Equal(t, "host/app/web-01", active)
+
+		cliConfig, err := LoadCLIConfig()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"alice", "host/app/web-01"}, cliConfig.Identities)
+
+		data, err := os.ReadFile(os.Getenv("CONJURRC"))
+		assert.NoError(t, err)
+		assert.Contains(t, string(data), "account: dev\n")
+	})
+
+	t.Run("switches to a cached identity", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		loginAs(t, config, "alice", "alice-api-key")
+		loginAs(t, config, "host/app/web-01", "host-api-key")
+
+		assert.NoError(t, UseIdentity(config, "alice"))
+		login, apiKey, err := readIdentity(config)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", login)
+		assert.Equal(t, "alice-api-key", apiKey)
+
+		err = UseIdentity(config, "bob")
+		assert.EqualError(t, err, "No credentials are cached for bob. Run 'conjur login -i bob' to cache them")
+	})
+
+	t.Run("applies a cached identity without switching", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		loginAs(t, config, "alice", "alice-api-key")
+		loginAs(t, config, "host/app/web-01", "host-api-key")
+
+		asConfig, err := ApplyIdentity(config, "alice")
+		assert.NoError(t, err)
+		login, apiKey, err := readIdentity(asConfig)
+		assert.NoError(t, err)
+		assert.Equal(t, "alice", login)
+		assert.Equal(t, "alice-api-key", apiKey)
+
+		active, err := ActiveIdentity(config)
+		assert.NoError(t, err)
+		assert.Equal(t, "host/app/web-01", active)
+
+		asConfig, err = ApplyIdentity(config, "host/app/web-01")
+		assert.NoError(t, err)
+		assert.Equal(t, config, asConfig)
+
+		_, err = ApplyIdentity(config, "bob")
+		assert.Error(t, err)
+	})
+
+	t.Run("purges an identity", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		loginAs(t, config, "alice", "alice-api-key")
+		loginAs(t, config, "host/app/web-01", "host-api-key")
+
+		assert.NoError(t, PurgeIdentity(config, "alice"))
+		_, err := ApplyIdentity(config, "alice")
+		assert.Error(t, err)
+
+		assert.NoError(t, PurgeIdentity(config, ""))
+		active, err := ActiveIdentity(config)
+		assert.NoError(t, err)
+		assert.Empty(t, active)
+
+		cliConfig, err := LoadCLIConfig()
+		assert.NoError(t, err)
+		assert.Empty(t, cliConfig.Identities)
+	})
+
+	t.Run("is not supported with other authn types", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		config.AuthnType = "oidc"
+
+		_, err := ApplyIdentity(config, "alice")
+		assert.Equal(t, errIdentitiesNotSupported, err)
+	})
+}
+
+func TestCredentialsSource(t *testing.T) {
+	for _, name := range []string{"CONJUR_AUTHN_TOKEN", "CONJUR_AUTHN_TOKEN_FILE", "CONJUR_AUTHN_LOGIN", "CONJUR_AUTHN_API_KEY"} {
+		t.Setenv(name, "")
+	}
+
+	t.Run("without cached credentials", func(t *testing.T) {
+		source, err := CredentialsSource(testIdentitiesConfig(t), CLIConfig{})
+		assert.NoError(t, err)
+		assert.Empty(t, source)
+	})
+
+	t.Run("with cached credentials", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		loginAs(t, config, "alice", "alice-api-key")
+		source, err := CredentialsSource(config, CLIConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, "the credentials of alice cached in the file credential storage", source)
+	})
+
+	t.Run("with credentials in the environment", func(t *testing.T) {
+		t.Setenv("CONJUR_AUTHN_LOGIN", "alice")
+		t.Setenv("CONJUR_AUTHN_API_KEY", "alice-api-key")
+		source, err := CredentialsSource(testIdentitiesConfig(t), CLIConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY", source)
+	})
+
+	t.Run("with a JWT source", func(t *testing.T) {
+		config := testIdentitiesConfig(t)
+		config.AuthnType = "jwt"
+		source, err := CredentialsSource(config, CLIConfig{JWTSource: "env:MY_JWT"})
+		assert.NoError(t, err)
+		assert.Equal(t, "the JWT source env:MY_JWT", source)
+
+		source, err = CredentialsSource(config, CLIConfig{})
+		assert.NoError(t, err)
+		assert.Empty(t, source)
+	})
+}
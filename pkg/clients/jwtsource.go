@@ -0,0 +1,198 @@
+package clients
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+)
+
+// k8sServiceAccountTokenPath is the default path of the Kubernetes service account token
+const k8sServiceAccountTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// jwtExpiryLeeway is how long before its expiry a cached JWT is considered stale
+const jwtExpiryLeeway = 30 * time.Second
+
+// JWTSource obtains a JWT to authenticate with authn-jwt
+type JWTSource interface {
+	JWT() (string, error)
+}
+
+type fileJWTSource struct {
+	path string
+}
+
+func (s fileJWTSource) JWT() (string, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+type envJWTSource struct {
+	name string
+}
+
+func (s envJWTSource) JWT() (string, error) {
+	jwt := strings.TrimSpace(os.Getenv(s.name))
+	if jwt == "" {
+		return "", fmt.Errorf("environment variable %s is not set", s.name)
+	}
+	return jwt, nil
+}
+
+type execJWTSource struct {
+	command []string
+}
+
+func (s execJWTSource) JWT() (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(s.command[0], s.command[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s failed: %w: %s", s.command[0], err, msg)
+		}
+		return "", fmt.Errorf("%s failed: %w", s.command[0], err)
+	}
+	jwt := strings.TrimSpace(stdout.String())
+	if jwt == "" {
+		return "", fmt.Errorf("%s did not print a JWT", s.command[0])
+	}
+	return jwt, nil
+}
+
+// ParseJWTSource parses a JWT source specification, which is one of:
+//
+//   - file:<path> reads the JWT from a file
+//   - env:<name> reads the JWT from an environment variable
+//   - exec:<command> runs a command and reads the JWT from its output. The arguments are split on whitespace.
+//   - k8s[:<path>] reads a Kubernetes projected service account token, by default from the standard path
+//
+// The file and k8s sources are re-read whenever a new JWT is needed, so rotated tokens are picked up.
+func ParseJWTSource(spec string) (JWTSource, error) {
+	kind, value, _ := strings.Cut(spec, ":")
+	value = strings.TrimSpace(value)
+
+	switch kind {
+	case "file":
+		if value == "" {
+			return nil, errors.New("JWT source 'file' requires a path, e.g. file:/path/to/token")
+		}
+		return fileJWTSource{path: value}, nil
+	case "env":
+		if value == "" {
+			return nil, errors.New("JWT source 'env' requires a variable name, e.g. env:CI_JOB_JWT")
+		}
+		return envJWTSource{name: value}, nil
+	case "exec":
+		command := strings.Fields(value)
+		if len(command) == 0 {
+			return nil, errors.New("JWT source 'exec' requires a command, e.g. exec:gcloud auth print-identity-token")
+		}
+		return execJWTSource{command: command}, nil
+	case "k8s":
+		if value == "" {
+			value = k8sServiceAccountTokenPath
+		}
+		return fileJWTSource{path: value}, nil
+	default:
+		return nil, fmt.Errorf("invalid JWT source %q, expected one of file:<path>, env:<name>, exec:<command> or k8s[:<path>]", spec)
+	}
+}
+
+// cachedJWTSource caches the JWT obtained from a source until shortly before it expires. JWTs without
+// an expiry aren't cached.
+type cachedJWTSource struct {
+	source    JWTSource
+	now       func() time.Time
+	mutex     sync.Mutex
+	jwt       string
+	expiresAt time.Time
+}
+
+func newCachedJWTSource(source JWTSource) *cachedJWTSource {
+	return &cachedJWTSource{source: source, now: time.Now}
+}
+
+func (s *cachedJWTSource) JWT() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.jwt != "" && s.now().Add(jwtExpiryLeeway).Before(s.expiresAt) {
+		return s.jwt, nil
+	}
+
+	jwt, err := s.source.JWT()
+	if err != nil {
+		return "", err
+	}
+
+	s.jwt, s.expiresAt = jwt, time.Time{}
+	if token, err := utils.ParseJWT(jwt); err == nil {
+		if exp, ok := token.TimeClaim("exp"); ok {
+			s.expiresAt = exp
+		}
+	}
+	return jwt, nil
+}
+
+// jwtSourceAuthenticator authenticates using a JWT from a JWTSource, so that a fresh JWT is obtained
+// whenever the Conjur access token is refreshed
+type jwtSourceAuthenticator struct {
+	source       JWTSource
+	authenticate func(jwt string) ([]byte, error)
+}
+
+func (a *jwtSourceAuthenticator) RefreshToken() ([]byte, error) {
+	jwt, err := a.source.JWT()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to obtain JWT: %w", err)
+	}
+	return a.authenticate(jwt)
+}
+
+func (a *jwtSourceAuthenticator) NeedsTokenRefresh() bool {
+	return false
+}
+
+// NewJWTClient creates a client that authenticates with authn-jwt. When a JWT source is configured it's
+// used to obtain the JWT, otherwise the JWT is read from the config as usual.
+func NewJWTClient(config conjurapi.Config, cliConfig CLIConfig) (*conjurapi.Client, error) {
+	if cliConfig.JWTSource == "" {
+		return conjurapi.NewClientFromJwt(APIConfig(config))
+	}
+
+	parsedSource, err := ParseJWTSource(cliConfig.JWTSource)
+	if err != nil {
+		return nil, err
+	}
+	source := newCachedJWTSource(parsedSource)
+
+	// conjurapi requires a JWT in the config. Obtaining it here also fills the cache for the first authentication.
+	config.JWTContent, err = source.JWT()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to obtain JWT: %w", err)
+	}
+
+	client, err := conjurapi.NewClient(APIConfig(config))
+	if err != nil {
+		return nil, err
+	}
+	client.SetAuthenticator(&jwtSourceAuthenticator{
+		source: source,
+		authenticate: func(jwt string) ([]byte, error) {
+			return client.JWTAuthenticate(jwt, config.JWTHostID)
+		},
+	})
+	return client, nil
+}
@@ -0,0 +1,148 @@
+package clients
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testJWTWithExpiry(exp time.Time) string {
+	payload := fmt.Sprintf(`{"sub":"test","exp":%d}`, exp.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"RS256"}`)) + "." +
+		base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".c2lnbmF0dXJl"
+}
+
+type countingJWTSource struct {
+	jwts  []string
+	calls int
+}
+
+func (s *countingJWTSource) JWT() (string, error) {
+	jwt := s.jwts[s.calls%len(s.jwts)]
+	s.calls++
+	return jwt, nil
+}
+
+func TestParseJWTSource(t *testing.T) {
+	testCases := []struct {
+		name          string
+		spec          string
+		expected      JWTSource
+		expectedError string
+	}{
+		{name: "file", spec: "file:/tmp/token", expected: fileJWTSource{path: "/tmp/token"}},
+		{name: "env", spec: "env:CI_JOB_JWT", expected: envJWTSource{name: "CI_JOB_JWT"}},
+		{name: "exec", spec: "exec:gcloud auth  print-identity-token", expected: execJWTSource{command: []string{"gcloud", "auth", "print-identity-token"}}},
+		{name: "k8s default path", spec: "k8s", expected: fileJWTSource{path: k8sServiceAccountTokenPath}},
+		{name: "k8s projected token", spec: "k8s:/var/run/secrets/tokens/conjur", expected: fileJWTSource{path: "/var/run/secrets/tokens/conjur"}},
+		{name: "file without path", spec: "file:", expectedError: "JWT source 'file' requires a path, e.g. file:/path/to/token"},
+		{name: "exec without command", spec: "exec: ", expectedError: "JWT source 'exec' requires a command, e.g. exec:gcloud auth print-identity-token"},
+		{name: "unknown kind", spec: "vault:token", expectedError: "invalid JWT source \"vault:token\", expected one of file:<path>, env:<name>, exec:<command> or k8s[:<path>]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source, err := ParseJWTSource(tc.spec)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, source)
+		})
+	}
+}
+
+func TestJWTSources(t *testing.T) {
+	t.Run("file source re-reads the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "token")
+		source := fileJWTSource{path: path}
+
+		assert.NoError(t, os.WriteFile(path, []byte("first\n"), 0600))
+		jwt, err := source.JWT()
+		assert.NoError(t, err)
+		assert.Equal(t, "first", jwt)
+
+		assert.NoError(t, os.WriteFile(path, []byte("second\n"), 0600))
+		jwt, err = source.JWT()
+		assert.NoError(t, err)
+		assert.Equal(t, "second", jwt)
+	})
+
+	t.Run("env source", func(t *testing.T) {
+		t.Setenv("TEST_CONJUR_JWT", " token \n")
+		jwt, err := envJWTSource{name: "TEST_CONJUR_JWT"}.JWT()
+		assert.NoError(t, err)
+		assert.Equal(t, "token", jwt)
+
+		_, err = envJWTSource{name: "TEST_CONJUR_JWT_MISSING"}.JWT()
+		assert.EqualError(t, err, "environment variable TEST_CONJUR_JWT_MISSING is not set")
+	})
+
+	t.Run("exec source", func(t *testing.T) {
+		jwt, err := execJWTSource{command: []string{"echo", "token"}}.JWT()
+		assert.NoError(t, err)
+		assert.Equal(t, "token", jwt)
+
+		_, err = execJWTSource{command: []string{"sh", "-c", "echo denied >&2; exit 3"}}.JWT()
+		assert.EqualError(t, err, "sh failed: exit status 3: denied")
+
+		_, err = execJWTSource{command: []string{"true"}}.JWT()
+		assert.EqualError(t, err, "true did not print a JWT")
+	})
+}
+
+func TestCachedJWTSource(t *testing.T) {
+	now := time.Now()
+
+	t.Run("reuses the JWT until shortly before it expires", func(t *testing.T) {
+		source := &countingJWTSource{jwts: []string{testJWTWithExpiry(now.Add(5 * time.Minute))}}
+		cached := newCachedJWTSource(source)
+		cached.now = func() time.Time { return now }
+
+		_, err := cached.JWT()
+		assert.NoError(t, err)
+		_, err = cached.JWT()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, source.calls)
+
+		cached.now = func() time.Time { return now.Add(5*time.Minute - jwtExpiryLeeway) }
+		_, err = cached.JWT()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, source.calls)
+	})
+
+	t.Run("doesn't cache JWTs without an expiry", func(t *testing.T) {
+		source := &countingJWTSource{jwts: []string{"not-a-jwt"}}
+		cached := newCachedJWTSource(source)
+
+		cached.JWT()
+		cached.JWT()
+		assert.Equal(t, 2, source.calls)
+	})
+}
+
+func TestJWTSourceAuthenticator(t *testing.T) {
+	source := &countingJWTSource{jwts: []string{"first", "second"}}
+	var authenticated []string
+	authenticator := &jwtSourceAuthenticator{
+		source: source,
+		authenticate: func(jwt string) ([]byte, error) {
+			authenticated = append(authenticated, jwt)
+			return []byte("token"), nil
+		},
+	}
+
+	token, err := authenticator.RefreshToken()
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("token"), token)
+	authenticator.RefreshToken()
+
+	assert.Equal(t, []string{"first", "second"}, authenticated)
+	assert.False(t, authenticator.NeedsTokenRefresh())
+}
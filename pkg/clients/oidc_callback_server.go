@@ -4,7 +4,9 @@ import (
 	"context"
 	"crypto/rand"
 	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
@@ -58,7 +60,18 @@ func (h *callbackEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.shutdownSignal <- struct{}{}
 }
 
-func handleOpenIDFlow(authEndpointURL string, generateStateFn func() string, openBrowserFn func(string) error) (string, error) {
+// handleOpenIDFlow runs the OIDC authorization code flow and returns the authorization code. It starts a local
+// server to receive the callback and opens a browser to the authorization URL. When the server can't be started,
+// the browser can't be opened or no openBrowserFn is given, it falls back to asking the user to paste back the
+// URL they were redirected to using pasteBackFn. A nil pasteBackFn disables the fallback. The reason for falling
+// back is written to out.
+func handleOpenIDFlow(
+	authEndpointURL string,
+	generateStateFn func() string,
+	openBrowserFn func(string) error,
+	pasteBackFn func(string) (string, error),
+	out io.Writer,
+) (string, error) {
 	callbackEndpoint := &callbackEndpoint{}
 	callbackEndpoint.state = generateStateFn()
 	callbackEndpoint.shutdownSignal = make(chan struct{})
@@ -69,7 +82,15 @@ func handleOpenIDFlow(authEndpointURL string, generateStateFn func() string, ope
 	}
 
 	queryVals := authURL.Query()
-	host, port, err := parseAndValidateRedirectUri(queryVals.Get("redirect_uri"))
+	redirectURI := queryVals.Get("redirect_uri")
+	queryVals.Set("state", callbackEndpoint.state)
+	authURL.RawQuery = queryVals.Encode()
+
+	if openBrowserFn == nil && pasteBackFn != nil {
+		return manualOpenIDFlow(authURL.String(), callbackEndpoint.state, pasteBackFn)
+	}
+
+	host, port, err := parseAndValidateRedirectUri(redirectURI)
 	if err != nil {
 		return "", err
 	}
@@ -93,16 +114,17 @@ func handleOpenIDFlow(authEndpointURL string, generateStateFn func() string, ope
 		server.Addr = fmt.Sprintf("%s:%d", host, port)
 	}
 
-	queryVals.Set("state", callbackEndpoint.state)
-	authURL.RawQuery = queryVals.Encode()
-
 	// Start the local server. This will fail if the server fails to start.
 	errorSignal := make(chan error)
 	listener, err := net.Listen("tcp", server.Addr)
 
-	// This will stop execution if the port is already in use
+	// This will stop execution if the port is already in use, unless the user can paste back the redirect URL
 	if err != nil {
-		return "", err
+		if pasteBackFn == nil {
+			return "", err
+		}
+		fmt.Fprintf(out, "Unable to start the local callback server: %s\n", err)
+		return manualOpenIDFlow(authURL.String(), callbackEndpoint.state, pasteBackFn)
 	}
 
 	go func() {
@@ -115,7 +137,11 @@ func handleOpenIDFlow(authEndpointURL string, generateStateFn func() string, ope
 	// Open the browser to the OIDC provider
 	err = openBrowserFn(authURL.String())
 	if err != nil {
-		return "", err
+		if pasteBackFn == nil {
+			return "", err
+		}
+		fmt.Fprintf(out, "Unable to open a browser: %s\n", err)
+		return manualOpenIDFlow(authURL.String(), callbackEndpoint.state, pasteBackFn)
 	}
 
 	// Set a timeout and shut down the server if we don't get a response in time
@@ -132,6 +158,45 @@ func handleOpenIDFlow(authEndpointURL string, generateStateFn func() string, ope
 	}
 }
 
+// manualOpenIDFlow shows the authorization URL to the user and extracts the authorization code from the full
+// URL they paste back after being redirected
+func manualOpenIDFlow(authURL string, state string, pasteBackFn func(string) (string, error)) (string, error) {
+	input, err := pasteBackFn(authURL)
+	if err != nil {
+		return "", err
+	}
+	return parseOidcRedirect(input, state)
+}
+
+// parseOidcRedirect extracts the authorization code from a redirect URL, validating its state parameter the same
+// way the callback server does
+func parseOidcRedirect(input string, state string) (string, error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return "", errors.New("no redirect URL was provided")
+	}
+
+	redirectURL, err := url.Parse(input)
+	if err != nil {
+		return "", fmt.Errorf("Unable to parse the redirect URL: %s", err)
+	}
+	query := redirectURL.Query()
+	if oidcErr := query.Get("error"); oidcErr != "" {
+		if description := query.Get("error_description"); description != "" {
+			return "", fmt.Errorf("OIDC provider returned an error: %s: %s", oidcErr, description)
+		}
+		return "", fmt.Errorf("OIDC provider returned an error: %s", oidcErr)
+	}
+	if query.Get("state") != state {
+		return "", errors.New("the state parameter of the redirect URL does not match the login request")
+	}
+	code := query.Get("code")
+	if code == "" {
+		return "", errors.New("the redirect URL does not contain an authorization code")
+	}
+	return code, nil
+}
+
 func generateState() string {
 	b := make([]byte, 16)
 	rand.Read(b)
@@ -1,6 +1,7 @@
 package clients
 
 import (
+	"bytes"
 	"encoding/base64"
 	"fmt"
 	"io"
@@ -84,7 +85,7 @@ func TestHandleOidcFlow(t *testing.T) {
 			mockOpenBrowser := func(url string) error { return nil }
 			redirectURI := "https://example.com?redirect_uri=" + url.QueryEscape(tc.redirectURI)
 			go func() {
-				code, serverError = handleOpenIDFlow(redirectURI, mockGenerateState, mockOpenBrowser)
+				code, serverError = handleOpenIDFlow(redirectURI, mockGenerateState, mockOpenBrowser, nil, io.Discard)
 			}()
 			// Wait for the server to start up asynchronously
 			time.Sleep(200 * time.Millisecond)
@@ -120,7 +121,7 @@ func TestHandleOidcFlow(t *testing.T) {
 		port := fmt.Sprint(randomPort())
 		redirectURI := "https://example.com?redirect_uri=http%3A%2F%2F127.0.0.1%3A" + port + "%2Fcallback"
 		go func() {
-			code, serverError = handleOpenIDFlow(redirectURI, mockGenerateState, mockOpenBrowser)
+			code, serverError = handleOpenIDFlow(redirectURI, mockGenerateState, mockOpenBrowser, nil, io.Discard)
 		}()
 		// Wait for the server to start up asynchronously
 		time.Sleep(1 * time.Second)
@@ -164,7 +165,7 @@ func TestHandleOidcFlow(t *testing.T) {
 		defer listener.Close()
 
 		// Now try to start the local server
-		_, err = handleOpenIDFlow("https://example.com?redirect_uri=http%3A%2F%2F127.0.0.1%3A"+port+"%2Fcallback", mockGenerateState, mockOpenBrowser)
+		_, err = handleOpenIDFlow("https://example.com?redirect_uri=http%3A%2F%2F127.0.0.1%3A"+port+"%2Fcallback", mockGenerateState, mockOpenBrowser, nil, io.Discard)
 
 		assert.ErrorContains(t, err, "address already in use")
 		assert.False(t, openBrowserCalled)
@@ -189,7 +190,7 @@ func TestHandleOidcFlow(t *testing.T) {
 		port := fmt.Sprint(randomPort())
 		redirectURI := "https://example.com?redirect_uri=http%3A%2F%2F127.0.0.1%3A" + port + "%2Fcallback"
 		go func() {
-			handleOpenIDFlow(redirectURI, mockGenerateState, mockOpenBrowser)
+			handleOpenIDFlow(redirectURI, mockGenerateState, mockOpenBrowser, nil, io.Discard)
 		}()
 
 		// Wait for the server to start up asynchronously
@@ -202,6 +203,131 @@ func TestHandleOidcFlow(t *testing.T) {
 	})
 }
 
+func TestHandleOidcFlowPasteBack(t *testing.T) {
+	authEndpointURL := "https://example.com/auth?client_id=conjur&redirect_uri=http%3A%2F%2F127.0.0.1%3A8888%2Fcallback"
+	mockPasteBack := func(pastedAuthURL *string) func(string) (string, error) {
+		return func(authURL string) (string, error) {
+			*pastedAuthURL = authURL
+			return "http://127.0.0.1:8888/callback?code=1234&state=test-state", nil
+		}
+	}
+
+	t.Run("Asks for the redirect URL without a browser", func(t *testing.T) {
+		var pastedAuthURL string
+		code, err := handleOpenIDFlow(authEndpointURL, mockGenerateState, nil, mockPasteBack(&pastedAuthURL), io.Discard)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1234", code)
+		assert.Equal(t, "https://example.com/auth?client_id=conjur&redirect_uri=http%3A%2F%2F127.0.0.1%3A8888%2Fcallback&state=test-state", pastedAuthURL)
+	})
+
+	t.Run("Falls back to pasting the redirect URL if server can't start", func(t *testing.T) {
+		port := fmt.Sprint(randomPort())
+		listener, err := net.Listen("tcp", "127.0.0.1:"+port)
+		assert.NoError(t, err)
+		defer listener.Close()
+
+		openBrowserCalled := false
+		mockOpenBrowser := func(url string) error {
+			openBrowserCalled = true
+			return nil
+		}
+
+		var pastedAuthURL string
+		var out bytes.Buffer
+		code, err := handleOpenIDFlow("https://example.com?redirect_uri=http%3A%2F%2F127.0.0.1%3A"+port+"%2Fcallback",
+			mockGenerateState, mockOpenBrowser, mockPasteBack(&pastedAuthURL), &out)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1234", code)
+		assert.Contains(t, pastedAuthURL, "state=test-state")
+		assert.False(t, openBrowserCalled)
+		assert.Contains(t, out.String(), "Unable to start the local callback server")
+	})
+
+	t.Run("Falls back to pasting the redirect URL if browser can't be opened", func(t *testing.T) {
+		port := fmt.Sprint(randomPort())
+		mockOpenBrowser := func(url string) error {
+			return fmt.Errorf("Error opening browser")
+		}
+
+		var pastedAuthURL string
+		var out bytes.Buffer
+		code, err := handleOpenIDFlow("https://example.com?redirect_uri=http%3A%2F%2F127.0.0.1%3A"+port+"%2Fcallback",
+			mockGenerateState, mockOpenBrowser, mockPasteBack(&pastedAuthURL), &out)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "1234", code)
+		assert.NotEmpty(t, pastedAuthURL)
+		assert.Equal(t, "Unable to open a browser: Error opening browser\n", out.String())
+		// Ensure server is shut down
+		assert.False(t, isServerRunning("http://127.0.0.1:"+port))
+	})
+}
+
+func TestParseOidcRedirect(t *testing.T) {
+	testCases := []struct {
+		name          string
+		input         string
+		state         string
+		expectedCode  string
+		expectedError string
+	}{
+		{
+			name:         "Returns the code from the redirect URL",
+			input:        " http://127.0.0.1:8888/callback?code=1234&state=test-state\n",
+			expectedCode: "1234",
+		},
+		{
+			name:         "Decodes the state parameter",
+			input:        "http://127.0.0.1:8888/callback?state=a%2Bb%2Fc%3D%3D&code=1234",
+			state:        "a+b/c==",
+			expectedCode: "1234",
+		},
+		{
+			name:          "Fails for the code on its own",
+			input:         "1234",
+			expectedError: "the state parameter of the redirect URL does not match the login request",
+		},
+		{
+			name:          "Fails if the state doesn't match",
+			input:         "http://127.0.0.1:8888/callback?code=1234&state=wrong-state",
+			expectedError: "the state parameter of the redirect URL does not match the login request",
+		},
+		{
+			name:          "Fails if the code is missing",
+			input:         "http://127.0.0.1:8888/callback?state=test-state",
+			expectedError: "the redirect URL does not contain an authorization code",
+		},
+		{
+			name:          "Returns the error from the provider",
+			input:         "http://127.0.0.1:8888/callback?error=access_denied&error_description=User+cancelled&state=test-state",
+			expectedError: "OIDC provider returned an error: access_denied: User cancelled",
+		},
+		{
+			name:          "Fails on empty input",
+			input:         " ",
+			expectedError: "no redirect URL was provided",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			state := tc.state
+			if state == "" {
+				state = "test-state"
+			}
+			code, err := parseOidcRedirect(tc.input, state)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedCode, code)
+		})
+	}
+}
+
 func TestGenerateState(t *testing.T) {
 	state := generateState()
 	// Check that the state is a valid base64 string
@@ -0,0 +1,44 @@
+package clients
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+)
+
+// PinCertificateForClient makes a Conjur client reject the servers whose certificate doesn't have the
+// fingerprint pinned with init --cert-fingerprint, on every TLS handshake. Only the appliance URL can be
+// reached, which is why ValidateConfig rejects follower_urls along with cert_fingerprint.
+func PinCertificateForClient(client ConjurClient, cliConfig CLIConfig) error {
+	if cliConfig.CertFingerprint == "" || client == nil {
+		return nil
+	}
+	fingerprint, err := utils.NormalizeFingerprint(cliConfig.CertFingerprint)
+	if err != nil {
+		return err
+	}
+
+	applianceURL, err := url.Parse(client.GetConfig().ApplianceURL)
+	if err != nil {
+		return err
+	}
+	httpClient := client.GetHttpClient()
+	if httpClient == nil {
+		return errors.New("Unable to enforce the pinned certificate fingerprint")
+	}
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("Unable to enforce the pinned certificate fingerprint")
+	}
+	// An HTTPS proxy is reached with the same TLS configuration, its certificate isn't pinned
+	var proxyHosts []string
+	if transport.Proxy != nil {
+		if proxyURL, err := transport.Proxy(&http.Request{URL: applianceURL}); err == nil && proxyURL != nil {
+			proxyHosts = append(proxyHosts, proxyURL.Hostname())
+		}
+	}
+	utils.PinCertificate(transport, applianceURL.Hostname(), fingerprint, proxyHosts...)
+	return nil
+}
@@ -0,0 +1,55 @@
+package clients
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinCertificateForClient(t *testing.T) {
+	client, _ := conjurapi.NewClientFromKey(conjurapi.Config{Account: "conjur", ApplianceURL: "https://conjur.com"}, authn.LoginPair{Login: "username", APIKey: "password"})
+	pinned := CLIConfig{CertFingerprint: strings.Repeat("ab", 32)}
+
+	t.Run("does nothing without a pinned fingerprint", func(t *testing.T) {
+		transport := &http.Transport{}
+		client.SetHttpClient(&http.Client{Transport: transport})
+		assert.NoError(t, PinCertificateForClient(client, CLIConfig{}))
+		assert.Nil(t, transport.TLSClientConfig)
+	})
+
+	t.Run("verifies the connections", func(t *testing.T) {
+		transport := &http.Transport{}
+		client.SetHttpClient(&http.Client{Transport: transport})
+		assert.NoError(t, PinCertificateForClient(client, pinned))
+		assert.NotNil(t, transport.TLSClientConfig.VerifyConnection)
+	})
+
+	t.Run("fails for an invalid fingerprint", func(t *testing.T) {
+		client.SetHttpClient(&http.Client{Transport: &http.Transport{}})
+		assert.ErrorContains(t, PinCertificateForClient(client, CLIConfig{CertFingerprint: "AB:CD"}), "is not a SHA-256 fingerprint")
+	})
+
+	t.Run("fails when the transport can't be pinned", func(t *testing.T) {
+		client.SetHttpClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return nil, nil
+		})})
+		assert.EqualError(t, PinCertificateForClient(client, pinned), "Unable to enforce the pinned certificate fingerprint")
+	})
+}
+
+func TestValidateConfigCertFingerprint(t *testing.T) {
+	pinned := CLIConfig{CertFingerprint: strings.Repeat("ab", 32)}
+	config := conjurapi.Config{Account: "conjur", ApplianceURL: "https://conjur.example.com"}
+
+	assert.NoError(t, ValidateConfig(config, pinned))
+
+	pinned.FollowerURLs = []string{"https://follower.example.com"}
+	assert.EqualError(t, ValidateConfig(config, pinned), "follower_urls can't be used with cert_fingerprint, which only pins the certificate of the leader")
+
+	saasConfig := conjurapi.Config{Account: "conjur", ApplianceURL: "https://tenant.secretsmgr.cyberark.cloud/api", Environment: conjurapi.EnvironmentSaaS}
+	assert.EqualError(t, ValidateConfig(saasConfig, CLIConfig{CertFingerprint: pinned.CertFingerprint}), "cert_fingerprint is only supported in Secrets Manager Self-Hosted")
+}
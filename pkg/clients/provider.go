@@ -0,0 +1,47 @@
+package clients
+
+import (
+	"context"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+type clientProviderKey struct{}
+
+// ClientProvider authenticates once per invocation of the CLI and hands the same client, and so the
+// same pooled HTTP connections, to every command run with it
+type ClientProvider struct {
+	mu     sync.Mutex
+	client ConjurClient
+}
+
+// WithClientProvider returns a context holding a new ClientProvider for the commands executed with it
+func WithClientProvider(ctx context.Context) context.Context {
+	return context.WithValue(ctx, clientProviderKey{}, &ClientProvider{})
+}
+
+func clientProviderForCommand(cmd *cobra.Command) *ClientProvider {
+	ctx := cmd.Context()
+	if ctx == nil {
+		return nil
+	}
+	provider, _ := ctx.Value(clientProviderKey{}).(*ClientProvider)
+	return provider
+}
+
+// Client returns the authenticated client of the invocation, authenticating on the first call.
+// Failures aren't kept so that a later call can try again.
+func (p *ClientProvider) Client(cmd *cobra.Command) (ConjurClient, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.client == nil {
+		client, err := newAuthenticatedConjurClient(cmd)
+		if err != nil {
+			return nil, err
+		}
+		p.client = client
+	}
+	return p.client, nil
+}
@@ -0,0 +1,64 @@
+package clients
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func testProviderCommand(t *testing.T, ctx context.Context) *cobra.Command {
+	conjurrc := filepath.Join(t.TempDir(), ".conjurrc")
+	assert.NoError(t, os.WriteFile(conjurrc, []byte("account: dev\nappliance_url: https://conjur\n"), 0600))
+	t.Setenv("CONJURRC", conjurrc)
+	t.Setenv("CONJUR_CREDENTIAL_STORAGE", "none")
+	t.Setenv("CONJUR_AUTHN_TOKEN", "")
+	t.Setenv("CONJUR_AUTHN_TOKEN_FILE", "")
+	t.Setenv("CONJUR_AUTHN_LOGIN", "alice")
+	t.Setenv("CONJUR_AUTHN_API_KEY", "alice-api-key")
+
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("debug", true, "Debug logging enabled")
+	cmd.SetContext(ctx)
+	return cmd
+}
+
+func TestClientProvider(t *testing.T) {
+	t.Run("reuses the client of the invocation", func(t *testing.T) {
+		cmd := testProviderCommand(t, WithClientProvider(context.Background()))
+
+		client, err := AuthenticatedConjurClientForCommand(cmd)
+		assert.NoError(t, err)
+		again, err := AuthenticatedConjurClientForCommand(cmd)
+		assert.NoError(t, err)
+		assert.Same(t, client, again)
+	})
+
+	t.Run("creates a client for each command without a provider", func(t *testing.T) {
+		cmd := testProviderCommand(t, context.Background())
+
+		client, err := AuthenticatedConjurClientForCommand(cmd)
+		assert.NoError(t, err)
+		again, err := AuthenticatedConjurClientForCommand(cmd)
+		assert.NoError(t, err)
+		assert.NotSame(t, client, again)
+	})
+
+	t.Run("doesn't keep failures", func(t *testing.T) {
+		cmd := testProviderCommand(t, WithClientProvider(context.Background()))
+		t.Setenv("CONJUR_APPLIANCE_URL", "")
+		t.Setenv("CONJUR_ACCOUNT", "")
+		assert.NoError(t, os.WriteFile(os.Getenv("CONJURRC"), []byte{}, 0600))
+
+		_, err := AuthenticatedConjurClientForCommand(cmd)
+		assert.Error(t, err)
+
+		assert.NoError(t, os.WriteFile(os.Getenv("CONJURRC"), []byte("account: dev\nappliance_url: https://conjur\n"), 0600))
+		client, err := AuthenticatedConjurClientForCommand(cmd)
+		assert.NoError(t, err)
+		assert.NotNil(t, client)
+	})
+}
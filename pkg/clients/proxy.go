@@ -0,0 +1,83 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+)
+
+// IdentityProxy returns the proxy for the Identity tenant of Secrets Manager SaaS, which is
+// identity_proxy when it is set and the proxy of Conjur otherwise
+func IdentityProxy(config conjurapi.Config, cliConfig CLIConfig) string {
+	if cliConfig.IdentityProxy != "" {
+		return cliConfig.IdentityProxy
+	}
+	return config.Proxy
+}
+
+// UseProxyForClient routes the requests of a Conjur client through the proxy of the configuration,
+// except for the hosts matching the NO_PROXY patterns of the environment. The requests to hosts
+// other than the appliance and its followers, such as the Identity tenant, go through
+// identity_proxy when it is set.
+// The environment's proxy applies when neither is set, like without this.
+func UseProxyForClient(client ConjurClient, cliConfig CLIConfig) error {
+	if client == nil {
+		return nil
+	}
+	config := client.GetConfig()
+	if config.Proxy == "" && cliConfig.IdentityProxy == "" {
+		return nil
+	}
+
+	conjurProxy, err := parseProxy("proxy", config.Proxy)
+	if err != nil {
+		return err
+	}
+	identityProxy, err := parseProxy("identity_proxy", IdentityProxy(config, cliConfig))
+	if err != nil {
+		return err
+	}
+	applianceURL, err := url.Parse(config.ApplianceURL)
+	if err != nil {
+		return err
+	}
+	followers, err := followerURLs(cliConfig)
+	if err != nil {
+		return err
+	}
+
+	httpClient := client.GetHttpClient()
+	if httpClient == nil {
+		return errors.New("Unable to use the proxy")
+	}
+	transport, ok := httpClient.Transport.(*http.Transport)
+	if !ok {
+		return errors.New("Unable to use the proxy")
+	}
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		proxyURL := conjurProxy
+		if req.URL.Host != applianceURL.Host && !isFollowerHost(followers, req.URL.Host) {
+			proxyURL = identityProxy
+		}
+		if proxyURL == nil {
+			return http.ProxyFromEnvironment(req)
+		}
+		return utils.ProxyForURL(proxyURL, req.URL)
+	}
+	return nil
+}
+
+func parseProxy(key, proxy string) (*url.URL, error) {
+	if proxy == "" {
+		return nil, nil
+	}
+	proxyURL, err := utils.ParseProxyURL(proxy)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s: %s", key, err)
+	}
+	return proxyURL, nil
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/base64"
 	"fmt"
 	"image/png"
+	"io"
+	"os"
 	"strings"
 
 	"github.com/cyberark/conjur-cli-go/pkg/cmd/style"
@@ -36,12 +38,17 @@ func DisplayQRCode(img string) error {
 		return fmt.Errorf("failed to decode QR Code: %w", err)
 	}
 
-	qrCode, err := qr.New(codes.String(), qr.Highest)
+	return DisplayQRCodeForText(os.Stdout, codes.String())
+}
+
+// DisplayQRCodeForText prints a QR code encoding the given text to out
+func DisplayQRCodeForText(out io.Writer, text string) error {
+	qrCode, err := qr.New(text, qr.Highest)
 	if err != nil {
 		return fmt.Errorf("failed to create QR Code: %w", err)
 	}
 
 	// The CyberArk authenticator app QR scanner requires color is inverted on light backgrounds
-	fmt.Println(qrCode.ToSmallString(!style.HasDarkBackground()))
+	fmt.Fprintln(out, qrCode.ToSmallString(!style.HasDarkBackground()))
 	return nil
 }
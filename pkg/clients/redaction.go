@@ -0,0 +1,38 @@
+package clients
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+)
+
+// rule converts the redaction settings of .conjurrc into a rule applying to every request and response
+func (c RedactConfig) rule() (utils.RedactionRule, error) {
+	rule := utils.RedactionRule{
+		Requests:  true,
+		Responses: true,
+		Headers:   c.Headers,
+		JSONPaths: c.JSONPaths,
+	}
+	for _, pattern := range c.Patterns {
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return rule, fmt.Errorf("Invalid redaction pattern %q: %s", pattern, err)
+		}
+		rule.Patterns = append(rule.Patterns, rx)
+	}
+	return rule, nil
+}
+
+// httpLogRedactor returns the redactor of the HTTP logs, with the built-in rules and those of .conjurrc
+func httpLogRedactor() *utils.Redactor {
+	rules := utils.BuiltinRedactionRules()
+	// The settings were validated with the rest of the configuration
+	if cliConfig, err := LoadCLIConfig(); err == nil {
+		if rule, err := cliConfig.Redact.rule(); err == nil {
+			rules = append(rules, rule)
+		}
+	}
+	return utils.NewRedactor(rules...)
+}
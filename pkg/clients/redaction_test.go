@@ -0,0 +1,68 @@
+package clients
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateConfigRedaction(t *testing.T) {
+	config := conjurapi.Config{Account: "dev", ApplianceURL: "https://conjur"}
+
+	err := ValidateConfig(config, CLIConfig{Redact: RedactConfig{Patterns: []string{`ghp_[A-Za-z0-9]+`}}})
+	assert.NoError(t, err)
+
+	err = ValidateConfig(config, CLIConfig{Redact: RedactConfig{Patterns: []string{`ghp_[`}}})
+	assert.ErrorContains(t, err, `Invalid redaction pattern "ghp_["`)
+}
+
+func TestDebugLoggingRedaction(t *testing.T) {
+	conjurrc := filepath.Join(t.TempDir(), ".conjurrc")
+	assert.NoError(t, os.WriteFile(conjurrc, []byte("redact:\n  headers: [X-Custom-Token]\n"), 0600))
+	t.Setenv("CONJURRC", conjurrc)
+
+	newRequest := func() *http.Request {
+		req, _ := http.NewRequest("POST", "https://conjur/secrets/dev/variable/db/password", bytes.NewBufferString("s3cr3t"))
+		req.Header.Set("X-Custom-Token", "custom-token")
+		return req
+	}
+	logRequest := func(args ...string) string {
+		client, _ := conjurapi.NewClientFromKey(conjurapi.Config{Account: "dev", ApplianceURL: "https://conjur"}, authn.LoginPair{Login: "alice", APIKey: "api-key"})
+		client.SetHttpClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			return &http.Response{StatusCode: 201, Body: io.NopCloser(&bytes.Buffer{}), Request: req}, nil
+		})})
+
+		cmd := &cobra.Command{}
+		cmd.Flags().Bool("debug-unsafe", false, "")
+		cmd.ParseFlags(args)
+		stderr := &bytes.Buffer{}
+		cmd.SetErr(stderr)
+
+		MaybeDebugLoggingForClient(true, cmd, client)
+		client.GetHttpClient().Do(newRequest())
+		return stderr.String()
+	}
+
+	logs := logRequest()
+	assert.NotContains(t, logs, "s3cr3t")
+	assert.NotContains(t, logs, "custom-token")
+
+	logs = logRequest("--debug-unsafe")
+	assert.Contains(t, logs, "Warning: --debug-unsafe")
+	assert.Contains(t, logs, "s3cr3t")
+	assert.Contains(t, logs, "custom-token")
+}
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
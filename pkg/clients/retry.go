@@ -0,0 +1,65 @@
+package clients
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	// DefaultRetries is the default number of retries of requests failing with a transient error
+	DefaultRetries = 3
+	// DefaultRetryMaxWait is the default maximum wait between retries
+	DefaultRetryMaxWait = 30 * time.Second
+)
+
+// GetRetryFlags extracts the --retries and --retry-max-wait flags. Commands without them don't retry.
+func GetRetryFlags(cmd *cobra.Command) (retries int, maxWait time.Duration, err error) {
+	if cmd.Flags().Lookup("retries") == nil {
+		return 0, DefaultRetryMaxWait, nil
+	}
+	retries, err = cmd.Flags().GetInt("retries")
+	if err != nil {
+		return 0, 0, err
+	}
+	maxWait, err = cmd.Flags().GetDuration("retry-max-wait")
+	if err != nil {
+		return 0, 0, err
+	}
+	if retries < 0 {
+		return 0, 0, fmt.Errorf("--retries must not be negative")
+	}
+	if maxWait <= 0 {
+		return 0, 0, fmt.Errorf("--retry-max-wait must be positive")
+	}
+	return retries, maxWait, nil
+}
+
+// RetryTransientErrorsForClient makes a Conjur client retry the requests failing with a transient
+// error, as configured with --retries and --retry-max-wait. The retries are logged with --debug.
+func RetryTransientErrorsForClient(cmd *cobra.Command, client ConjurClient) error {
+	retries, maxWait, err := GetRetryFlags(cmd)
+	if err != nil || retries == 0 || client == nil {
+		return err
+	}
+	httpClient := client.GetHttpClient()
+	if httpClient == nil || utils.IsRetryTransport(httpClient.Transport) {
+		return nil
+	}
+	debug, _ := cmd.Flags().GetBool("debug")
+
+	httpClient.Transport = utils.NewRetryTransport(
+		httpClient.Transport,
+		retries,
+		maxWait,
+		func(message string) {
+			if debug {
+				cmd.PrintErrln(message)
+			}
+		},
+	)
+	return nil
+}
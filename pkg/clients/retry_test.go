@@ -0,0 +1,56 @@
+package clients
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func newRetryTestCommand(args ...string) *cobra.Command {
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("debug", false, "Debug logging enabled")
+	cmd.Flags().Int("retries", DefaultRetries, "")
+	cmd.Flags().Duration("retry-max-wait", DefaultRetryMaxWait, "")
+	cmd.ParseFlags(args)
+	return cmd
+}
+
+func TestGetRetryFlags(t *testing.T) {
+	retries, maxWait, err := GetRetryFlags(newRetryTestCommand("--retries", "5", "--retry-max-wait", "1m"))
+	assert.NoError(t, err)
+	assert.Equal(t, 5, retries)
+	assert.Equal(t, time.Minute, maxWait)
+
+	retries, _, err = GetRetryFlags(&cobra.Command{})
+	assert.NoError(t, err)
+	assert.Zero(t, retries)
+
+	_, _, err = GetRetryFlags(newRetryTestCommand("--retries", "-1"))
+	assert.EqualError(t, err, "--retries must not be negative")
+	_, _, err = GetRetryFlags(newRetryTestCommand("--retry-max-wait", "0s"))
+	assert.EqualError(t, err, "--retry-max-wait must be positive")
+}
+
+func TestRetryTransientErrorsForClient(t *testing.T) {
+	client, _ := conjurapi.NewClientFromKey(conjurapi.Config{Account: "conjur", ApplianceURL: "http://conjur.com"}, authn.LoginPair{Login: "username", APIKey: "password"})
+	client.SetHttpClient(&http.Client{})
+
+	cmd := newRetryTestCommand()
+	assert.NoError(t, RetryTransientErrorsForClient(cmd, client))
+	transport := client.GetHttpClient().Transport
+	assert.True(t, utils.IsRetryTransport(transport))
+
+	// Decorating the client again doesn't retry twice
+	assert.NoError(t, RetryTransientErrorsForClient(cmd, client))
+	assert.Same(t, transport, client.GetHttpClient().Transport)
+
+	client.SetHttpClient(&http.Client{})
+	assert.NoError(t, RetryTransientErrorsForClient(newRetryTestCommand("--retries", "0"), client))
+	assert.Nil(t, client.GetHttpClient().Transport)
+}
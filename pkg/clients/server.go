@@ -0,0 +1,107 @@
+package clients
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+)
+
+var errNotSelfHosted = errors.New("Only available in Secrets Manager Self-Hosted")
+
+// ServerHealth is the response of the '/health' endpoint of a self-hosted leader, standby or follower
+type ServerHealth struct {
+	OK       bool                   `json:"ok"`
+	Role     string                 `json:"role,omitempty"`
+	Services map[string]interface{} `json:"services,omitempty"`
+	Database DatabaseHealth         `json:"database"`
+}
+
+// DatabaseHealth is the health of the database of a self-hosted server
+type DatabaseHealth struct {
+	OK                bool                 `json:"ok"`
+	Connect           map[string]string    `json:"connect,omitempty"`
+	FreeSpace         map[string]DiskSpace `json:"free_space,omitempty"`
+	ReplicationStatus *ReplicationStatus   `json:"replication_status,omitempty"`
+}
+
+// DiskSpace is the free space of a database volume
+type DiskSpace struct {
+	KBytes int64 `json:"kbytes"`
+	Inodes int64 `json:"inodes"`
+}
+
+// ReplicationStatus is the database replication status. A leader reports the replicas streaming
+// from it, while a standby or follower reports how far it lags behind.
+type ReplicationStatus struct {
+	Replicas            []ReplicaStatus `json:"pg_stat_replication,omitempty"`
+	LagBytes            *int64          `json:"replication_lag_bytes,omitempty"`
+	LastReplayTimestamp string          `json:"pg_last_xact_replay_timestamp,omitempty"`
+}
+
+// ReplicaStatus is the status of a replica streaming from the leader
+type ReplicaStatus struct {
+	ApplicationName string `json:"application_name"`
+	ClientAddr      string `json:"client_addr"`
+	State           string `json:"state"`
+	SyncState       string `json:"sync_state"`
+	LagBytes        int64  `json:"replication_lag_bytes"`
+}
+
+// GetServerInfo returns the response of the '/info' endpoint of a self-hosted server
+func GetServerInfo(config conjurapi.Config, cliConfig CLIConfig) (*conjurapi.EnterpriseInfoResponse, error) {
+	if config.IsSaaS() {
+		return nil, fmt.Errorf("Unable to retrieve the server info: %s", errNotSelfHosted)
+	}
+	client, err := unauthenticatedClient(config, cliConfig)
+	if err != nil {
+		return nil, err
+	}
+	info, err := client.EnterpriseServerInfo()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve the server info: %s", err)
+	}
+	return info, nil
+}
+
+// GetServerHealth returns the response of the '/health' endpoint of a self-hosted server. An
+// unhealthy server responds with an error status, and its health is still returned with OK false.
+func GetServerHealth(config conjurapi.Config, cliConfig CLIConfig) (*ServerHealth, error) {
+	if config.IsSaaS() {
+		return nil, fmt.Errorf("Unable to retrieve the server health: %s", errNotSelfHosted)
+	}
+	client, err := unauthenticatedClient(config, cliConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(config.ApplianceURL, "/")+"/health", nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.GetHttpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to connect to %s: %s", config.ApplianceURL, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusUnauthorized {
+		return nil, fmt.Errorf("Unable to retrieve the server health: %s", errNotSelfHosted)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+	health := ServerHealth{}
+	if err = json.Unmarshal(body, &health); err != nil {
+		return nil, fmt.Errorf("Unable to retrieve the server health: %s %s", res.Status, strings.TrimSpace(string(body)))
+	}
+	if res.StatusCode != http.StatusOK {
+		health.OK = false
+	}
+	return &health, nil
+}
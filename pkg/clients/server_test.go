@@ -0,0 +1,72 @@
+package clients
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetServerInfoAndHealth(t *testing.T) {
+	status, body := http.StatusOK, ""
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	certFile := filepath.Join(t.TempDir(), "conjur-server.pem")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0600))
+	t.Setenv("HTTPS_PROXY", "")
+	t.Setenv("https_proxy", "")
+
+	config := conjurapi.Config{Account: "conjur", ApplianceURL: server.URL, SSLCertPath: certFile, CredentialStorage: conjurapi.CredentialStorageNone}
+
+	t.Run("returns the server info", func(t *testing.T) {
+		status, body = http.StatusOK, `{"release":"13.5.0","role":"follower","services":{"possum":{"version":"1.21.1"}}}`
+		info, err := GetServerInfo(config, CLIConfig{})
+		assert.NoError(t, err)
+		assert.Equal(t, "follower", info.Role)
+		assert.Equal(t, "1.21.1", info.Services["possum"].Version)
+	})
+
+	t.Run("returns the health of a healthy server", func(t *testing.T) {
+		status, body = http.StatusOK, `{"ok":true,"database":{"ok":true,"replication_status":{"replication_lag_bytes":0}}}`
+		health, err := GetServerHealth(config, CLIConfig{})
+		assert.NoError(t, err)
+		assert.True(t, health.OK)
+		assert.Equal(t, int64(0), *health.Database.ReplicationStatus.LagBytes)
+	})
+
+	t.Run("returns the health of an unhealthy server", func(t *testing.T) {
+		status, body = http.StatusBadGateway, `{"ok":false,"database":{"ok":false,"connect":{"main":"PG::ConnectionBad"}}}`
+		health, err := GetServerHealth(config, CLIConfig{})
+		assert.NoError(t, err)
+		assert.False(t, health.OK)
+		assert.Equal(t, "PG::ConnectionBad", health.Database.Connect["main"])
+	})
+
+	t.Run("fails when the server has no health endpoint", func(t *testing.T) {
+		status, body = http.StatusNotFound, ""
+		_, err := GetServerHealth(config, CLIConfig{})
+		assert.EqualError(t, err, "Unable to retrieve the server health: Only available in Secrets Manager Self-Hosted")
+	})
+
+	t.Run("fails when the response isn't a health status", func(t *testing.T) {
+		status, body = http.StatusServiceUnavailable, "Service Unavailable"
+		_, err := GetServerHealth(config, CLIConfig{})
+		assert.EqualError(t, err, "Unable to retrieve the server health: 503 Service Unavailable Service Unavailable")
+	})
+
+	t.Run("fails when the server's certificate isn't trusted", func(t *testing.T) {
+		config := config
+		config.SSLCertPath = ""
+		_, err := GetServerHealth(config, CLIConfig{})
+		assert.ErrorContains(t, err, "Unable to connect to "+server.URL)
+	})
+}
@@ -0,0 +1,285 @@
+package clients
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"gopkg.in/yaml.v3"
+)
+
+// authnTypes are the authentication types supported by conjurapi
+var authnTypes = []string{"authn", "ldap", "oidc", "jwt", "iam", "azure", "gcp", "cloud", "cert"}
+
+// credentialStorages are the supported credential storages
+var credentialStorages = []string{conjurapi.CredentialStorageKeyring, conjurapi.CredentialStorageFile, conjurapi.CredentialStorageNone, CredentialStorageEncryptedFile}
+
+// Setting is a scalar key of .conjurrc
+type Setting struct {
+	// Key is the name of the setting in .conjurrc
+	Key string
+	// EnvVars are the environment variables overriding the setting, by decreasing precedence
+	EnvVars []string
+	// normalize validates a value of the setting and returns it the way it's stored
+	normalize func(value string) (string, error)
+}
+
+// Settings are the settings that can be viewed and set with the config commands, in the order
+// they are listed
+var Settings = []Setting{
+	{Key: "appliance_url", EnvVars: []string{"CONJUR_APPLIANCE_URL"}, normalize: normalizeURL},
+	{Key: "account", EnvVars: []string{"CONJUR_ACCOUNT"}},
+	{Key: "environment", EnvVars: []string{"CONJUR_ENVIRONMENT"}, normalize: oneOf(conjurapi.SupportedEnvironments)},
+	{Key: "authn_type", EnvVars: []string{"CONJUR_AUTHN_CERT_SERVICE_ID", "CONJUR_AUTHN_JWT_SERVICE_ID", "CONJUR_AUTHN_TYPE"}, normalize: oneOf(authnTypes)},
+	{Key: "service_id", EnvVars: []string{"CONJUR_AUTHN_CERT_SERVICE_ID", "CONJUR_AUTHN_JWT_SERVICE_ID", "CONJUR_SERVICE_ID"}},
+	{Key: "cert_file", EnvVars: []string{"CONJUR_CERT_FILE"}, normalize: normalizePath},
+	{Key: "netrc_path", EnvVars: []string{"CONJUR_NETRC_PATH"}, normalize: normalizePath},
+	{Key: "credential_storage", EnvVars: []string{"CONJUR_CREDENTIAL_STORAGE"}, normalize: oneOf(credentialStorages)},
+	{Key: "keychain_namespace", EnvVars: []string{"CONJUR_KEYCHAIN_NAMESPACE"}},
+	{Key: "http_timeout", EnvVars: []string{"CONJUR_HTTP_TIMEOUT"}, normalize: intBetween(1, conjurapi.HTTPTimeoutMaxValue)},
+	{Key: "disable_keep_alives", EnvVars: []string{"CONJUR_DISABLE_KEEP_ALIVES"}, normalize: normalizeBool},
+	{Key: "proxy", normalize: normalizeProxy},
+	{Key: "cc_timeout", normalize: intBetween(1, 0)},
+	{Key: "jwt_file", EnvVars: []string{"JWT_TOKEN_PATH"}, normalize: normalizePath},
+	{Key: "jwt_host_id", EnvVars: []string{"CONJUR_AUTHN_JWT_HOST_ID"}},
+	{Key: "azure_client_id", EnvVars: []string{"CONJUR_AUTHN_AZURE_CLIENT_ID"}},
+	{Key: "client_cert_file", EnvVars: []string{"CONJUR_AUTHN_CERT_FILE"}, normalize: normalizePath},
+	{Key: "client_cert_key_file", EnvVars: []string{"CONJUR_AUTHN_CERT_KEY_FILE"}, normalize: normalizePath},
+	{Key: "cert_host_id", EnvVars: []string{"CONJUR_AUTHN_CERT_HOST_ID"}},
+	{Key: "jwt_source", EnvVars: []string{"CONJUR_AUTHN_JWT_SOURCE"}, normalize: normalizeJWTSource},
+	{Key: "metadata_url", EnvVars: []string{"CONJUR_AUTHN_METADATA_URL"}, normalize: normalizeURL},
+	{Key: "credentials_file", EnvVars: []string{"CONJUR_CREDENTIALS_FILE"}, normalize: normalizePath},
+	{Key: "cert_fingerprint", EnvVars: []string{"CONJUR_CERT_FINGERPRINT"}, normalize: utils.NormalizeFingerprint},
+	{Key: "cert_expiry_warning_days", normalize: intBetween(1, 0)},
+	{Key: "client_cert_bundle", EnvVars: []string{"CONJUR_CLIENT_CERT_BUNDLE"}, normalize: normalizePath},
+	{Key: "identity_proxy", EnvVars: []string{"CONJUR_IDENTITY_PROXY"}, normalize: normalizeProxy},
+}
+
+// LookupSetting returns the setting with the given key
+func LookupSetting(key string) (Setting, error) {
+	for _, setting := range Settings {
+		if setting.Key == key {
+			return setting, nil
+		}
+	}
+	keys := make([]string, len(Settings))
+	for i, setting := range Settings {
+		keys[i] = setting.Key
+	}
+	return Setting{}, fmt.Errorf("Unknown setting %q, must be one of %s", key, strings.Join(keys, ", "))
+}
+
+// Normalize validates a value of the setting and returns it the way it's stored in .conjurrc.
+// Paths are made absolute, so that they don't depend on the working directory.
+func (s Setting) Normalize(value string) (string, error) {
+	if s.normalize == nil {
+		return value, nil
+	}
+	normalized, err := s.normalize(value)
+	if err != nil {
+		return "", fmt.Errorf("Invalid %s: %s", s.Key, err)
+	}
+	return normalized, nil
+}
+
+func oneOf(values []string) func(string) (string, error) {
+	return func(value string) (string, error) {
+		if !slices.Contains(values, value) {
+			return "", fmt.Errorf("must be one of %s", strings.Join(values, ", "))
+		}
+		return value, nil
+	}
+}
+
+// intBetween accepts the integers from min to max, or with no upper bound when max is 0
+func intBetween(min, max int) func(string) (string, error) {
+	return func(value string) (string, error) {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return "", errors.New("must be an integer")
+		}
+		if n < min || (max > 0 && n > max) {
+			if max > 0 {
+				return "", fmt.Errorf("must be between %d and %d", min, max)
+			}
+			return "", fmt.Errorf("must be at least %d", min)
+		}
+		return strconv.Itoa(n), nil
+	}
+}
+
+func normalizeBool(value string) (string, error) {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return "", errors.New("must be true or false")
+	}
+	return strconv.FormatBool(b), nil
+}
+
+func normalizePath(value string) (string, error) {
+	return filepath.Abs(value)
+}
+
+func normalizeURL(value string) (string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return "", errors.New("must be an http:// or https:// URL")
+	}
+	return value, nil
+}
+
+func normalizeProxy(value string) (string, error) {
+	if _, err := utils.ParseProxyURL(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+func normalizeJWTSource(value string) (string, error) {
+	if _, err := ParseJWTSource(value); err != nil {
+		return "", err
+	}
+	return value, nil
+}
+
+// SettingValue is the effective value of a setting and where it comes from
+type SettingValue struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// SettingSourceDefault is the source of the settings that aren't set anywhere
+const SettingSourceDefault = "default"
+
+// EffectiveSettings returns the value of every setting of config and cliConfig, which are loaded
+// from the configuration files and the environment, with where it comes from. The source is the
+// --timeout flag when timeout is set, an environment variable, .conjurrc, the system-wide
+// conjur.conf or the default, by decreasing precedence. Unset settings are left out and the
+// credentials of proxies are redacted.
+func EffectiveSettings(config conjurapi.Config, cliConfig CLIConfig, timeout time.Duration) ([]SettingValue, error) {
+	values, err := settingValues(config, cliConfig)
+	if err != nil {
+		return nil, err
+	}
+	if values["http_timeout"] == "" {
+		values["http_timeout"] = strconv.Itoa(config.GetHttpTimeout())
+	}
+
+	conjurrc := ConjurrcPath()
+	conjurrcSource := conjurrc
+	if os.Getenv("CONJURRC") != "" {
+		conjurrcSource = fmt.Sprintf("%s (CONJURRC)", conjurrc)
+	}
+	conjurrcKeys, err := fileSettingKeys(conjurrc)
+	if err != nil {
+		return nil, err
+	}
+	systemKeys, err := fileSettingKeys(systemConjurrcPath())
+	if err != nil {
+		return nil, err
+	}
+
+	var settings []SettingValue
+	for _, setting := range Settings {
+		value := values[setting.Key]
+		if value == "" {
+			continue
+		}
+		if setting.Key == "proxy" || setting.Key == "identity_proxy" {
+			if proxyURL, err := url.Parse(value); err == nil {
+				value = proxyURL.Redacted()
+			}
+		}
+
+		source := SettingSourceDefault
+		switch {
+		case setting.Key == "http_timeout" && timeout > 0:
+			source = "--timeout flag"
+		case setting.EnvVar() != "":
+			source = setting.EnvVar()
+		case slices.Contains(conjurrcKeys, setting.Key):
+			source = conjurrcSource
+		case slices.Contains(systemKeys, setting.Key):
+			source = systemConjurrcPath()
+		}
+		settings = append(settings, SettingValue{Key: setting.Key, Value: value, Source: source})
+	}
+	return settings, nil
+}
+
+// EnvVar returns the environment variable overriding the setting, if any is set
+func (s Setting) EnvVar() string {
+	for _, name := range s.EnvVars {
+		if os.Getenv(name) != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// settingValues returns the values of the settings of config and cliConfig, as they are written to
+// .conjurrc
+func settingValues(config conjurapi.Config, cliConfig CLIConfig) (map[string]string, error) {
+	if path, err := CredentialsFilePath(); err == nil && config.CredentialStorage == CredentialStorageEncryptedFile {
+		cliConfig.CredentialsFile = path
+	}
+
+	values := map[string]string{}
+	for _, v := range []interface{}{&config, &cliConfig} {
+		var node yaml.Node
+		if err := node.Encode(v); err != nil {
+			return nil, err
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i+1].Kind == yaml.ScalarNode {
+				values[node.Content[i].Value] = node.Content[i+1].Value
+			}
+		}
+	}
+	return values, nil
+}
+
+// fileSettingKeys returns the top-level keys of a configuration file, or none when it doesn't exist
+func fileSettingKeys(path string) ([]string, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var mapping map[string]interface{}
+	if err = yaml.Unmarshal(data, &mapping); err != nil {
+		return nil, fmt.Errorf("%s is not a valid configuration file: %s", path, err)
+	}
+	keys := make([]string, 0, len(mapping))
+	for key := range mapping {
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// systemConjurrcPath returns the path of the system-wide configuration file, which conjurapi loads
+// before .conjurrc
+func systemConjurrcPath() string {
+	if runtime.GOOS == "windows" {
+		return `C:\windows\conjur.conf`
+	}
+	return "/etc/conjur.conf"
+}
@@ -0,0 +1,90 @@
+package clients
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/cyberark/conjur-api-go/conjurapi/storage"
+)
+
+// tokenCacheFile is the file in the user cache directory holding the cached access tokens when the
+// credentials are stored in a .netrc file. It has the same format and permissions.
+const tokenCacheFile = "conjur-access-tokens"
+
+// cachingAuthenticator reuses the access token obtained by a previous command until it should be
+// refreshed, instead of authenticating again with the API key
+type cachingAuthenticator struct {
+	conjurapi.Authenticator
+	cache conjurapi.CredentialStorageProvider
+}
+
+func (a *cachingAuthenticator) RefreshToken() ([]byte, error) {
+	if cached, err := a.cache.ReadAuthnToken(); err == nil && len(cached) > 0 {
+		if token, err := authn.NewToken(cached); err == nil && !token.ShouldRefresh() {
+			return cached, nil
+		}
+	}
+
+	tokenBytes, err := a.Authenticator.RefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	// The token is only cached to save time, so failing to do it doesn't fail the command
+	_ = a.cache.StoreAuthnToken(tokenBytes)
+	return tokenBytes, nil
+}
+
+// accessTokenCache returns where the access tokens of the identity are cached, which follows the
+// credential storage. Tokens aren't cached when credential storage is disabled.
+func accessTokenCache(config conjurapi.Config, identity string) (conjurapi.CredentialStorageProvider, error) {
+	name := fmt.Sprintf("%s/%s/%s:access-token", strings.TrimSuffix(config.ApplianceURL, "/"), config.Account, identity)
+
+	switch credentialStorageType(config) {
+	case conjurapi.CredentialStorageKeyring:
+		return storage.NewKeyringStorageProvider(name), nil
+	case CredentialStorageEncryptedFile:
+		return newEncryptedFileStorage(name)
+	case conjurapi.CredentialStorageFile:
+		dir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, err
+		}
+		if err = os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+		return storage.NewNetrcStorageProvider(filepath.Join(dir, tokenCacheFile), name)
+	default:
+		return nil, nil
+	}
+}
+
+// cacheAccessTokens makes a client that authenticates with an API key share its access tokens with
+// the other commands
+func cacheAccessTokens(client ConjurClient, config conjurapi.Config) {
+	apiClient, ok := client.(*conjurapi.Client)
+	if !ok {
+		return
+	}
+	authenticator, ok := apiClient.GetAuthenticator().(*authn.APIKeyAuthenticator)
+	if !ok || authenticator.Login == "" {
+		return
+	}
+	cache, err := accessTokenCache(config, authenticator.Login)
+	if err != nil || cache == nil {
+		return
+	}
+	apiClient.SetAuthenticator(&cachingAuthenticator{Authenticator: authenticator, cache: cache})
+}
+
+// purgeAccessToken deletes the cached access token of the identity
+func purgeAccessToken(config conjurapi.Config, identity string) error {
+	cache, err := accessTokenCache(config, identity)
+	if err != nil || cache == nil {
+		return err
+	}
+	return cache.PurgeCredentials()
+}
@@ -0,0 +1,127 @@
+package clients
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/authn"
+	"github.com/stretchr/testify/assert"
+)
+
+type countingAuthenticator struct {
+	token []byte
+	calls int
+}
+
+func (a *countingAuthenticator) RefreshToken() ([]byte, error) {
+	a.calls++
+	return a.token, nil
+}
+
+func (a *countingAuthenticator) NeedsTokenRefresh() bool {
+	return false
+}
+
+func testAccessToken(login string, issued time.Time) []byte {
+	payload := base64.StdEncoding.EncodeToString([]byte(fmt.Sprintf(`{"sub":"%s","iat":%d}`, login, issued.Unix())))
+	return []byte(fmt.Sprintf(`{"protected":"e30=","payload":"%s","signature":"c2ln"}`, payload))
+}
+
+// testTokenCacheConfig returns a config caching the access tokens in a temporary user cache directory
+func testTokenCacheConfig(t *testing.T) conjurapi.Config {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	return testIdentitiesConfig(t)
+}
+
+func TestCachingAuthenticator(t *testing.T) {
+	t.Run("reuses the cached access token", func(t *testing.T) {
+		config := testTokenCacheConfig(t)
+		cache, err := accessTokenCache(config, "alice")
+		assert.NoError(t, err)
+
+		token := testAccessToken("alice", time.Now())
+		first := &countingAuthenticator{token: token}
+		tokenBytes, err := (&cachingAuthenticator{Authenticator: first, cache: cache}).RefreshToken()
+		assert.NoError(t, err)
+		assert.Equal(t, token, tokenBytes)
+
+		second := &countingAuthenticator{token: testAccessToken("alice", time.Now())}
+		tokenBytes, err = (&cachingAuthenticator{Authenticator: second, cache: cache}).RefreshToken()
+		assert.NoError(t, err)
+		assert.Equal(t, token, tokenBytes)
+		assert.Equal(t, 1, first.calls)
+		assert.Equal(t, 0, second.calls)
+
+		cacheDir, err := os.UserCacheDir()
+		assert.NoError(t, err)
+		info, err := os.Stat(filepath.Join(cacheDir, tokenCacheFile))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("refreshes the access token shortly before it expires", func(t *testing.T) {
+		config := testTokenCacheConfig(t)
+		cache, err := accessTokenCache(config, "alice")
+		assert.NoError(t, err)
+		assert.NoError(t, cache.StoreAuthnToken(testAccessToken("alice", time.Now().Add(-6*time.Minute))))
+
+		token := testAccessToken("alice", time.Now())
+		authenticator := &countingAuthenticator{token: token}
+		tokenBytes, err := (&cachingAuthenticator{Authenticator: authenticator, cache: cache}).RefreshToken()
+		assert.NoError(t, err)
+		assert.Equal(t, token, tokenBytes)
+		assert.Equal(t, 1, authenticator.calls)
+
+		cached, err := cache.ReadAuthnToken()
+		assert.NoError(t, err)
+		assert.Equal(t, token, cached)
+	})
+
+	t.Run("keeps the access tokens of each identity apart", func(t *testing.T) {
+		config := testTokenCacheConfig(t)
+		aliceCache, err := accessTokenCache(config, "alice")
+		assert.NoError(t, err)
+		assert.NoError(t, aliceCache.StoreAuthnToken(testAccessToken("alice", time.Now())))
+
+		bobCache, err := accessTokenCache(config, "bob")
+		assert.NoError(t, err)
+		authenticator := &countingAuthenticator{token: testAccessToken("bob", time.Now())}
+		_, err = (&cachingAuthenticator{Authenticator: authenticator, cache: bobCache}).RefreshToken()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, authenticator.calls)
+	})
+
+	t.Run("doesn't cache access tokens without credential storage", func(t *testing.T) {
+		config := testTokenCacheConfig(t)
+		config.CredentialStorage = conjurapi.CredentialStorageNone
+		cache, err := accessTokenCache(config, "alice")
+		assert.NoError(t, err)
+		assert.Nil(t, cache)
+	})
+}
+
+func TestCacheAccessTokens(t *testing.T) {
+	config := testTokenCacheConfig(t)
+	client, err := conjurapi.NewClientFromKey(config, authn.LoginPair{Login: "alice", APIKey: "alice-api-key"})
+	assert.NoError(t, err)
+
+	cacheAccessTokens(client, config)
+	authenticator, ok := client.GetAuthenticator().(*cachingAuthenticator)
+	assert.True(t, ok)
+
+	// Decorating the client again doesn't cache the access tokens twice
+	cacheAccessTokens(client, config)
+	assert.Same(t, authenticator, client.GetAuthenticator())
+
+	// Logging out deletes the cached access token
+	loginAs(t, config, "alice", "alice-api-key")
+	assert.NoError(t, authenticator.cache.StoreAuthnToken(testAccessToken("alice", time.Now())))
+	assert.NoError(t, PurgeIdentity(config, ""))
+	_, err = authenticator.cache.ReadAuthnToken()
+	assert.Error(t, err)
+}
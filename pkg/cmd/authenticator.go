@@ -2,13 +2,19 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"strings"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
 	"github.com/cyberark/conjur-cli-go/pkg/clients"
 	"github.com/spf13/cobra"
-	"strings"
 )
 
 type authenticatorClient interface {
 	EnableAuthenticator(authenticatorType string, serviceID string, enabled bool) error
+	LoadPolicy(mode conjurapi.PolicyMode, policyID string, policy io.Reader) (*conjurapi.PolicyResponse, error)
+	AddSecret(variableID string, secretValue string) error
+	RetrieveSecret(variableID string) ([]byte, error)
 }
 
 type authenticatorClientFactoryFunc func(*cobra.Command) (authenticatorClient, error)
@@ -54,6 +60,7 @@ Examples:
 		},
 	}
 
+	addAuthenticatorIDFlag(cmd)
 	return cmd
 }
 
@@ -94,9 +101,15 @@ Examples:
 		},
 	}
 
+	addAuthenticatorIDFlag(cmd)
 	return cmd
 }
 
+func addAuthenticatorIDFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("id", "i", "", "(Required) Provide authenticator identifier")
+	cmd.MarkFlagRequired("id")
+}
+
 func parseAuthenticatorID(ID string) (authenticatorType, serviceID string, err error) {
 	if ID == "authn-gcp" {
 		// For a GCP authenticator, use only authn-gcp; the <service-ID> is not relevant in this use case
@@ -124,14 +137,15 @@ func newAuthenticatorCommand(clientFactory authenticatorClientFactoryFunc) *cobr
 		Short: "Manage Secrets Manager authenticators",
 	}
 
-	authenticatorCmd.PersistentFlags().StringP("id", "i", "", "(Required) Provide authenticator identifier")
-	authenticatorCmd.MarkPersistentFlagRequired("id")
-
 	authenticatorEnable := newEnableCmd(clientFactory)
 	authenticatorDisable := newDisableCmd(clientFactory)
+	authenticatorScaffold := newScaffoldCmd(clientFactory)
+	authenticatorTestJWT := newTestJWTCmd(clientFactory)
 
 	authenticatorCmd.AddCommand(authenticatorEnable)
 	authenticatorCmd.AddCommand(authenticatorDisable)
+	authenticatorCmd.AddCommand(authenticatorScaffold)
+	authenticatorCmd.AddCommand(authenticatorTestJWT)
 	return authenticatorCmd
 }
 
@@ -2,15 +2,20 @@ package cmd
 
 import (
 	"fmt"
-	"github.com/spf13/cobra"
+	"io"
 	"testing"
 
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/spf13/cobra"
 	"github.com/stretchr/testify/assert"
 )
 
 type mockAuthenticatorClient struct {
 	t                   *testing.T
 	enableAuthenticator func(t *testing.T, authenticatorType string, serviceID string, enabled bool) error
+	loadPolicy          func(t *testing.T, mode conjurapi.PolicyMode, policyID string, policy io.Reader) (*conjurapi.PolicyResponse, error)
+	addSecret           func(t *testing.T, variableID string, secretValue string) error
+	retrieveSecret      func(t *testing.T, variableID string) ([]byte, error)
 }
 
 func (m mockAuthenticatorClient) EnableAuthenticator(
@@ -21,6 +26,22 @@ func (m mockAuthenticatorClient) EnableAuthenticator(
 	return m.enableAuthenticator(m.t, authenticatorType, serviceID, enabled)
 }
 
+func (m mockAuthenticatorClient) LoadPolicy(
+	mode conjurapi.PolicyMode,
+	policyID string,
+	policy io.Reader,
+) (*conjurapi.PolicyResponse, error) {
+	return m.loadPolicy(m.t, mode, policyID, policy)
+}
+
+func (m mockAuthenticatorClient) AddSecret(variableID string, secretValue string) error {
+	return m.addSecret(m.t, variableID, secretValue)
+}
+
+func (m mockAuthenticatorClient) RetrieveSecret(variableID string) ([]byte, error) {
+	return m.retrieveSecret(m.t, variableID)
+}
+
 type authenticatorCmdTestCase struct {
 	name                string
 	args                []string
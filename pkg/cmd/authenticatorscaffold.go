@@ -0,0 +1,342 @@
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/prompts"
+	"github.com/spf13/cobra"
+)
+
+// scaffoldSetting describes a single configuration value of an authenticator. Most settings
+// are stored in variables, but some authenticators (LDAP) read them from webservice annotations.
+type scaffoldSetting struct {
+	name         string
+	title        string
+	defaultValue string
+	required     bool
+	secret       bool
+	annotation   bool
+}
+
+var authenticatorScaffoldSettings = map[string][]scaffoldSetting{
+	"jwt": {
+		{name: "jwks-uri", title: "JWKS URI (leave empty to use public keys)"},
+		{name: "public-keys", title: "Public keys JSON (leave empty to use a JWKS URI)"},
+		{name: "issuer", title: "Token issuer ('iss' claim)"},
+		{name: "audience", title: "Token audience ('aud' claim)"},
+		{name: "token-app-property", title: "Claim that contains the application identity"},
+		{name: "identity-path", title: "Policy path of the hosts that authenticate with this authenticator"},
+		{name: "enforced-claims", title: "Comma-separated claims that every token must contain"},
+	},
+	"oidc": {
+		{name: "provider-uri", title: "OIDC provider URI", required: true},
+		{name: "client-id", title: "Client ID", required: true},
+		{name: "client-secret", title: "Client secret", required: true, secret: true},
+		{name: "redirect-uri", title: "Redirect URI", defaultValue: "http://127.0.0.1:8888/callback", required: true},
+		{name: "claim-mapping", title: "Claim that maps to the Secrets Manager user", defaultValue: "preferred_username", required: true},
+		{name: "provider-scope", title: "Additional scopes to request"},
+		{name: "name", title: "Display name of the provider"},
+	},
+	"ldap": {
+		{name: "ldap-authn/host", title: "LDAP server host", required: true, annotation: true},
+		{name: "ldap-authn/port", title: "LDAP server port", defaultValue: "389", required: true, annotation: true},
+		{name: "ldap-authn/connect_type", title: "Connection type (plain, tls or ssl)", defaultValue: "tls", required: true, annotation: true},
+		{name: "ldap-authn/base_dn", title: "Base DN", required: true, annotation: true},
+		{name: "ldap-authn/bind_dn", title: "Bind DN", required: true, annotation: true},
+		{name: "ldap-authn/filter_template", title: "User filter template", defaultValue: "(uid=%s)", annotation: true},
+		{name: "bind-password", title: "Bind password", required: true, secret: true},
+		{name: "tls-ca-cert", title: "CA certificate of the LDAP server (PEM)"},
+	},
+}
+
+type scaffoldCmdFlagValues struct {
+	authnType   string
+	serviceID   string
+	settings    map[string]string
+	printPolicy bool
+	skipLoad    bool
+}
+
+func getScaffoldCmdFlagValues(cmd *cobra.Command) (scaffoldCmdFlagValues, error) {
+	authnType, err := cmd.Flags().GetString("type")
+	if err != nil {
+		return scaffoldCmdFlagValues{}, err
+	}
+	serviceID, err := cmd.Flags().GetString("service-id")
+	if err != nil {
+		return scaffoldCmdFlagValues{}, err
+	}
+	settings, err := getSettingFlagValues(cmd)
+	if err != nil {
+		return scaffoldCmdFlagValues{}, err
+	}
+	printPolicy, err := cmd.Flags().GetBool("print-policy")
+	if err != nil {
+		return scaffoldCmdFlagValues{}, err
+	}
+	skipLoad, err := cmd.Flags().GetBool("skip-load")
+	if err != nil {
+		return scaffoldCmdFlagValues{}, err
+	}
+
+	return scaffoldCmdFlagValues{
+		authnType:   strings.TrimPrefix(authnType, "authn-"),
+		serviceID:   serviceID,
+		settings:    settings,
+		printPolicy: printPolicy,
+		skipLoad:    skipLoad,
+	}, nil
+}
+
+// getSettingFlagValues parses the repeatable --setting flag of the form 'name=value'
+func getSettingFlagValues(cmd *cobra.Command) (map[string]string, error) {
+	rawSettings, err := cmd.Flags().GetStringArray("setting")
+	if err != nil {
+		return nil, err
+	}
+
+	settings := map[string]string{}
+	for _, rawSetting := range rawSettings {
+		name, value, ok := strings.Cut(rawSetting, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid setting %q, expected format is 'name=value'", rawSetting)
+		}
+		settings[strings.TrimSpace(name)] = value
+	}
+	return settings, nil
+}
+
+func supportedScaffoldTypes() []string {
+	types := make([]string, 0, len(authenticatorScaffoldSettings))
+	for authnType := range authenticatorScaffoldSettings {
+		types = append(types, authnType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// collectScaffoldValues merges the values given on the command line with the answers to
+// prompts for the remaining settings. Prompts are skipped when every required setting
+// without a default has been provided on the command line, so the command can be used
+// non-interactively.
+func collectScaffoldValues(authnType string, settings []scaffoldSetting, provided map[string]string) (map[string]string, error) {
+	known := map[string]bool{}
+	for _, setting := range settings {
+		known[setting.name] = true
+	}
+	for name := range provided {
+		if !known[name] {
+			names := make([]string, 0, len(settings))
+			for _, setting := range settings {
+				names = append(names, setting.name)
+			}
+			return nil, fmt.Errorf("unknown setting %q for authn-%s, expected one of: %s", name, authnType, strings.Join(names, ", "))
+		}
+	}
+
+	values := map[string]string{}
+	needsPrompt := false
+	for _, setting := range settings {
+		if value, ok := provided[setting.name]; ok {
+			values[setting.name] = strings.TrimSpace(value)
+			continue
+		}
+		values[setting.name] = setting.defaultValue
+		if setting.required && setting.defaultValue == "" {
+			needsPrompt = true
+		}
+	}
+	if authnType == "jwt" && values["jwks-uri"] == "" && values["public-keys"] == "" {
+		// authn-jwt needs one of the two key sources, neither of which is required on its own
+		needsPrompt = true
+	}
+
+	if needsPrompt {
+		answers := make([]string, len(settings))
+		var missing []prompts.Setting
+		for i, setting := range settings {
+			if _, ok := provided[setting.name]; ok {
+				continue
+			}
+			answers[i] = setting.defaultValue
+			missing = append(missing, prompts.Setting{
+				Title:    fmt.Sprintf("%s (%s)", setting.title, setting.name),
+				Value:    &answers[i],
+				Required: setting.required,
+				Secret:   setting.secret,
+			})
+		}
+		err := prompts.AskForSettings(fmt.Sprintf("Configure authn-%s", authnType), missing)
+		if err != nil {
+			return nil, err
+		}
+		for i, setting := range settings {
+			if _, ok := provided[setting.name]; !ok {
+				values[setting.name] = answers[i]
+			}
+		}
+	}
+
+	var missing []string
+	for _, setting := range settings {
+		if setting.required && values[setting.name] == "" {
+			missing = append(missing, setting.name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("missing required settings for authn-%s: %s", authnType, strings.Join(missing, ", "))
+	}
+	if authnType == "jwt" && values["jwks-uri"] == "" && values["public-keys"] == "" {
+		return nil, fmt.Errorf("authn-jwt requires either the jwks-uri or the public-keys setting")
+	}
+
+	return values, nil
+}
+
+func scaffoldPolicyID(authnType string, serviceID string) string {
+	return fmt.Sprintf("conjur/authn-%s/%s", authnType, serviceID)
+}
+
+// scaffoldPolicy generates the policy for an authenticator. Only the variables and annotations
+// that have a value are declared, since the authenticators treat declared but empty variables
+// as misconfiguration.
+func scaffoldPolicy(authnType string, serviceID string, settings []scaffoldSetting, values map[string]string) string {
+	var policy strings.Builder
+
+	fmt.Fprintf(&policy, "- !policy\n  id: %s\n  body:\n  - !webservice\n", scaffoldPolicyID(authnType, serviceID))
+
+	annotationsWritten := false
+	for _, setting := range settings {
+		if !setting.annotation || values[setting.name] == "" {
+			continue
+		}
+		if !annotationsWritten {
+			policy.WriteString("    annotations:\n")
+			annotationsWritten = true
+		}
+		fmt.Fprintf(&policy, "      %s: %s\n", setting.name, strconv.Quote(values[setting.name]))
+	}
+	policy.WriteString("\n")
+
+	for _, setting := range settings {
+		if setting.annotation || values[setting.name] == "" {
+			continue
+		}
+		fmt.Fprintf(&policy, "  - !variable %s\n", setting.name)
+	}
+
+	policy.WriteString(`
+  - !group authenticatable
+
+  - !permit
+    role: !group authenticatable
+    privilege: [ read, authenticate ]
+    resource: !webservice
+`)
+
+	return policy.String()
+}
+
+func runScaffoldCommand(cmd *cobra.Command, clientFactory authenticatorClientFactoryFunc) error {
+	cmdFlagVals, err := getScaffoldCmdFlagValues(cmd)
+	if err != nil {
+		return err
+	}
+
+	settings, ok := authenticatorScaffoldSettings[cmdFlagVals.authnType]
+	if !ok {
+		return fmt.Errorf(
+			"unsupported authenticator type: %s, expected one of: %s",
+			cmdFlagVals.authnType,
+			strings.Join(supportedScaffoldTypes(), ", "),
+		)
+	}
+	if strings.TrimSpace(cmdFlagVals.serviceID) == "" || strings.ContainsAny(cmdFlagVals.serviceID, "/ \t\n") {
+		return fmt.Errorf("invalid service ID %q", cmdFlagVals.serviceID)
+	}
+
+	values, err := collectScaffoldValues(cmdFlagVals.authnType, settings, cmdFlagVals.settings)
+	if err != nil {
+		return err
+	}
+
+	policy := scaffoldPolicy(cmdFlagVals.authnType, cmdFlagVals.serviceID, settings, values)
+	if cmdFlagVals.printPolicy {
+		cmd.Print(policy)
+		return nil
+	}
+
+	client, err := clientFactory(cmd)
+	if err != nil {
+		return err
+	}
+
+	policyID := scaffoldPolicyID(cmdFlagVals.authnType, cmdFlagVals.serviceID)
+	if !cmdFlagVals.skipLoad {
+		_, err = client.LoadPolicy(conjurapi.PolicyModePost, "root", strings.NewReader(policy))
+		if err != nil {
+			return fmt.Errorf("Unable to load the policy for %s: %w", policyID, err)
+		}
+		cmd.Printf("Loaded policy '%s'\n", policyID)
+	}
+
+	for _, setting := range settings {
+		value := values[setting.name]
+		if setting.annotation || value == "" {
+			continue
+		}
+		variableID := policyID + "/" + setting.name
+		err = client.AddSecret(variableID, value)
+		if err != nil {
+			return fmt.Errorf("Unable to set variable %s: %w", variableID, err)
+		}
+		cmd.Printf("Set variable '%s'\n", variableID)
+	}
+
+	err = client.EnableAuthenticator(cmdFlagVals.authnType, cmdFlagVals.serviceID, true)
+	if err != nil {
+		return err
+	}
+
+	cmd.Printf("Authenticator authn-%s/%s is enabled\n", cmdFlagVals.authnType, cmdFlagVals.serviceID)
+	cmd.Printf("Grant roles membership in the group '%s/authenticatable' to allow them to authenticate\n", policyID)
+	return nil
+}
+
+func newScaffoldCmd(clientFactory authenticatorClientFactoryFunc) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "scaffold",
+		Short: "Create and configure a new authenticator",
+		Long: `Create and configure a new authenticator.
+
+The command generates the policy for the authenticator, loads it into the root policy, sets its configuration variables and enables the authenticator.
+
+Settings that are not provided with --setting are requested interactively. When every required setting is provided on the command line, no prompts are presented.
+
+Supported authenticator types: jwt, oidc, ldap
+
+Examples:
+
+- conjur authenticator scaffold --type jwt --service-id github
+- conjur authenticator scaffold -t jwt -s github --setting jwks-uri=https://token.actions.githubusercontent.com/.well-known/jwks --setting issuer=https://token.actions.githubusercontent.com
+- conjur authenticator scaffold --type oidc --service-id okta --print-policy`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runScaffoldCommand(cmd, clientFactory)
+		},
+	}
+
+	cmd.Flags().StringP("type", "t", "", "(Required) Authenticator type (jwt, oidc or ldap)")
+	cmd.MarkFlagRequired("type")
+	cmd.Flags().StringP("service-id", "s", "", "(Required) Service ID of the authenticator")
+	cmd.MarkFlagRequired("service-id")
+	cmd.Flags().StringArray("setting", []string{}, "Authenticator setting in the format 'name=value' (can be repeated)")
+	cmd.Flags().Bool("print-policy", false, "Print the generated policy without making any changes")
+	cmd.Flags().Bool("skip-load", false, "Do not load the generated policy (use when the policy is already loaded)")
+
+	return cmd
+}
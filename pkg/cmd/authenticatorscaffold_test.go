@@ -0,0 +1,220 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+const expectedJWTScaffoldPolicy = `- !policy
+  id: conjur/authn-jwt/github
+  body:
+  - !webservice
+
+  - !variable jwks-uri
+  - !variable issuer
+  - !variable token-app-property
+
+  - !group authenticatable
+
+  - !permit
+    role: !group authenticatable
+    privilege: [ read, authenticate ]
+    resource: !webservice
+`
+
+var jwtScaffoldArgs = []string{
+	"authenticator", "scaffold", "--type", "jwt", "--service-id", "github",
+	"--setting", "jwks-uri=https://token.actions.githubusercontent.com/.well-known/jwks",
+	"--setting", "issuer=https://token.actions.githubusercontent.com",
+	"--setting", "token-app-property=repository",
+}
+
+type scaffoldCalls struct {
+	policyBranch string
+	policy       string
+	secrets      map[string]string
+	enabled      []string
+}
+
+var scaffoldCmdTestCases = []struct {
+	name               string
+	args               []string
+	loadPolicyError    error
+	addSecretError     error
+	clientFactoryError error
+	assert             func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error)
+}{
+	{
+		name: "scaffold subcommand help",
+		args: []string{"authenticator", "scaffold", "--help"},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stdout, "HELP LONG")
+		},
+	},
+	{
+		name: "missing type",
+		args: []string{"authenticator", "scaffold", "--service-id", "github"},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: required flag(s) \"type\" not set\n")
+		},
+	},
+	{
+		name: "unsupported type",
+		args: []string{"authenticator", "scaffold", "--type", "iam", "--service-id", "prod"},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: unsupported authenticator type: iam, expected one of: jwt, ldap, oidc\n")
+		},
+	},
+	{
+		name: "invalid service id",
+		args: []string{"authenticator", "scaffold", "--type", "jwt", "--service-id", "a/b"},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: invalid service ID \"a/b\"\n")
+		},
+	},
+	{
+		name: "unknown setting",
+		args: []string{"authenticator", "scaffold", "-t", "jwt", "-s", "github", "--setting", "color=blue"},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: unknown setting \"color\" for authn-jwt")
+		},
+	},
+	{
+		name: "malformed setting",
+		args: []string{"authenticator", "scaffold", "-t", "jwt", "-s", "github", "--setting", "issuer"},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: invalid setting \"issuer\", expected format is 'name=value'\n")
+		},
+	},
+	{
+		name: "jwt without a key source",
+		args: []string{"authenticator", "scaffold", "-t", "jwt", "-s", "github", "--setting", "jwks-uri=", "--setting", "public-keys="},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: authn-jwt requires either the jwks-uri or the public-keys setting\n")
+		},
+	},
+	{
+		name:               "print policy",
+		args:               append(append([]string{}, jwtScaffoldArgs...), "--print-policy"),
+		clientFactoryError: fmt.Errorf("client should not be created"),
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Equal(t, expectedJWTScaffoldPolicy, stdout)
+		},
+	},
+	{
+		name: "loads policy, sets variables and enables the authenticator",
+		args: jwtScaffoldArgs,
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Equal(t, "root", calls.policyBranch)
+			assert.Equal(t, expectedJWTScaffoldPolicy, calls.policy)
+			assert.Equal(t, map[string]string{
+				"conjur/authn-jwt/github/jwks-uri":           "https://token.actions.githubusercontent.com/.well-known/jwks",
+				"conjur/authn-jwt/github/issuer":             "https://token.actions.githubusercontent.com",
+				"conjur/authn-jwt/github/token-app-property": "repository",
+			}, calls.secrets)
+			assert.Equal(t, []string{"jwt/github"}, calls.enabled)
+			assert.Contains(t, stdout, "Loaded policy 'conjur/authn-jwt/github'\n")
+			assert.Contains(t, stdout, "Authenticator authn-jwt/github is enabled\n")
+		},
+	},
+	{
+		name: "skip load",
+		args: append(append([]string{}, jwtScaffoldArgs...), "--skip-load"),
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Empty(t, calls.policy)
+			assert.Len(t, calls.secrets, 3)
+			assert.Equal(t, []string{"jwt/github"}, calls.enabled)
+		},
+	},
+	{
+		name: "ldap settings are written as annotations and defaults are applied",
+		args: []string{
+			"authenticator", "scaffold", "--type", "authn-ldap", "--service-id", "corp",
+			"--setting", "ldap-authn/host=ldap.example.com",
+			"--setting", "ldap-authn/base_dn=dc=example,dc=com",
+			"--setting", "ldap-authn/bind_dn=cn=admin,dc=example,dc=com",
+			"--setting", "bind-password=s3cr3t",
+		},
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, calls.policy, "    annotations:\n      ldap-authn/host: \"ldap.example.com\"\n      ldap-authn/port: \"389\"\n")
+			assert.Contains(t, calls.policy, "      ldap-authn/filter_template: \"(uid=%s)\"\n")
+			assert.Contains(t, calls.policy, "  - !variable bind-password\n")
+			assert.NotContains(t, calls.policy, "tls-ca-cert")
+			assert.Equal(t, map[string]string{"conjur/authn-ldap/corp/bind-password": "s3cr3t"}, calls.secrets)
+			assert.Equal(t, []string{"ldap/corp"}, calls.enabled)
+		},
+	},
+	{
+		name:            "load policy error",
+		args:            jwtScaffoldArgs,
+		loadPolicyError: fmt.Errorf("policy error"),
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: Unable to load the policy for conjur/authn-jwt/github: policy error\n")
+			assert.Empty(t, calls.enabled)
+		},
+	},
+	{
+		name:           "add secret error",
+		args:           jwtScaffoldArgs,
+		addSecretError: fmt.Errorf("secret error"),
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: Unable to set variable conjur/authn-jwt/github/jwks-uri: secret error\n")
+			assert.Empty(t, calls.enabled)
+		},
+	},
+	{
+		name:               "client factory error",
+		args:               jwtScaffoldArgs,
+		clientFactoryError: fmt.Errorf("client factory error"),
+		assert: func(t *testing.T, calls scaffoldCalls, stdout string, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: client factory error\n")
+		},
+	},
+}
+
+func TestAuthenticatorScaffoldCmd(t *testing.T) {
+	for _, tc := range scaffoldCmdTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			calls := scaffoldCalls{secrets: map[string]string{}}
+			mockClient := mockAuthenticatorClient{
+				t: t,
+				loadPolicy: func(t *testing.T, mode conjurapi.PolicyMode, policyID string, policy io.Reader) (*conjurapi.PolicyResponse, error) {
+					assert.Equal(t, conjurapi.PolicyModePost, mode)
+					content, err := io.ReadAll(policy)
+					assert.NoError(t, err)
+					calls.policyBranch = policyID
+					calls.policy = string(content)
+					return &conjurapi.PolicyResponse{}, tc.loadPolicyError
+				},
+				addSecret: func(t *testing.T, variableID string, secretValue string) error {
+					if tc.addSecretError != nil {
+						return tc.addSecretError
+					}
+					calls.secrets[variableID] = secretValue
+					return nil
+				},
+				enableAuthenticator: func(t *testing.T, authenticatorType string, serviceID string, enabled bool) error {
+					assert.True(t, enabled)
+					calls.enabled = append(calls.enabled, authenticatorType+"/"+serviceID)
+					return nil
+				},
+			}
+			cmd := newAuthenticatorCommand(
+				func(cmd *cobra.Command) (authenticatorClient, error) {
+					return mockClient, tc.clientFactoryError
+				})
+
+			stdout, stderr, err := executeCommandForTest(t, cmd, tc.args...)
+			tc.assert(t, calls, stdout, stderr, err)
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package cmd
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi/response"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"github.com/spf13/cobra"
+)
+
+// jwtAuthenticatorVariables are the authn-jwt configuration variables relevant to token validation
+var jwtAuthenticatorVariables = []string{
+	"jwks-uri",
+	"public-keys",
+	"ca-cert",
+	"issuer",
+	"audience",
+	"token-app-property",
+	"identity-path",
+	"enforced-claims",
+	"claim-aliases",
+}
+
+type jwtCheckResult struct {
+	name    string
+	passed  bool
+	skipped bool
+	detail  string
+}
+
+func (r jwtCheckResult) status() string {
+	switch {
+	case r.skipped:
+		return "SKIP"
+	case r.passed:
+		return "PASS"
+	default:
+		return "FAIL"
+	}
+}
+
+type testJWTCmdFlagValues struct {
+	serviceID string
+	jwtFile   string
+	jwksFile  string
+	hostID    string
+	settings  map[string]string
+	offline   bool
+}
+
+func getTestJWTCmdFlagValues(cmd *cobra.Command) (testJWTCmdFlagValues, error) {
+	serviceID, err := cmd.Flags().GetString("service-id")
+	if err != nil {
+		return testJWTCmdFlagValues{}, err
+	}
+	jwtFile, err := cmd.Flags().GetString("jwt-file")
+	if err != nil {
+		return testJWTCmdFlagValues{}, err
+	}
+	jwksFile, err := cmd.Flags().GetString("jwks-file")
+	if err != nil {
+		return testJWTCmdFlagValues{}, err
+	}
+	hostID, err := cmd.Flags().GetString("host-id")
+	if err != nil {
+		return testJWTCmdFlagValues{}, err
+	}
+	settings, err := getSettingFlagValues(cmd)
+	if err != nil {
+		return testJWTCmdFlagValues{}, err
+	}
+	offline, err := cmd.Flags().GetBool("offline")
+	if err != nil {
+		return testJWTCmdFlagValues{}, err
+	}
+
+	return testJWTCmdFlagValues{
+		serviceID: serviceID,
+		jwtFile:   jwtFile,
+		jwksFile:  jwksFile,
+		hostID:    hostID,
+		settings:  settings,
+		offline:   offline,
+	}, nil
+}
+
+// fetchJWTAuthenticatorSettings reads the configuration variables of an authn-jwt authenticator.
+// Variables that don't exist or have no value are left out of the result.
+func fetchJWTAuthenticatorSettings(client authenticatorClient, serviceID string) (map[string]string, error) {
+	policyID := scaffoldPolicyID("jwt", serviceID)
+	settings := map[string]string{}
+	for _, name := range jwtAuthenticatorVariables {
+		variableID := policyID + "/" + name
+		value, err := client.RetrieveSecret(variableID)
+		if err != nil {
+			var conjurErr *response.ConjurError
+			if errors.As(err, &conjurErr) && conjurErr.Code == http.StatusNotFound {
+				continue
+			}
+			return nil, fmt.Errorf("Unable to read %s: %w", variableID, err)
+		}
+		settings[name] = strings.TrimSpace(string(value))
+	}
+	return settings, nil
+}
+
+// loadJWKS loads the keys used to verify the token, preferring a local file over the
+// public-keys variable, and the public-keys variable over the jwks-uri
+func loadJWKS(jwksFile string, settings map[string]string, timeout time.Duration) (*utils.JSONWebKeySet, string, error) {
+	switch {
+	case jwksFile != "":
+		data, err := os.ReadFile(jwksFile)
+		if err != nil {
+			return nil, jwksFile, err
+		}
+		jwks, err := utils.ParseJWKS(data)
+		return jwks, jwksFile, err
+	case settings["public-keys"] != "":
+		jwks, err := utils.ParseJWKS([]byte(settings["public-keys"]))
+		return jwks, "public-keys", err
+	case settings["jwks-uri"] != "":
+		jwks, err := fetchJWKS(settings["jwks-uri"], settings["ca-cert"], timeout)
+		return jwks, settings["jwks-uri"], err
+	default:
+		return nil, "", errors.New("neither jwks-uri nor public-keys is configured")
+	}
+}
+
+func fetchJWKS(jwksURI string, caCert string, timeout time.Duration) (*utils.JSONWebKeySet, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if caCert != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(caCert)) {
+			return nil, errors.New("ca-cert does not contain a valid PEM certificate")
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+	httpClient := &http.Client{Transport: transport, Timeout: timeout}
+
+	resp, err := httpClient.Get(jwksURI)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from %s: %s", jwksURI, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return utils.ParseJWKS(data)
+}
+
+func checkJWTSignature(token *utils.JWT, jwks *utils.JSONWebKeySet, jwksSource string, jwksErr error) jwtCheckResult {
+	result := jwtCheckResult{name: "Signature"}
+	if jwksErr != nil {
+		result.detail = fmt.Sprintf("unable to load the signing keys: %s", jwksErr)
+		return result
+	}
+	if token.Algorithm() == "" || token.Algorithm() == "none" {
+		result.detail = "token is not signed"
+		return result
+	}
+
+	kid := token.KeyID()
+	candidates := 0
+	var lastErr error
+	for _, key := range jwks.Keys {
+		if kid != "" && key.Kid != kid {
+			continue
+		}
+		candidates++
+		publicKey, err := key.PublicKey()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if err = utils.VerifyJWTSignature(token, publicKey); err != nil {
+			lastErr = err
+			continue
+		}
+		result.passed = true
+		result.detail = fmt.Sprintf("verified with key %q (%s) from %s", key.Kid, token.Algorithm(), jwksSource)
+		return result
+	}
+
+	if candidates == 0 {
+		result.detail = fmt.Sprintf("no key with ID %q found in %s", kid, jwksSource)
+	} else {
+		result.detail = fmt.Sprintf("no key from %s verifies the token: %s", jwksSource, lastErr)
+	}
+	return result
+}
+
+func checkJWTIssuer(token *utils.JWT, settings map[string]string) jwtCheckResult {
+	result := jwtCheckResult{name: "Issuer"}
+	issuer := settings["issuer"]
+	if issuer == "" {
+		if settings["public-keys"] != "" {
+			result.detail = "issuer must be configured when public-keys is used"
+			return result
+		}
+		result.skipped = true
+		result.detail = "no issuer configured"
+		return result
+	}
+
+	iss, _ := token.StringClaim("iss")
+	if iss != issuer {
+		result.detail = fmt.Sprintf("token issuer %q does not match the configured issuer %q", iss, issuer)
+		return result
+	}
+	result.passed = true
+	result.detail = fmt.Sprintf("%q", iss)
+	return result
+}
+
+func checkJWTAudience(token *utils.JWT, settings map[string]string) jwtCheckResult {
+	result := jwtCheckResult{name: "Audience"}
+	audience := settings["audience"]
+	if audience == "" {
+		result.skipped = true
+		result.detail = "no audience configured"
+		return result
+	}
+
+	for _, aud := range token.Audience() {
+		if aud == audience {
+			result.passed = true
+			result.detail = fmt.Sprintf("%q", aud)
+			return result
+		}
+	}
+	result.detail = fmt.Sprintf("token audience %q does not contain the configured audience %q", token.Audience(), audience)
+	return result
+}
+
+func checkJWTExpiry(token *utils.JWT, now time.Time) jwtCheckResult {
+	result := jwtCheckResult{name: "Expiry"}
+	exp, ok := token.TimeClaim("exp")
+	if !ok {
+		result.detail = "token has no 'exp' claim"
+		return result
+	}
+	if !now.Before(exp) {
+		result.detail = fmt.Sprintf("token expired at %s (%s ago)", exp.Format(time.RFC1123), now.Sub(exp).Round(time.Second))
+		return result
+	}
+	if nbf, ok := token.TimeClaim("nbf"); ok && now.Before(nbf) {
+		result.detail = fmt.Sprintf("token is not valid before %s", nbf.Format(time.RFC1123))
+		return result
+	}
+	result.passed = true
+	result.detail = fmt.Sprintf("token expires at %s (in %s)", exp.Format(time.RFC1123), exp.Sub(now).Round(time.Second))
+	return result
+}
+
+func checkJWTEnforcedClaims(token *utils.JWT, settings map[string]string) jwtCheckResult {
+	result := jwtCheckResult{name: "Enforced claims"}
+	if settings["enforced-claims"] == "" {
+		result.skipped = true
+		result.detail = "no enforced claims configured"
+		return result
+	}
+
+	aliases := map[string]string{}
+	for _, alias := range strings.Split(settings["claim-aliases"], ",") {
+		if name, claim, ok := strings.Cut(alias, ":"); ok {
+			aliases[strings.TrimSpace(name)] = strings.TrimSpace(claim)
+		}
+	}
+
+	var missing []string
+	for _, claim := range strings.Split(settings["enforced-claims"], ",") {
+		claim = strings.TrimSpace(claim)
+		if mapped, ok := aliases[claim]; ok {
+			claim = mapped
+		}
+		if claim != "" && !token.HasClaim(claim) {
+			missing = append(missing, claim)
+		}
+	}
+	if len(missing) > 0 {
+		result.detail = fmt.Sprintf("token is missing the claims: %s", strings.Join(missing, ", "))
+		return result
+	}
+	result.passed = true
+	result.detail = settings["enforced-claims"]
+	return result
+}
+
+// checkJWTIdentity resolves the host the token authenticates as, using the token-app-property
+// and identity-path settings or, when token-app-property isn't configured, the given host ID
+func checkJWTIdentity(token *utils.JWT, settings map[string]string, hostID string) (jwtCheckResult, string) {
+	result := jwtCheckResult{name: "Identity"}
+	property := settings["token-app-property"]
+	if property == "" {
+		if hostID == "" {
+			result.detail = "token-app-property is not configured, so a host ID must be provided (--host-id)"
+			return result, ""
+		}
+		result.passed = true
+		result.detail = fmt.Sprintf("host ID %q is provided by the caller", hostID)
+		return result, hostID
+	}
+
+	value, ok := token.StringClaim(property)
+	if !ok || value == "" {
+		result.detail = fmt.Sprintf("token has no string claim %q (token-app-property)", property)
+		return result, ""
+	}
+
+	identity := "host/" + strings.TrimPrefix(path.Join(settings["identity-path"], value), "/")
+	result.passed = true
+	result.detail = fmt.Sprintf("claim %q maps to %s", property, identity)
+	return result, identity
+}
+
+func runTestJWTCommand(cmd *cobra.Command, clientFactory authenticatorClientFactoryFunc) error {
+	cmdFlagVals, err := getTestJWTCmdFlagValues(cmd)
+	if err != nil {
+		return err
+	}
+	if cmdFlagVals.offline && cmdFlagVals.jwksFile == "" && cmdFlagVals.settings["public-keys"] == "" && cmdFlagVals.settings["jwks-uri"] == "" {
+		return errors.New("--offline requires --jwks-file or a public-keys or jwks-uri setting")
+	}
+
+	var rawToken []byte
+	if cmdFlagVals.jwtFile == "-" {
+		rawToken, err = io.ReadAll(cmd.InOrStdin())
+	} else {
+		rawToken, err = os.ReadFile(cmdFlagVals.jwtFile)
+	}
+	if err != nil {
+		return err
+	}
+
+	token, err := utils.ParseJWT(string(rawToken))
+	if err != nil {
+		cmd.Printf("FAIL  Format: %s\n", err)
+		return fmt.Errorf("%s does not contain a valid JWT", cmdFlagVals.jwtFile)
+	}
+
+	settings := map[string]string{}
+	if !cmdFlagVals.offline {
+		client, err := clientFactory(cmd)
+		if err != nil {
+			return err
+		}
+		settings, err = fetchJWTAuthenticatorSettings(client, cmdFlagVals.serviceID)
+		if err != nil {
+			return err
+		}
+	}
+	for name, value := range cmdFlagVals.settings {
+		settings[name] = value
+	}
+
+	timeout, err := clients.GetTimeout(cmd)
+	if err != nil {
+		return err
+	}
+	if timeout == 0 {
+		timeout = time.Minute
+	}
+	jwks, jwksSource, jwksErr := loadJWKS(cmdFlagVals.jwksFile, settings, timeout)
+
+	identityResult, identity := checkJWTIdentity(token, settings, cmdFlagVals.hostID)
+	results := []jwtCheckResult{
+		{name: "Format", passed: true, detail: fmt.Sprintf("%s token", token.Algorithm())},
+		checkJWTSignature(token, jwks, jwksSource, jwksErr),
+		checkJWTIssuer(token, settings),
+		checkJWTAudience(token, settings),
+		checkJWTExpiry(token, time.Now()),
+		checkJWTEnforcedClaims(token, settings),
+		identityResult,
+	}
+
+	failed := 0
+	for _, result := range results {
+		cmd.Printf("%s  %s: %s\n", result.status(), result.name, result.detail)
+		if !result.passed && !result.skipped {
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(results))
+	}
+	cmd.Printf("\nThe JWT would be accepted by authn-jwt/%s as %s\n", cmdFlagVals.serviceID, identity)
+	return nil
+}
+
+func newTestJWTCmd(clientFactory authenticatorClientFactoryFunc) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test-jwt",
+		Short: "Validate a JWT against an authn-jwt authenticator",
+		Long: `Validate a JWT against the configuration of an authn-jwt authenticator.
+
+The token is decoded and checked locally against the authenticator's variables (jwks-uri or public-keys, issuer, audience, enforced-claims, token-app-property and identity-path), reporting each check that would fail during authentication.
+
+The signing keys can be read from a local JWKS file with --jwks-file. Any configuration variable can be overridden with --setting. With --offline, Secrets Manager is not contacted and the configuration is taken from the flags only.
+
+Examples:
+
+- conjur authenticator test-jwt --service-id github --jwt-file token.jwt
+- conjur authenticator test-jwt -s github -f token.jwt --jwks-file jwks.json --offline --setting issuer=https://token.actions.githubusercontent.com --setting token-app-property=repository`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTestJWTCommand(cmd, clientFactory)
+		},
+	}
+
+	cmd.Flags().StringP("service-id", "s", "", "(Required) Service ID of the authn-jwt authenticator")
+	cmd.MarkFlagRequired("service-id")
+	cmd.Flags().StringP("jwt-file", "f", "", "(Required) Path to the JWT to validate, or '-' to read it from stdin")
+	cmd.MarkFlagRequired("jwt-file")
+	cmd.Flags().String("jwks-file", "", "Path to a local JWKS file to verify the token signature with")
+	cmd.Flags().String("host-id", "", "Host ID to authenticate as when token-app-property is not configured")
+	cmd.Flags().StringArray("setting", []string{}, "Override an authenticator variable in the format 'name=value' (can be repeated)")
+	cmd.Flags().Bool("offline", false, "Do not contact Secrets Manager, take the configuration from the flags only")
+
+	return cmd
+}
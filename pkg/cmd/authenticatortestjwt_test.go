@@ -0,0 +1,247 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi/response"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+const testJWTIssuer = "https://token.actions.githubusercontent.com"
+
+func signTestJWTForCmd(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]interface{}{"alg": "RS256", "kid": kid, "typ": "JWT"})
+	assert.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	assert.NoError(t, err)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func testJWKS(key *rsa.PrivateKey, kid string) string {
+	return fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":%q,"n":%q,"e":%q}]}`,
+		kid,
+		base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+		base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	)
+}
+
+func TestAuthenticatorTestJWTCmd(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+
+	validClaims := map[string]interface{}{
+		"iss":        testJWTIssuer,
+		"aud":        "conjur",
+		"exp":        time.Now().Add(time.Hour).Unix(),
+		"repository": "cyberark/conjur",
+		"ref":        "refs/heads/main",
+	}
+	expiredClaims := map[string]interface{}{}
+	for name, value := range validClaims {
+		expiredClaims[name] = value
+	}
+	expiredClaims["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	dir := t.TempDir()
+	writeTestFile := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+		return path
+	}
+	jwksFile := writeTestFile("jwks.json", testJWKS(key, "key-1"))
+	validJWTFile := writeTestFile("valid.jwt", signTestJWTForCmd(t, key, "key-1", validClaims))
+	expiredJWTFile := writeTestFile("expired.jwt", signTestJWTForCmd(t, key, "key-1", expiredClaims))
+	unknownKidJWTFile := writeTestFile("unknown-kid.jwt", signTestJWTForCmd(t, key, "key-2", validClaims))
+	malformedJWTFile := writeTestFile("malformed.jwt", "not-a-jwt")
+
+	serverVariables := map[string]string{
+		"conjur/authn-jwt/github/public-keys":        `{"type":"jwks","value":` + testJWKS(key, "key-1") + `}`,
+		"conjur/authn-jwt/github/issuer":             testJWTIssuer,
+		"conjur/authn-jwt/github/audience":           "conjur",
+		"conjur/authn-jwt/github/token-app-property": "repository",
+		"conjur/authn-jwt/github/identity-path":      "/github-apps",
+		"conjur/authn-jwt/github/enforced-claims":    "branch",
+		"conjur/authn-jwt/github/claim-aliases":      "branch:ref",
+	}
+
+	offlineArgs := func(jwtFile string, extra ...string) []string {
+		return append([]string{
+			"authenticator", "test-jwt", "-s", "github", "-f", jwtFile, "--offline",
+			"--jwks-file", jwksFile,
+			"--setting", "issuer=" + testJWTIssuer,
+			"--setting", "token-app-property=repository",
+		}, extra...)
+	}
+
+	testCases := []struct {
+		name               string
+		args               []string
+		variables          map[string]string
+		retrieveError      error
+		clientFactoryError error
+		assert             func(t *testing.T, stdout string, stderr string, err error)
+	}{
+		{
+			name: "test-jwt subcommand help",
+			args: []string{"authenticator", "test-jwt", "--help"},
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stdout, "HELP LONG")
+			},
+		},
+		{
+			name: "missing jwt file",
+			args: []string{"authenticator", "test-jwt", "-s", "github"},
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stderr, "Error: required flag(s) \"jwt-file\" not set\n")
+			},
+		},
+		{
+			name: "offline without keys",
+			args: []string{"authenticator", "test-jwt", "-s", "github", "-f", validJWTFile, "--offline"},
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stderr, "Error: --offline requires --jwks-file or a public-keys or jwks-uri setting\n")
+			},
+		},
+		{
+			name:               "offline accepts a valid token without contacting the server",
+			args:               offlineArgs(validJWTFile, "--setting", "identity-path=github-apps"),
+			clientFactoryError: fmt.Errorf("client should not be created"),
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "PASS  Format: RS256 token\n")
+				assert.Contains(t, stdout, "PASS  Signature: verified with key \"key-1\" (RS256)")
+				assert.Contains(t, stdout, "PASS  Issuer: \""+testJWTIssuer+"\"\n")
+				assert.Contains(t, stdout, "SKIP  Audience: no audience configured\n")
+				assert.Contains(t, stdout, "PASS  Identity: claim \"repository\" maps to host/github-apps/cyberark/conjur\n")
+				assert.Contains(t, stdout, "The JWT would be accepted by authn-jwt/github as host/github-apps/cyberark/conjur\n")
+			},
+		},
+		{
+			name: "expired token",
+			args: offlineArgs(expiredJWTFile),
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stdout, "FAIL  Expiry: token expired at")
+				assert.Contains(t, stderr, "Error: 1 of 7 checks failed\n")
+			},
+		},
+		{
+			name: "unknown key ID",
+			args: offlineArgs(unknownKidJWTFile),
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stdout, "FAIL  Signature: no key with ID \"key-2\" found in "+jwksFile+"\n")
+				assert.Contains(t, stderr, "Error: 1 of 7 checks failed\n")
+			},
+		},
+		{
+			name: "mismatched issuer and audience",
+			args: offlineArgs(validJWTFile, "--setting", "issuer=https://other.example.com", "--setting", "audience=other"),
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stdout, "FAIL  Issuer: token issuer \""+testJWTIssuer+"\" does not match the configured issuer \"https://other.example.com\"\n")
+				assert.Contains(t, stdout, "FAIL  Audience: token audience [\"conjur\"] does not contain the configured audience \"other\"\n")
+				assert.Contains(t, stderr, "Error: 2 of 7 checks failed\n")
+			},
+		},
+		{
+			name: "host id is required without token-app-property",
+			args: []string{"authenticator", "test-jwt", "-s", "github", "-f", validJWTFile, "--offline", "--jwks-file", jwksFile},
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stdout, "FAIL  Identity: token-app-property is not configured, so a host ID must be provided (--host-id)\n")
+			},
+		},
+		{
+			name: "host id is used without token-app-property",
+			args: []string{"authenticator", "test-jwt", "-s", "github", "-f", validJWTFile, "--offline", "--jwks-file", jwksFile, "--host-id", "host/myapp"},
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "The JWT would be accepted by authn-jwt/github as host/myapp\n")
+			},
+		},
+		{
+			name: "malformed token",
+			args: offlineArgs(malformedJWTFile),
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stdout, "FAIL  Format: token must have 3 parts separated by '.', found 1\n")
+				assert.Contains(t, stderr, "Error: "+malformedJWTFile+" does not contain a valid JWT\n")
+			},
+		},
+		{
+			name:      "reads the configuration from the server",
+			args:      []string{"authenticator", "test-jwt", "-s", "github", "-f", validJWTFile},
+			variables: serverVariables,
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "PASS  Signature: verified with key \"key-1\" (RS256) from public-keys\n")
+				assert.Contains(t, stdout, "PASS  Audience: \"conjur\"\n")
+				assert.Contains(t, stdout, "PASS  Enforced claims: branch\n")
+				assert.Contains(t, stdout, "The JWT would be accepted by authn-jwt/github as host/github-apps/cyberark/conjur\n")
+			},
+		},
+		{
+			name:      "settings override the server configuration",
+			args:      []string{"authenticator", "test-jwt", "-s", "github", "-f", validJWTFile, "--setting", "enforced-claims=environment"},
+			variables: serverVariables,
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stdout, "FAIL  Enforced claims: token is missing the claims: environment\n")
+				assert.Contains(t, stderr, "Error: 1 of 7 checks failed\n")
+			},
+		},
+		{
+			name:          "retrieve secret error",
+			args:          []string{"authenticator", "test-jwt", "-s", "github", "-f", validJWTFile},
+			retrieveError: fmt.Errorf("forbidden"),
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stderr, "Error: Unable to read conjur/authn-jwt/github/jwks-uri: forbidden\n")
+			},
+		},
+		{
+			name:               "client factory error",
+			args:               []string{"authenticator", "test-jwt", "-s", "github", "-f", validJWTFile},
+			clientFactoryError: fmt.Errorf("client factory error"),
+			assert: func(t *testing.T, stdout string, stderr string, err error) {
+				assert.Contains(t, stderr, "Error: client factory error\n")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockClient := mockAuthenticatorClient{
+				t: t,
+				retrieveSecret: func(t *testing.T, variableID string) ([]byte, error) {
+					if tc.retrieveError != nil {
+						return nil, tc.retrieveError
+					}
+					value, ok := tc.variables[variableID]
+					if !ok {
+						return nil, &response.ConjurError{Code: http.StatusNotFound}
+					}
+					return []byte(value), nil
+				},
+			}
+			cmd := newAuthenticatorCommand(
+				func(cmd *cobra.Command) (authenticatorClient, error) {
+					return mockClient, tc.clientFactoryError
+				})
+
+			stdout, stderr, err := executeCommandForTest(t, cmd, tc.args...)
+			tc.assert(t, stdout, stderr, err)
+		})
+	}
+}
@@ -0,0 +1,357 @@
+package cmd
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/prompts"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+type certCmdFuncs struct {
+	LoadAndValidateConjurConfig func(timeout time.Duration) (conjurapi.Config, error)
+	LoadCLIConfig               func() (clients.CLIConfig, error)
+	GetServerCert               func(config conjurapi.Config, cliConfig clients.CLIConfig, applianceUrl *url.URL) (utils.ServerCert, error)
+	AskToTrustCert              func(cert utils.ServerCert) error
+	SetConjurrcValue            func(key string, value string) error
+	UpdateCLIConfig             func(update func(cliConfig *clients.CLIConfig)) error
+}
+
+var defaultCertCmdFuncs = certCmdFuncs{
+	LoadAndValidateConjurConfig: clients.LoadAndValidateConjurConfig,
+	LoadCLIConfig:               clients.LoadCLIConfig,
+	GetServerCert:               getServerCert,
+	AskToTrustCert:              prompts.AskToTrustCert,
+	SetConjurrcValue:            clients.SetConjurrcValue,
+	UpdateCLIConfig:             clients.UpdateCLIConfig,
+}
+
+// storedCerts returns the certificates trusted for the server and where they are stored, or no
+// certificates when the server is trusted through the system's certificate authorities
+func storedCerts(config conjurapi.Config) (string, []*x509.Certificate, error) {
+	source := config.SSLCertPath
+	if source == "" {
+		source = "CONJUR_SSL_CERTIFICATE"
+	}
+	if !config.IsHttps() {
+		return source, nil, nil
+	}
+
+	data, err := config.ReadSSLCert()
+	if err != nil {
+		return source, nil, err
+	}
+	certs, err := utils.ParseCertificates(data)
+	if err != nil {
+		return source, nil, fmt.Errorf("Unable to read the certificate stored in %s: %s", source, err)
+	}
+	return source, certs, nil
+}
+
+// loadCertCmdConfig loads the configuration of the cert commands, which need an HTTPS appliance URL
+func loadCertCmdConfig(cmd *cobra.Command, funcs certCmdFuncs) (conjurapi.Config, clients.CLIConfig, *url.URL, error) {
+	timeout, err := clients.GetTimeout(cmd)
+	if err != nil {
+		return conjurapi.Config{}, clients.CLIConfig{}, nil, err
+	}
+	config, err := funcs.LoadAndValidateConjurConfig(timeout)
+	if err != nil {
+		return config, clients.CLIConfig{}, nil, err
+	}
+	cliConfig, err := funcs.LoadCLIConfig()
+	if err != nil {
+		return config, cliConfig, nil, err
+	}
+	if cliConfig.CertFingerprint != "" {
+		if cliConfig.CertFingerprint, err = utils.NormalizeFingerprint(cliConfig.CertFingerprint); err != nil {
+			return config, cliConfig, nil, err
+		}
+	}
+
+	applianceUrl, err := url.Parse(config.ApplianceURL)
+	if err != nil {
+		return config, cliConfig, nil, err
+	}
+	if applianceUrl.Scheme != "https" {
+		return config, cliConfig, nil, fmt.Errorf("Cannot fetch certificate from non-HTTPS URL %s", applianceUrl)
+	}
+	return config, cliConfig, applianceUrl, nil
+}
+
+// verifyServerCert checks that the server's certificate matches the pinned fingerprint and is
+// trusted through the stored certificates, or the system's certificate authorities when none is
+// stored. The outcome is printed with printf.
+func verifyServerCert(printf func(format string, a ...interface{}), cliConfig clients.CLIConfig, applianceUrl *url.URL, source string, certs []*x509.Certificate, live utils.ServerCert) error {
+	if cliConfig.CertFingerprint != "" && live.Fingerprint != cliConfig.CertFingerprint {
+		return fmt.Errorf("The certificate of %s has the fingerprint %s, which doesn't match the pinned fingerprint %s", applianceUrl.Host, live.Fingerprint, cliConfig.CertFingerprint)
+	}
+	if len(certs) == 0 {
+		if live.SelfSigned || live.UntrustedCA {
+			return errors.New("The server's certificate isn't trusted by the system's certificate authorities and no certificate is stored. Run 'conjur cert refresh' to review and trust it.")
+		}
+		printf("The server's certificate is trusted by the system's certificate authorities.\n")
+		return nil
+	}
+
+	stored := utils.CertificateFingerprint(certs[0])
+	if stored == live.Fingerprint {
+		printf("The server's certificate matches the certificate stored in %s.\n", source)
+		return nil
+	}
+	if live.IsTrustedBy(certs, applianceUrl.Hostname()) {
+		printf("The server's certificate is issued by a certificate authority stored in %s.\n", source)
+		return nil
+	}
+	printf("Stored certificate:  %s\n", stored)
+	printf("Server certificate:  %s\n", live.Fingerprint)
+	return fmt.Errorf("The server's certificate doesn't match the certificate stored in %s. Run 'conjur cert refresh' to review and trust the new certificate.", source)
+}
+
+func printCertExpiryWarning(cmd *cobra.Command, config conjurapi.Config, cliConfig clients.CLIConfig) {
+	if warning := clients.CertExpiryWarning(config, cliConfig, time.Now()); warning != "" {
+		cmd.PrintErrln(warning)
+	}
+}
+
+func describeExpiry(notAfter time.Time) string {
+	remaining := time.Until(notAfter)
+	if remaining <= 0 {
+		return fmt.Sprintf("%s (expired)", notAfter.Format(time.RFC1123))
+	}
+	return fmt.Sprintf("%s (expires in %d days)", notAfter.Format(time.RFC1123), int(remaining.Hours()/24))
+}
+
+func newCertCmd(funcs certCmdFuncs) *cobra.Command {
+	certCmd := &cobra.Command{
+		Use:   "cert",
+		Short: "Server certificate commands (show, check, refresh)",
+		Long: `Inspect and refresh the certificate trusted for the Secrets Manager server.
+
+The certificate is stored by 'conjur init' when the server's certificate is self-signed or issued by a certificate authority the system doesn't trust. When the server's certificate rotates, use 'cert check' to compare it with the stored one and 'cert refresh' to trust the new one, without reinitializing the CLI.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Print --help if called without subcommand
+			cmd.Help()
+		},
+	}
+
+	certCmd.AddCommand(newCertShowCmd(funcs))
+	certCmd.AddCommand(newCertCheckCmd(funcs))
+	certCmd.AddCommand(newCertRefreshCmd(funcs))
+
+	return certCmd
+}
+
+func newCertShowCmd(funcs certCmdFuncs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "show",
+		Short: "Show the stored server certificate",
+		Long: `Show the certificates stored for the server, with their issuer, expiry and SHA-256 fingerprint.
+
+Examples:
+- conjur cert show`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := funcs.LoadAndValidateConjurConfig(0)
+			if err != nil {
+				return err
+			}
+			cliConfig, err := funcs.LoadCLIConfig()
+			if err != nil {
+				return err
+			}
+
+			source, certs, err := storedCerts(config)
+			if err != nil {
+				return err
+			}
+			if len(certs) == 0 {
+				cmd.Println("No certificate is stored, the server's certificate is verified with the system's certificate authorities.")
+				return nil
+			}
+
+			cmd.Printf("Certificate stored in %s\n", source)
+			for i, cert := range certs {
+				cmd.Printf("\nCertificate %d of %d\n", i+1, len(certs))
+				cmd.Printf("  Subject:     %s\n", cert.Subject)
+				cmd.Printf("  Issuer:      %s\n", cert.Issuer)
+				cmd.Printf("  Not before:  %s\n", cert.NotBefore.Format(time.RFC1123))
+				cmd.Printf("  Not after:   %s\n", describeExpiry(cert.NotAfter))
+				cmd.Printf("  Fingerprint: %s\n", utils.CertificateFingerprint(cert))
+			}
+			printCertExpiryWarning(cmd, config, cliConfig)
+			return nil
+		},
+	}
+}
+
+func newCertCheckCmd(funcs certCmdFuncs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "check",
+		Short: "Check the server certificate against the stored one",
+		Long: `Fetch the server's certificate and compare it with the stored certificate. The command fails when they differ, or when the certificate doesn't match the fingerprint pinned with 'init --cert-fingerprint'.
+
+Examples:
+- conjur cert check`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, cliConfig, applianceUrl, err := loadCertCmdConfig(cmd, funcs)
+			if err != nil {
+				return err
+			}
+			source, certs, err := storedCerts(config)
+			if err != nil {
+				return err
+			}
+			live, err := funcs.GetServerCert(config, cliConfig, applianceUrl)
+			if err != nil {
+				return err
+			}
+			printCertExpiryWarning(cmd, config, cliConfig)
+
+			return verifyServerCert(cmd.Printf, cliConfig, applianceUrl, source, certs, live)
+		},
+	}
+}
+
+func newCertRefreshCmd(funcs certCmdFuncs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "refresh",
+		Short: "Trust the server's current certificate",
+		Long: `Fetch the server's certificate, show how it differs from the stored one and, once you trust it, write it to the certificate file. Only the certificate file and the cert_file setting of .conjurrc are updated.
+
+When the server presents the certificates of its CAs, you choose whether to trust the server's certificate only or one of the CAs, with which renewed server certificates stay trusted.
+
+When a fingerprint is pinned with 'init --cert-fingerprint', pass the fingerprint of the new certificate with --cert-fingerprint to pin it instead.
+
+Examples:
+- conjur cert refresh
+- conjur cert refresh --trust-anchor root
+- conjur cert refresh --cert-fingerprint 2A:82:49:...`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			certFilePath, err := cmd.Flags().GetString("cert-file")
+			if err != nil {
+				return err
+			}
+			certFingerprint, err := cmd.Flags().GetString("cert-fingerprint")
+			if err != nil {
+				return err
+			}
+			trustAnchor, err := cmd.Flags().GetString("trust-anchor")
+			if err != nil {
+				return err
+			}
+			if err = validateTrustAnchor(trustAnchor); err != nil {
+				return err
+			}
+			if certFingerprint != "" {
+				if certFingerprint, err = utils.NormalizeFingerprint(certFingerprint); err != nil {
+					return fmt.Errorf("Invalid --cert-fingerprint: %s", err)
+				}
+			}
+
+			config, cliConfig, applianceUrl, err := loadCertCmdConfig(cmd, funcs)
+			if err != nil {
+				return err
+			}
+			// The stored certificate may be unreadable, it's replaced anyway
+			_, certs, _ := storedCerts(config)
+			live, err := funcs.GetServerCert(config, cliConfig, applianceUrl)
+			if err != nil {
+				return err
+			}
+
+			stored := "(none)"
+			if len(certs) > 0 {
+				stored = fmt.Sprintf("%s  (expires %s)", utils.CertificateFingerprint(certs[0]), certs[0].NotAfter.Format(time.RFC1123))
+				if live.IsTrustedBy(certs, applianceUrl.Hostname()) {
+					cmd.Println("The stored certificate is up to date.")
+					return nil
+				}
+			} else if !live.SelfSigned && !live.UntrustedCA {
+				cmd.Println("The server's certificate is trusted by the system's certificate authorities, there is no certificate to refresh.")
+				return nil
+			}
+			cmd.Println("The server's certificate has changed:")
+			cmd.Printf("- %s\n", stored)
+			cmd.Printf("+ %s  (expires %s)\n", live.Fingerprint, live.ExpirationDate)
+
+			switch {
+			case certFingerprint != "":
+				if live.Fingerprint != certFingerprint {
+					return fmt.Errorf("The certificate of %s has the fingerprint %s, which doesn't match --cert-fingerprint %s", applianceUrl.Host, live.Fingerprint, certFingerprint)
+				}
+			case cliConfig.CertFingerprint != "" && live.Fingerprint != cliConfig.CertFingerprint:
+				return fmt.Errorf("The server's certificate doesn't match the pinned fingerprint %s. Verify the new fingerprint with your administrator and pass it with --cert-fingerprint.", cliConfig.CertFingerprint)
+			default:
+				if err = funcs.AskToTrustCert(live); err != nil {
+					return fmt.Errorf("Based on your selection, this certificate will not be trusted")
+				}
+			}
+
+			anchor, err := chooseTrustAnchor(live, trustAnchor, certFingerprint == "")
+			if err != nil {
+				return err
+			}
+			combinedCert, err := live.TrustBundle(anchor)
+			if err != nil {
+				return err
+			}
+			// SaaS also needs the certificate of Identity, see fetchCertIfNeeded
+			if strings.Contains(applianceUrl.Host, ".secretsmgr") {
+				combinedCert, err = appendConjurCloudCert(applianceUrl, clients.IdentityProxy(config, cliConfig), false, combinedCert, true)
+				if err != nil {
+					return err
+				}
+			}
+
+			if config.SSLCertPath != "" {
+				certFilePath = config.SSLCertPath
+			}
+			if certFilePath, err = filepath.Abs(certFilePath); err != nil {
+				return err
+			}
+			if err = os.WriteFile(certFilePath, []byte(combinedCert), 0600); err != nil {
+				return err
+			}
+			if certFilePath != config.SSLCertPath {
+				if err = funcs.SetConjurrcValue("cert_file", certFilePath); err != nil {
+					return err
+				}
+			}
+			if certFingerprint != "" {
+				err = funcs.UpdateCLIConfig(func(cliConfig *clients.CLIConfig) {
+					cliConfig.CertFingerprint = certFingerprint
+				})
+				if err != nil {
+					return err
+				}
+			}
+
+			cmd.Printf("Wrote certificate to %s\n", certFilePath)
+			return nil
+		},
+	}
+
+	userHomeDir, _ := os.UserHomeDir()
+	cmd.Flags().String("cert-file", filepath.Join(userHomeDir, "conjur-server.pem"), "File to write the server's certificate to when none is stored yet")
+	cmd.Flags().String("cert-fingerprint", "", "SHA-256 fingerprint the server's new certificate must have, which replaces the pinned fingerprint")
+	cmd.Flags().String("trust-anchor", "", "Certificate of the server's chain to trust: server, intermediate or root. You are prompted to choose if omitted.")
+
+	return cmd
+}
+
+func init() {
+	certCmd := newCertCmd(defaultCertCmdFuncs)
+	rootCmd.AddCommand(certCmd)
+}
@@ -0,0 +1,394 @@
+package cmd
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestServerCert returns a self-signed certificate expiring at notAfter
+func newTestServerCert(t *testing.T, notAfter time.Time) utils.ServerCert {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "conjur.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+
+	return utils.ServerCert{
+		Fingerprint:    utils.CertificateFingerprint(cert),
+		Issuer:         cert.Issuer,
+		ExpirationDate: cert.NotAfter.Format(time.RFC1123),
+		Cert:           string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})),
+		SelfSigned:     true,
+	}
+}
+
+// newTestCertChain returns the chain of a server certificate for localhost issued by an
+// intermediate CA, itself issued by a root CA, the server's certificate first
+func newTestCertChain(t *testing.T) ([]*x509.Certificate, *ecdsa.PrivateKey) {
+	chain := []*x509.Certificate{}
+	var parent *x509.Certificate
+	var parentKey *ecdsa.PrivateKey
+	for i, name := range []string{"Root CA", "Intermediate CA", "localhost"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 1)),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  name != "localhost",
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+			DNSNames:              []string{"localhost", "conjur.example.com"},
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		assert.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		assert.NoError(t, err)
+		chain = append([]*x509.Certificate{cert}, chain...)
+		parent, parentKey = cert, key
+	}
+	return chain, parentKey
+}
+
+func encodeTestCerts(certs ...*x509.Certificate) string {
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return string(data)
+}
+
+type certCmdTestCase struct {
+	name      string
+	args      []string
+	stored    *utils.ServerCert
+	live      utils.ServerCert
+	cliConfig clients.CLIConfig
+	trustErr  error
+	assert    func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig)
+}
+
+func TestCertCmd(t *testing.T) {
+	stored := newTestServerCert(t, time.Now().Add(365*24*time.Hour))
+	expiring := newTestServerCert(t, time.Now().Add(10*24*time.Hour))
+	live := newTestServerCert(t, time.Now().Add(2*365*24*time.Hour))
+
+	chain, _ := newTestCertChain(t)
+	renewed, _ := newTestCertChain(t)
+	storedCA := utils.ServerCert{Cert: encodeTestCerts(chain[1:]...)}
+	liveChain := utils.ServerCert{
+		Fingerprint:    utils.CertificateFingerprint(chain[0]),
+		ExpirationDate: chain[0].NotAfter.Format(time.RFC1123),
+		Cert:           encodeTestCerts(chain[0]),
+		UntrustedCA:    true,
+		Chain:          chain,
+	}
+	renewedChain := liveChain
+	renewedChain.Fingerprint = utils.CertificateFingerprint(renewed[0])
+	renewedChain.Cert = encodeTestCerts(renewed[0])
+	renewedChain.Chain = renewed
+
+	testCases := []certCmdTestCase{
+		{
+			name: "cert command help",
+			args: []string{"cert", "--help"},
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Contains(t, stdout, "HELP LONG")
+			},
+		},
+		{
+			name:   "cert show",
+			args:   []string{"cert", "show"},
+			stored: &stored,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "Certificate stored in "+certFile)
+				assert.Contains(t, stdout, "Certificate 1 of 1")
+				assert.Contains(t, stdout, "Subject:     CN=conjur.example.com")
+				assert.Contains(t, stdout, "(expires in 364 days)")
+				assert.Contains(t, stdout, "Fingerprint: "+stored.Fingerprint)
+				assert.Empty(t, stderr)
+			},
+		},
+		{
+			name:   "cert show warns about an expiring certificate",
+			args:   []string{"cert", "show"},
+			stored: &expiring,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stderr, "Warning: The server certificate stored in "+certFile+" expires on")
+				assert.Contains(t, stderr, "in 9 days")
+			},
+		},
+		{
+			name:      "cert show with the warning disabled",
+			args:      []string{"cert", "show"},
+			stored:    &expiring,
+			cliConfig: clients.CLIConfig{CertExpiryWarningDays: -1},
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Empty(t, stderr)
+			},
+		},
+		{
+			name: "cert show without a stored certificate",
+			args: []string{"cert", "show"},
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "No certificate is stored")
+			},
+		},
+		{
+			name:   "cert check with a matching certificate",
+			args:   []string{"cert", "check"},
+			stored: &stored,
+			live:   stored,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "The server's certificate matches the certificate stored in "+certFile)
+			},
+		},
+		{
+			name:   "cert check with a rotated certificate",
+			args:   []string{"cert", "check"},
+			stored: &stored,
+			live:   live,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "Stored certificate:  "+stored.Fingerprint)
+				assert.Contains(t, stdout, "Server certificate:  "+live.Fingerprint)
+				assert.Contains(t, stderr, "Run 'conjur cert refresh' to review and trust the new certificate")
+			},
+		},
+		{
+			name:      "cert check with a certificate that doesn't match the pin",
+			args:      []string{"cert", "check"},
+			stored:    &stored,
+			live:      stored,
+			cliConfig: clients.CLIConfig{CertFingerprint: live.Fingerprint},
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Error(t, err)
+				assert.Contains(t, stderr, "which doesn't match the pinned fingerprint "+live.Fingerprint)
+			},
+		},
+		{
+			name: "cert check without a stored certificate",
+			args: []string{"cert", "check"},
+			live: utils.ServerCert{Fingerprint: live.Fingerprint},
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "trusted by the system's certificate authorities")
+			},
+		},
+		{
+			name:   "cert check with a certificate issued by a stored CA",
+			args:   []string{"cert", "check"},
+			stored: &storedCA,
+			live:   liveChain,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "The server's certificate is issued by a certificate authority stored in "+certFile)
+			},
+		},
+		{
+			name:   "cert check with a certificate issued by another CA",
+			args:   []string{"cert", "check"},
+			stored: &storedCA,
+			live:   renewedChain,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Error(t, err)
+				assert.Contains(t, stderr, "doesn't match the certificate stored in "+certFile)
+			},
+		},
+		{
+			name:   "cert refresh with a certificate issued by a stored CA",
+			args:   []string{"cert", "refresh"},
+			stored: &storedCA,
+			live:   liveChain,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "The stored certificate is up to date")
+			},
+		},
+		{
+			name:   "cert refresh trusting the root CA",
+			args:   []string{"cert", "refresh", "--trust-anchor", "root"},
+			stored: &storedCA,
+			live:   renewedChain,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				data, _ := os.ReadFile(certFile)
+				assert.Equal(t, encodeTestCerts(renewed[1:]...), string(data))
+			},
+		},
+		{
+			name:   "cert refresh with an invalid --trust-anchor",
+			args:   []string{"cert", "refresh", "--trust-anchor", "leaf"},
+			stored: &stored,
+			live:   live,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Error(t, err)
+				assert.Contains(t, stderr, `Invalid --trust-anchor "leaf", must be one of server, intermediate, root`)
+			},
+		},
+		{
+			name:   "cert refresh with an up to date certificate",
+			args:   []string{"cert", "refresh"},
+			stored: &stored,
+			live:   stored,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "The stored certificate is up to date")
+			},
+		},
+		{
+			name:   "cert refresh with a rotated certificate",
+			args:   []string{"cert", "refresh"},
+			stored: &stored,
+			live:   live,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "- "+stored.Fingerprint)
+				assert.Contains(t, stdout, "+ "+live.Fingerprint)
+				assert.Contains(t, stdout, "Wrote certificate to "+certFile)
+				data, _ := os.ReadFile(certFile)
+				assert.Equal(t, live.Cert, string(data))
+				// The certificate file didn't move
+				assert.Empty(t, conjurrc)
+			},
+		},
+		{
+			name:     "cert refresh with an untrusted certificate",
+			args:     []string{"cert", "refresh"},
+			stored:   &stored,
+			live:     live,
+			trustErr: errors.New("not trusted"),
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Error(t, err)
+				assert.Contains(t, stderr, "this certificate will not be trusted")
+				data, _ := os.ReadFile(certFile)
+				assert.Equal(t, stored.Cert, string(data))
+			},
+		},
+		{
+			name:      "cert refresh with a pinned fingerprint",
+			args:      []string{"cert", "refresh"},
+			stored:    &stored,
+			live:      live,
+			cliConfig: clients.CLIConfig{CertFingerprint: stored.Fingerprint},
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Error(t, err)
+				assert.Contains(t, stderr, "pass it with --cert-fingerprint")
+				data, _ := os.ReadFile(certFile)
+				assert.Equal(t, stored.Cert, string(data))
+			},
+		},
+		{
+			name:      "cert refresh with a new pinned fingerprint",
+			args:      []string{"cert", "refresh", "--cert-fingerprint", live.Fingerprint},
+			stored:    &stored,
+			live:      live,
+			cliConfig: clients.CLIConfig{CertFingerprint: stored.Fingerprint},
+			trustErr:  errors.New("the certificate is trusted without prompting"),
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				data, _ := os.ReadFile(certFile)
+				assert.Equal(t, live.Cert, string(data))
+				assert.Equal(t, live.Fingerprint, cliConfig.CertFingerprint)
+			},
+		},
+		{
+			name:   "cert refresh with a wrong fingerprint",
+			args:   []string{"cert", "refresh", "--cert-fingerprint", stored.Fingerprint},
+			stored: &stored,
+			live:   live,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.Error(t, err)
+				assert.Contains(t, stderr, "which doesn't match --cert-fingerprint "+stored.Fingerprint)
+			},
+		},
+		{
+			name: "cert refresh without a stored certificate",
+			args: []string{"cert", "refresh"},
+			live: live,
+			assert: func(t *testing.T, stdout, stderr string, err error, certFile string, conjurrc map[string]string, cliConfig clients.CLIConfig) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "- (none)")
+				assert.Equal(t, map[string]string{"cert_file": certFile}, conjurrc)
+				data, _ := os.ReadFile(certFile)
+				assert.Equal(t, live.Cert, string(data))
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			certFile := filepath.Join(t.TempDir(), "conjur-server.pem")
+			config := conjurapi.Config{Account: "conjur", ApplianceURL: "https://conjur.example.com"}
+			if tc.stored != nil {
+				assert.NoError(t, os.WriteFile(certFile, []byte(tc.stored.Cert), 0600))
+				config.SSLCertPath = certFile
+			}
+			conjurrc := map[string]string{}
+			cliConfig := tc.cliConfig
+
+			cmd := newCertCmd(certCmdFuncs{
+				LoadAndValidateConjurConfig: func(time.Duration) (conjurapi.Config, error) {
+					return config, nil
+				},
+				LoadCLIConfig: func() (clients.CLIConfig, error) {
+					return cliConfig, nil
+				},
+				GetServerCert: func(config conjurapi.Config, cliConfig clients.CLIConfig, applianceUrl *url.URL) (utils.ServerCert, error) {
+					assert.Equal(t, "conjur.example.com", applianceUrl.Host)
+					return tc.live, nil
+				},
+				AskToTrustCert: func(cert utils.ServerCert) error {
+					return tc.trustErr
+				},
+				SetConjurrcValue: func(key string, value string) error {
+					conjurrc[key] = value
+					return nil
+				},
+				UpdateCLIConfig: func(update func(cliConfig *clients.CLIConfig)) error {
+					update(&cliConfig)
+					return nil
+				},
+			})
+
+			args := tc.args
+			if tc.stored == nil && tc.args[1] == "refresh" {
+				args = append(args, "--cert-file", certFile)
+			}
+			stdout, stderr, err := executeCommandForTest(t, cmd, args...)
+			tc.assert(t, stdout, stderr, err, certFile, conjurrc, cliConfig)
+		})
+	}
+}
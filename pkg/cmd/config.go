@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+
+	"github.com/spf13/cobra"
+)
+
+type configCmdFuncs struct {
+	LoadConfig        func() (conjurapi.Config, error)
+	LoadCLIConfig     func() (clients.CLIConfig, error)
+	SetConjurrcValue  func(key string, value string) error
+	GetServerCert     func(config conjurapi.Config, cliConfig clients.CLIConfig, applianceUrl *url.URL) (utils.ServerCert, error)
+	CheckConnectivity func(config conjurapi.Config, cliConfig clients.CLIConfig) error
+}
+
+var defaultConfigCmdFuncs = configCmdFuncs{
+	LoadConfig:        conjurapi.LoadConfig,
+	LoadCLIConfig:     clients.LoadCLIConfig,
+	SetConjurrcValue:  clients.SetConjurrcValue,
+	GetServerCert:     getServerCert,
+	CheckConnectivity: clients.CheckConnectivity,
+}
+
+// loadEffectiveConfig loads the configuration the other commands run with, without validating it
+func loadEffectiveConfig(cmd *cobra.Command, funcs configCmdFuncs) (conjurapi.Config, clients.CLIConfig, time.Duration, error) {
+	timeout, err := clients.GetTimeout(cmd)
+	if err != nil {
+		return conjurapi.Config{}, clients.CLIConfig{}, 0, err
+	}
+	config, err := funcs.LoadConfig()
+	if err != nil {
+		return config, clients.CLIConfig{}, 0, err
+	}
+	if timeout > 0 {
+		config.HTTPTimeout = int(timeout.Seconds())
+	}
+	cliConfig, err := funcs.LoadCLIConfig()
+	return config, cliConfig, timeout, err
+}
+
+func newConfigCmd(funcs configCmdFuncs) *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config",
+		Short: "Configuration commands (view, set, validate)",
+		Long: `View, edit and validate the configuration of the CLI.
+
+The configuration is merged from the system-wide conjur.conf, .conjurrc (or the file $CONJURRC points to), the CONJUR_* environment variables and the --timeout flag, by increasing precedence.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Print --help if called without subcommand
+			cmd.Help()
+		},
+	}
+
+	configCmd.AddCommand(newConfigViewCmd(funcs))
+	configCmd.AddCommand(newConfigSetCmd(funcs))
+	configCmd.AddCommand(newConfigValidateCmd(funcs))
+
+	return configCmd
+}
+
+func newConfigViewCmd(funcs configCmdFuncs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "view",
+		Short: "Show the effective configuration",
+		Long: `Show the settings in effect, each with where its value comes from: the --timeout flag, an environment variable, .conjurrc, the system-wide conjur.conf or the default. The credentials of proxies are redacted.
+
+Examples:
+- conjur config view
+- CONJUR_AUTHN_TYPE=ldap conjur config view`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, cliConfig, timeout, err := loadEffectiveConfig(cmd, funcs)
+			if err != nil {
+				return err
+			}
+			settings, err := clients.EffectiveSettings(config, cliConfig, timeout)
+			if err != nil {
+				return err
+			}
+
+			keyWidth, valueWidth := len("KEY"), len("VALUE")
+			for _, setting := range settings {
+				keyWidth = max(keyWidth, len(setting.Key))
+				valueWidth = max(valueWidth, len(setting.Value))
+			}
+			cmd.Printf("%-*s  %-*s  %s\n", keyWidth, "KEY", valueWidth, "VALUE", "SOURCE")
+			for _, setting := range settings {
+				cmd.Printf("%-*s  %-*s  %s\n", keyWidth, setting.Key, valueWidth, setting.Value, setting.Source)
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigSetCmd(funcs configCmdFuncs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Set a setting in .conjurrc",
+		Long: `Set a setting in .conjurrc, or the file $CONJURRC points to, leaving the rest of the file as is. The value is validated first and paths are made absolute.
+
+Environment variables still take precedence over the value written.
+
+Examples:
+- conjur config set authn_type ldap
+- conjur config set service_id my-ldap
+- conjur config set http_timeout 120`,
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			setting, err := clients.LookupSetting(args[0])
+			if err != nil {
+				return err
+			}
+			value, err := setting.Normalize(args[1])
+			if err != nil {
+				return err
+			}
+			if err = funcs.SetConjurrcValue(setting.Key, value); err != nil {
+				return err
+			}
+
+			cmd.Printf("Set %s to %s in %s\n", setting.Key, value, clients.ConjurrcPath())
+			if name := setting.EnvVar(); name != "" {
+				cmd.PrintErrf("Warning: %s is set and takes precedence over %s\n", name, setting.Key)
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigValidateCmd(funcs configCmdFuncs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate",
+		Short: "Validate the configuration and the connection to the server",
+		Long: `Validate the effective configuration, then check that the server's certificate is trusted and that the server can be reached, the way the other commands reach it.
+
+The command fails on the first check that doesn't pass.
+
+Examples:
+- conjur config validate`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, cliConfig, _, err := loadEffectiveConfig(cmd, funcs)
+			if err != nil {
+				return err
+			}
+			if err = clients.ValidateConfig(config, cliConfig); err != nil {
+				return fmt.Errorf("The configuration is invalid: %s", err)
+			}
+			cmd.Println("The configuration is valid.")
+
+			applianceUrl, err := url.Parse(config.ApplianceURL)
+			if err != nil {
+				return err
+			}
+			if applianceUrl.Scheme == "https" {
+				if cliConfig.CertFingerprint != "" {
+					if cliConfig.CertFingerprint, err = utils.NormalizeFingerprint(cliConfig.CertFingerprint); err != nil {
+						return err
+					}
+				}
+				source, certs, err := storedCerts(config)
+				if err != nil {
+					return err
+				}
+				live, err := funcs.GetServerCert(config, cliConfig, applianceUrl)
+				if err != nil {
+					return err
+				}
+				printCertExpiryWarning(cmd, config, cliConfig)
+				if err = verifyServerCert(cmd.Printf, cliConfig, applianceUrl, source, certs, live); err != nil {
+					return err
+				}
+			}
+
+			if err = funcs.CheckConnectivity(config, cliConfig); err != nil {
+				return err
+			}
+			cmd.Printf("Connected to %s.\n", config.ApplianceURL)
+			return nil
+		},
+	}
+}
+
+func init() {
+	configCmd := newConfigCmd(defaultConfigCmdFuncs)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/prompts"
+
+	"github.com/spf13/cobra"
+)
+
+type credentialsCmdFuncs struct {
+	LoadAndValidateConjurConfig func(timeout time.Duration) (conjurapi.Config, error)
+	MigrateCredentials          func(config conjurapi.Config, to string) (int, error)
+	AskForPassphrase            func() (string, error)
+}
+
+var defaultCredentialsCmdFuncs = credentialsCmdFuncs{
+	LoadAndValidateConjurConfig: clients.LoadAndValidateConjurConfig,
+	MigrateCredentials:          clients.MigrateCredentials,
+	AskForPassphrase:            prompts.AskForPassphrase,
+}
+
+func newCredentialsCmd(funcs credentialsCmdFuncs) *cobra.Command {
+	credentialsCmd := &cobra.Command{
+		Use:   "credentials",
+		Short: "Credential storage commands (migrate)",
+		Run: func(cmd *cobra.Command, args []string) {
+			// Print --help if called without subcommand
+			cmd.Help()
+		},
+	}
+
+	credentialsCmd.AddCommand(newCredentialsMigrateCmd(funcs))
+
+	return credentialsCmd
+}
+
+func newCredentialsMigrateCmd(funcs credentialsCmdFuncs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Move the cached credentials to another credential storage",
+		Long: `Move the cached credentials, including those of the other cached identities, to another credential storage and use it from now on.
+
+The credential storages are:
+- keyring: the operating system user's credential storage
+- netrc: a plaintext .netrc file, which Summon and other tools can read
+- encrypted-file: a file encrypted with a key derived from a passphrase, or from the machine secret (/etc/machine-id) by default. It is stored at ~/.conjur-credentials, which can be changed with credentials_file in .conjurrc or CONJUR_CREDENTIALS_FILE. This is meant for servers without a keyring. The machine secret keeps the credentials from being used on another machine, while the passphrase also protects them from other users of the machine. Provide the passphrase with CONJUR_CREDENTIALS_PASSPHRASE, or you will be prompted for it. The encrypted file can't be used with OIDC authentication.
+
+Examples:
+
+- conjur credentials migrate --to encrypted-file
+- conjur credentials migrate --to encrypted-file --passphrase
+- conjur credentials migrate --to keyring`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			to, err := cmd.Flags().GetString("to")
+			if err != nil {
+				return err
+			}
+			passphrase, err := cmd.Flags().GetBool("passphrase")
+			if err != nil {
+				return err
+			}
+			if to == "" {
+				return fmt.Errorf("Must specify the credential storage to migrate to with --to")
+			}
+			if passphrase && to != clients.CredentialStorageEncryptedFile {
+				return fmt.Errorf("--passphrase can only be used with --to encrypted-file")
+			}
+
+			config, err := funcs.LoadAndValidateConjurConfig(0)
+			if err != nil {
+				return err
+			}
+
+			if passphrase && os.Getenv("CONJUR_CREDENTIALS_PASSPHRASE") == "" {
+				value, err := funcs.AskForPassphrase()
+				if err != nil {
+					return err
+				}
+				clients.SetCredentialsPassphrase(value)
+			}
+
+			migrated, err := funcs.MigrateCredentials(config, to)
+			if err != nil {
+				return err
+			}
+
+			if os.Getenv("CONJUR_CREDENTIAL_STORAGE") != "" {
+				cmd.PrintErrln("Warning: CONJUR_CREDENTIAL_STORAGE is set and takes precedence over the credential storage in .conjurrc")
+			}
+			cmd.Printf("Moved %d cached credentials to %s\n", migrated, to)
+			return nil
+		},
+	}
+
+	cmd.Flags().String("to", "", "The credential storage to move the credentials to: keyring, netrc or encrypted-file")
+	cmd.Flags().Bool("passphrase", false, "Encrypt the credentials file with a passphrase rather than the machine secret, which every local user can read and only prevents copying the file to another machine")
+
+	return cmd
+}
+
+func init() {
+	credentialsCmd := newCredentialsCmd(defaultCredentialsCmdFuncs)
+	rootCmd.AddCommand(credentialsCmd)
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/stretchr/testify/assert"
+)
+
+var credentialsCmdTestCases = []struct {
+	name               string
+	args               []string
+	migrateCredentials func(t *testing.T, to string) (int, error)
+	askForPassphrase   func() (string, error)
+	assert             func(t *testing.T, stdout string, stderr string, err error)
+}{
+	{
+		name: "credentials command help",
+		args: []string{"credentials", "--help"},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stdout, "HELP LONG")
+		},
+	},
+	{
+		name: "credentials migrate",
+		args: []string{"credentials", "migrate", "--to", "encrypted-file"},
+		migrateCredentials: func(t *testing.T, to string) (int, error) {
+			assert.Equal(t, "encrypted-file", to)
+			return 2, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Equal(t, "Moved 2 cached credentials to encrypted-file\n", stdout)
+		},
+	},
+	{
+		name: "credentials migrate with a passphrase",
+		args: []string{"credentials", "migrate", "--to", "encrypted-file", "--passphrase"},
+		askForPassphrase: func() (string, error) {
+			return "passphrase", nil
+		},
+		migrateCredentials: func(t *testing.T, to string) (int, error) {
+			return 1, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "Moved 1 cached credentials")
+		},
+	},
+	{
+		name: "credentials migrate with a passphrase to another storage",
+		args: []string{"credentials", "migrate", "--to", "keyring", "--passphrase"},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.EqualError(t, err, "--passphrase can only be used with --to encrypted-file")
+		},
+	},
+	{
+		name: "credentials migrate without --to",
+		args: []string{"credentials", "migrate"},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.EqualError(t, err, "Must specify the credential storage to migrate to with --to")
+		},
+	},
+	{
+		name: "credentials migrate returns error",
+		args: []string{"credentials", "migrate", "--to", "netrc"},
+		migrateCredentials: func(t *testing.T, to string) (int, error) {
+			return 0, errors.New("Credentials are already stored in netrc")
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Error(t, err)
+			assert.Equal(t, "Error: Credentials are already stored in netrc\n", stderr)
+		},
+	},
+}
+
+func TestCredentialsCmd(t *testing.T) {
+	t.Setenv("CONJUR_CREDENTIALS_PASSPHRASE", "")
+	t.Setenv("CONJUR_CREDENTIAL_STORAGE", "")
+
+	for _, tc := range credentialsCmdTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newCredentialsCmd(credentialsCmdFuncs{
+				LoadAndValidateConjurConfig: func(time.Duration) (conjurapi.Config, error) {
+					return defaultConjurConfig, nil
+				},
+				MigrateCredentials: func(config conjurapi.Config, to string) (int, error) {
+					return tc.migrateCredentials(t, to)
+				},
+				AskForPassphrase: tc.askForPassphrase,
+			})
+
+			stdout, stderr, err := executeCommandForTest(t, cmd, tc.args...)
+			tc.assert(t, stdout, stderr, err)
+		})
+	}
+}
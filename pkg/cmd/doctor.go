@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"github.com/cyberark/conjur-cli-go/pkg/version"
+
+	"github.com/spf13/cobra"
+)
+
+type doctorClient interface {
+	RefreshToken() error
+	WhoAmI() ([]byte, error)
+}
+
+type doctorCmdFuncs struct {
+	LoadAndValidateConjurConfig func(timeout time.Duration) (conjurapi.Config, error)
+	LoadCLIConfig               func() (clients.CLIConfig, error)
+	LookupHost                  func(host string) ([]string, error)
+	GetServerCert               func(config conjurapi.Config, cliConfig clients.CLIConfig, applianceUrl *url.URL) (utils.ServerCert, error)
+	CheckConnectivity           func(config conjurapi.Config, cliConfig clients.CLIConfig) error
+	CredentialsSource           func(config conjurapi.Config, cliConfig clients.CLIConfig) (string, error)
+	ClientFactory               func(cmd *cobra.Command) (doctorClient, error)
+}
+
+var defaultDoctorCmdFuncs = doctorCmdFuncs{
+	LoadAndValidateConjurConfig: clients.LoadAndValidateConjurConfig,
+	LoadCLIConfig:               clients.LoadCLIConfig,
+	LookupHost:                  net.LookupHost,
+	GetServerCert:               getServerCert,
+	CheckConnectivity:           clients.CheckConnectivity,
+	CredentialsSource:           clients.CredentialsSource,
+	ClientFactory: func(cmd *cobra.Command) (doctorClient, error) {
+		return clients.AuthenticatedConjurClientForCommand(cmd)
+	},
+}
+
+// The statuses of the doctor checks
+const (
+	doctorPass = "pass"
+	doctorWarn = "warn"
+	doctorFail = "fail"
+	doctorSkip = "skip"
+)
+
+// doctorCheck is the outcome of a doctor check, with a hint to fix it when it doesn't pass
+type doctorCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// doctorReport is the report of the doctor command, as printed with --json
+type doctorReport struct {
+	Version      string        `json:"version"`
+	Platform     string        `json:"platform"`
+	ApplianceURL string        `json:"appliance_url,omitempty"`
+	AuthnType    string        `json:"authn_type,omitempty"`
+	Checks       []doctorCheck `json:"checks"`
+}
+
+// count returns the number of checks with the given status
+func (r doctorReport) count(status string) int {
+	n := 0
+	for _, check := range r.Checks {
+		if check.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// doctor runs the checks in order, skipping the checks whose dependencies didn't pass
+type doctor struct {
+	cmd       *cobra.Command
+	funcs     doctorCmdFuncs
+	report    doctorReport
+	config    conjurapi.Config
+	cliConfig clients.CLIConfig
+	url       *url.URL
+	// statuses are the statuses of the checks run so far, by name
+	statuses map[string]string
+}
+
+// run runs check, unless one of the checks it depends on failed or was skipped
+func (d *doctor) run(name string, dependsOn []string, check func() doctorCheck) {
+	result := doctorCheck{}
+	for _, dependency := range dependsOn {
+		if status := d.statuses[dependency]; status == doctorFail || status == doctorSkip {
+			result = doctorCheck{Status: doctorSkip, Message: fmt.Sprintf("Skipped because the %s check didn't pass", dependency)}
+			break
+		}
+	}
+	if result.Status == "" {
+		result = check()
+	}
+	result.Name = name
+	d.statuses[name] = result.Status
+	d.report.Checks = append(d.report.Checks, result)
+}
+
+func (d *doctor) checkConfig() doctorCheck {
+	timeout, err := clients.GetTimeout(d.cmd)
+	if err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error()}
+	}
+	d.config, err = d.funcs.LoadAndValidateConjurConfig(timeout)
+	if err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Run 'conjur config view' to review the configuration, or 'conjur init' to recreate it"}
+	}
+	d.report.ApplianceURL = d.config.ApplianceURL
+	d.report.AuthnType = d.config.AuthnType
+	if d.cliConfig, err = d.funcs.LoadCLIConfig(); err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Check the syntax of " + clients.ConjurrcPath()}
+	}
+	if d.cliConfig.CertFingerprint != "" {
+		// Validated with the rest of the configuration
+		d.cliConfig.CertFingerprint, _ = utils.NormalizeFingerprint(d.cliConfig.CertFingerprint)
+	}
+	if d.url, err = url.Parse(d.config.ApplianceURL); err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Fix appliance_url with 'conjur config set appliance_url <url>'"}
+	}
+	return doctorCheck{Status: doctorPass, Message: fmt.Sprintf("The configuration is valid, the server is %s", d.config.ApplianceURL)}
+}
+
+func (d *doctor) checkDNS() doctorCheck {
+	host := d.url.Hostname()
+	if net.ParseIP(host) != nil {
+		return doctorCheck{Status: doctorPass, Message: fmt.Sprintf("%s is an IP address", host)}
+	}
+	addrs, err := d.funcs.LookupHost(host)
+	if err != nil {
+		if d.config.Proxy != "" {
+			// The proxy resolves the host names of the requests it forwards
+			return doctorCheck{Status: doctorWarn, Message: fmt.Sprintf("Unable to resolve %s: %s", host, err), Hint: "The proxy must resolve it instead"}
+		}
+		return doctorCheck{Status: doctorFail, Message: fmt.Sprintf("Unable to resolve %s: %s", host, err), Hint: "Check the host name in appliance_url and the DNS settings of this machine"}
+	}
+	return doctorCheck{Status: doctorPass, Message: fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", "))}
+}
+
+func (d *doctor) checkCertificate() doctorCheck {
+	if d.url.Scheme != "https" {
+		return doctorCheck{Status: doctorWarn, Message: "The appliance URL doesn't use HTTPS, the connection to the server isn't encrypted", Hint: "Use an https:// appliance URL"}
+	}
+	source, certs, err := storedCerts(d.config)
+	if err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Run 'conjur cert refresh' to store the server's certificate again"}
+	}
+	live, err := d.funcs.GetServerCert(d.config, d.cliConfig, d.url)
+	if err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Check that the server listens on " + d.url.Host + " and the proxy settings"}
+	}
+
+	var out strings.Builder
+	printf := func(format string, a ...interface{}) { fmt.Fprintf(&out, format, a...) }
+	if err = verifyServerCert(printf, d.cliConfig, d.url, source, certs, live); err != nil {
+		// The fingerprints of the stored and the server's certificates are printed on lines of their own
+		message := err.Error()
+		var details []string
+		for _, line := range strings.Split(strings.TrimSpace(out.String()), "\n") {
+			if line != "" {
+				details = append(details, strings.Join(strings.Fields(line), " "))
+			}
+		}
+		if len(details) > 0 {
+			message = fmt.Sprintf("%s (%s)", message, strings.Join(details, ", "))
+		}
+		return doctorCheck{Status: doctorFail, Message: message, Hint: "Verify the server's certificate with your administrator"}
+	}
+	message := strings.TrimSpace(out.String())
+	if warning := clients.CertExpiryWarning(d.config, d.cliConfig, time.Now()); warning != "" {
+		return doctorCheck{Status: doctorWarn, Message: message + " " + strings.TrimPrefix(warning, "Warning: "), Hint: "Run 'conjur cert refresh' once the server's certificate is renewed"}
+	}
+	return doctorCheck{Status: doctorPass, Message: message}
+}
+
+func (d *doctor) checkConnectivity() doctorCheck {
+	if err := d.funcs.CheckConnectivity(d.config, d.cliConfig); err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Check the proxy settings and that no firewall blocks the connection"}
+	}
+	return doctorCheck{Status: doctorPass, Message: fmt.Sprintf("The server responds on %s", d.config.ApplianceURL)}
+}
+
+func (d *doctor) checkCredentials() doctorCheck {
+	source, err := d.funcs.CredentialsSource(d.config, d.cliConfig)
+	if err != nil {
+		return doctorCheck{Status: doctorFail, Message: fmt.Sprintf("Unable to read the cached credentials: %s", err), Hint: "Run 'conjur login' to cache them again"}
+	}
+	if source == "" {
+		return doctorCheck{Status: doctorFail, Message: "No credentials are cached", Hint: "Run 'conjur login'"}
+	}
+	return doctorCheck{Status: doctorPass, Message: fmt.Sprintf("Authenticating with %s", source)}
+}
+
+func (d *doctor) checkAuthentication() (doctorClient, doctorCheck) {
+	client, err := d.funcs.ClientFactory(d.cmd)
+	if err == nil {
+		err = client.RefreshToken()
+	}
+	if err != nil {
+		return nil, doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Run 'conjur login' to renew the credentials, or check the authenticator settings with 'conjur config view'"}
+	}
+	return client, doctorCheck{Status: doctorPass, Message: "Obtained an access token"}
+}
+
+func (d *doctor) checkWhoAmI(client doctorClient) doctorCheck {
+	data, err := client.WhoAmI()
+	if err != nil {
+		return doctorCheck{Status: doctorFail, Message: err.Error(), Hint: "Check that the access token is accepted by the server"}
+	}
+	var whoami struct {
+		Account  string `json:"account"`
+		Username string `json:"username"`
+	}
+	if err = json.Unmarshal(data, &whoami); err != nil {
+		return doctorCheck{Status: doctorFail, Message: fmt.Sprintf("Unexpected response: %s", err)}
+	}
+	return doctorCheck{Status: doctorPass, Message: fmt.Sprintf("Logged in as %s in account %s", whoami.Username, whoami.Account)}
+}
+
+func (d *doctor) runChecks() {
+	d.statuses = map[string]string{}
+	d.report.Version = fmt.Sprintf("%s-%s", version.Version, version.Tag)
+	d.report.Platform = runtime.GOOS + "/" + runtime.GOARCH
+
+	d.run("config", nil, d.checkConfig)
+	d.run("dns", []string{"config"}, d.checkDNS)
+	d.run("tls", []string{"dns"}, d.checkCertificate)
+	d.run("connectivity", []string{"tls"}, d.checkConnectivity)
+	// The credentials are checked even when the server can't be reached
+	d.run("credentials", []string{"config"}, d.checkCredentials)
+	var client doctorClient
+	d.run("authentication", []string{"connectivity", "credentials"}, func() doctorCheck {
+		var check doctorCheck
+		client, check = d.checkAuthentication()
+		return check
+	})
+	d.run("whoami", []string{"authentication"}, func() doctorCheck { return d.checkWhoAmI(client) })
+}
+
+func (d *doctor) print(jsonOutput bool) error {
+	if jsonOutput {
+		data, err := json.MarshalIndent(d.report, "", "  ")
+		if err != nil {
+			return err
+		}
+		d.cmd.Println(string(data))
+		return nil
+	}
+
+	for _, check := range d.report.Checks {
+		d.cmd.Printf("[%s] %-14s %s\n", strings.ToUpper(check.Status), check.Name, check.Message)
+		if check.Hint != "" && check.Status != doctorPass {
+			d.cmd.Printf("       %-14s Hint: %s\n", "", check.Hint)
+		}
+	}
+	d.cmd.Printf("\n%d passed, %d warned, %d failed, %d skipped\n",
+		d.report.count(doctorPass), d.report.count(doctorWarn), d.report.count(doctorFail), d.report.count(doctorSkip))
+	return nil
+}
+
+func newDoctorCmd(funcs doctorCmdFuncs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the configuration and the connection to the server",
+		Long: `Run end-to-end diagnostics, in order: the configuration is valid, the server's host name resolves, its TLS certificate is trusted, the server can be reached, credentials are available, authentication succeeds and whoami answers.
+
+Each check passes, warns or fails with a hint to fix it. The checks depending on a failed one are skipped. The command fails when any check fails. Nothing is prompted for, run 'conjur login' first when no credentials are cached.
+
+Use --json to attach the report to a support ticket. It doesn't contain any credentials.
+
+Examples:
+- conjur doctor
+- conjur doctor --json > doctor.json`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOutput, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+
+			d := &doctor{cmd: cmd, funcs: funcs}
+			d.runChecks()
+			if err = d.print(jsonOutput); err != nil {
+				return err
+			}
+			if failed := d.report.count(doctorFail); failed > 0 {
+				return fmt.Errorf("%d of %d checks failed", failed, len(d.report.Checks))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "Output the report as JSON")
+
+	return cmd
+}
+
+func init() {
+	doctorCmd := newDoctorCmd(defaultDoctorCmdFuncs)
+	rootCmd.AddCommand(doctorCmd)
+}
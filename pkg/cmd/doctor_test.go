@@ -0,0 +1,244 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+type mockDoctorClient struct {
+	refreshErr error
+	whoami     []byte
+	whoamiErr  error
+}
+
+func (c mockDoctorClient) RefreshToken() error {
+	return c.refreshErr
+}
+
+func (c mockDoctorClient) WhoAmI() ([]byte, error) {
+	return c.whoami, c.whoamiErr
+}
+
+func TestDoctorCmd(t *testing.T) {
+	stored := newTestServerCert(t, time.Now().Add(365*24*time.Hour))
+	rotated := newTestServerCert(t, time.Now().Add(365*24*time.Hour))
+	expiring := newTestServerCert(t, time.Now().Add(10*24*time.Hour))
+	whoami := []byte(`{"account":"myorg","username":"alice","client_ip":"10.0.0.1"}`)
+
+	testCases := []struct {
+		name         string
+		args         []string
+		config       conjurapi.Config
+		configErr    error
+		lookupErr    error
+		stored       *utils.ServerCert
+		live         utils.ServerCert
+		connectivity error
+		credentials  string
+		client       mockDoctorClient
+		assert       func(t *testing.T, stdout, stderr string, err error)
+	}{
+		{
+			name:        "doctor passes every check",
+			args:        []string{"doctor"},
+			stored:      &stored,
+			live:        stored,
+			credentials: "the credentials of alice cached in the file credential storage",
+			client:      mockDoctorClient{whoami: whoami},
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "[PASS] config         The configuration is valid, the server is https://conjur.example.com\n")
+				assert.Contains(t, stdout, "[PASS] dns            conjur.example.com resolves to 10.0.0.2\n")
+				assert.Contains(t, stdout, "[PASS] tls            The server's certificate matches the certificate stored in ")
+				assert.Contains(t, stdout, "[PASS] connectivity   The server responds on https://conjur.example.com\n")
+				assert.Contains(t, stdout, "[PASS] credentials    Authenticating with the credentials of alice cached in the file credential storage\n")
+				assert.Contains(t, stdout, "[PASS] authentication Obtained an access token\n")
+				assert.Contains(t, stdout, "[PASS] whoami         Logged in as alice in account myorg\n")
+				assert.Contains(t, stdout, "\n7 passed, 0 warned, 0 failed, 0 skipped\n")
+				assert.NotContains(t, stdout, "Hint")
+			},
+		},
+		{
+			name:      "doctor with an invalid configuration",
+			args:      []string{"doctor"},
+			configErr: errors.New("Must specify an ApplianceURL"),
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "[FAIL] config         Must specify an ApplianceURL\n")
+				assert.Contains(t, stdout, "Hint: Run 'conjur config view' to review the configuration, or 'conjur init' to recreate it\n")
+				assert.Contains(t, stdout, "[SKIP] dns            Skipped because the config check didn't pass\n")
+				assert.Contains(t, stdout, "[SKIP] whoami         Skipped because the authentication check didn't pass\n")
+				assert.Contains(t, stdout, "0 passed, 0 warned, 1 failed, 6 skipped\n")
+				assert.Equal(t, "Error: 1 of 7 checks failed\n", stderr)
+			},
+		},
+		{
+			name:        "doctor with an unresolvable host",
+			args:        []string{"doctor"},
+			lookupErr:   errors.New("no such host"),
+			credentials: "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY",
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "[FAIL] dns            Unable to resolve conjur.example.com: no such host\n")
+				assert.Contains(t, stdout, "[SKIP] tls            Skipped because the dns check didn't pass\n")
+				assert.Contains(t, stdout, "[SKIP] connectivity   Skipped because the tls check didn't pass\n")
+				// The credentials don't depend on the server
+				assert.Contains(t, stdout, "[PASS] credentials    Authenticating with CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY\n")
+				assert.Contains(t, stdout, "[SKIP] authentication Skipped because the connectivity check didn't pass\n")
+			},
+		},
+		{
+			name:        "doctor warns about an unresolvable host behind a proxy",
+			args:        []string{"doctor"},
+			config:      conjurapi.Config{Proxy: "http://proxy:3128"},
+			lookupErr:   errors.New("no such host"),
+			stored:      &stored,
+			live:        stored,
+			credentials: "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY",
+			client:      mockDoctorClient{whoami: whoami},
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "[WARN] dns            Unable to resolve conjur.example.com: no such host\n")
+				assert.Contains(t, stdout, "Hint: The proxy must resolve it instead\n")
+				assert.Contains(t, stdout, "6 passed, 1 warned, 0 failed, 0 skipped\n")
+			},
+		},
+		{
+			name:   "doctor with a rotated certificate",
+			args:   []string{"doctor"},
+			stored: &stored,
+			live:   rotated,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "[FAIL] tls            The server's certificate doesn't match the certificate stored in ")
+				assert.Contains(t, stdout, "(Stored certificate: "+stored.Fingerprint+", Server certificate: "+rotated.Fingerprint+")\n")
+			},
+		},
+		{
+			name:        "doctor warns about an expiring certificate",
+			args:        []string{"doctor"},
+			stored:      &expiring,
+			live:        expiring,
+			credentials: "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY",
+			client:      mockDoctorClient{whoami: whoami},
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "[WARN] tls            The server's certificate matches the certificate stored in ")
+				assert.Contains(t, stdout, "expires on")
+				assert.Contains(t, stdout, "Hint: Run 'conjur cert refresh' once the server's certificate is renewed\n")
+			},
+		},
+		{
+			name:         "doctor with an unreachable server",
+			args:         []string{"doctor"},
+			config:       conjurapi.Config{ApplianceURL: "http://10.0.0.2"},
+			connectivity: errors.New("Unable to connect to http://10.0.0.2: connection refused"),
+			credentials:  "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY",
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "[PASS] dns            10.0.0.2 is an IP address\n")
+				assert.Contains(t, stdout, "[WARN] tls            The appliance URL doesn't use HTTPS")
+				assert.Contains(t, stdout, "[FAIL] connectivity   Unable to connect to http://10.0.0.2: connection refused\n")
+				assert.Contains(t, stdout, "[SKIP] authentication Skipped because the connectivity check didn't pass\n")
+			},
+		},
+		{
+			name:   "doctor without cached credentials",
+			args:   []string{"doctor"},
+			stored: &stored,
+			live:   stored,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "[FAIL] credentials    No credentials are cached\n")
+				assert.Contains(t, stdout, "Hint: Run 'conjur login'\n")
+				assert.Contains(t, stdout, "[SKIP] authentication Skipped because the credentials check didn't pass\n")
+			},
+		},
+		{
+			name:        "doctor with rejected credentials",
+			args:        []string{"doctor"},
+			stored:      &stored,
+			live:        stored,
+			credentials: "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY",
+			client:      mockDoctorClient{refreshErr: errors.New("401 Unauthorized")},
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "[FAIL] authentication 401 Unauthorized\n")
+				assert.Contains(t, stdout, "[SKIP] whoami         Skipped because the authentication check didn't pass\n")
+				assert.Equal(t, "Error: 1 of 7 checks failed\n", stderr)
+			},
+		},
+		{
+			name:        "doctor --json",
+			args:        []string{"doctor", "--json"},
+			stored:      &stored,
+			live:        stored,
+			credentials: "CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY",
+			client:      mockDoctorClient{whoamiErr: errors.New("403 Forbidden")},
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				var report doctorReport
+				assert.NoError(t, json.Unmarshal([]byte(stdout), &report))
+				assert.Equal(t, "https://conjur.example.com", report.ApplianceURL)
+				assert.NotEmpty(t, report.Version)
+				assert.NotEmpty(t, report.Platform)
+				assert.Len(t, report.Checks, 7)
+				assert.Equal(t, doctorCheck{Name: "config", Status: doctorPass, Message: "The configuration is valid, the server is https://conjur.example.com"}, report.Checks[0])
+				assert.Equal(t, doctorCheck{Name: "whoami", Status: doctorFail, Message: "403 Forbidden", Hint: "Check that the access token is accepted by the server"}, report.Checks[6])
+				assert.Equal(t, "Error: 1 of 7 checks failed\n", stderr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := tc.config
+			config.Account = "myorg"
+			if config.ApplianceURL == "" {
+				config.ApplianceURL = "https://conjur.example.com"
+			}
+			if tc.stored != nil {
+				config.SSLCertPath = filepath.Join(t.TempDir(), "conjur-server.pem")
+				assert.NoError(t, os.WriteFile(config.SSLCertPath, []byte(tc.stored.Cert), 0600))
+			}
+
+			cmd := newDoctorCmd(doctorCmdFuncs{
+				LoadAndValidateConjurConfig: func(time.Duration) (conjurapi.Config, error) {
+					return config, tc.configErr
+				},
+				LoadCLIConfig: func() (clients.CLIConfig, error) {
+					return clients.CLIConfig{}, nil
+				},
+				LookupHost: func(host string) ([]string, error) {
+					return []string{"10.0.0.2"}, tc.lookupErr
+				},
+				GetServerCert: func(config conjurapi.Config, cliConfig clients.CLIConfig, applianceUrl *url.URL) (utils.ServerCert, error) {
+					return tc.live, nil
+				},
+				CheckConnectivity: func(config conjurapi.Config, cliConfig clients.CLIConfig) error {
+					return tc.connectivity
+				},
+				CredentialsSource: func(config conjurapi.Config, cliConfig clients.CLIConfig) (string, error) {
+					return tc.credentials, nil
+				},
+				ClientFactory: func(cmd *cobra.Command) (doctorClient, error) {
+					return tc.client, nil
+				},
+			})
+
+			stdout, stderr, err := executeCommandForTest(t, cmd, tc.args...)
+			tc.assert(t, stdout, stderr, err)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"slices"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+
+	"github.com/spf13/cobra"
+)
+
+type identityCmdFuncs struct {
+	LoadAndValidateConjurConfig func(timeout time.Duration) (conjurapi.Config, error)
+	LoadCLIConfig               func() (clients.CLIConfig, error)
+	ActiveIdentity              func(config conjurapi.Config) (string, error)
+	UseIdentity                 func(config conjurapi.Config, identity string) error
+}
+
+var defaultIdentityCmdFuncs = identityCmdFuncs{
+	LoadAndValidateConjurConfig: clients.LoadAndValidateConjurConfig,
+	LoadCLIConfig:               clients.LoadCLIConfig,
+	ActiveIdentity:              clients.ActiveIdentity,
+	UseIdentity:                 clients.UseIdentity,
+}
+
+func newIdentityCmd(funcs identityCmdFuncs) *cobra.Command {
+	identityCmd := &cobra.Command{
+		Use:   "identity",
+		Short: "Identity commands (list, use)",
+		Long: `Manage the identities whose credentials are cached for the configured server.
+
+Each identity you login as remains cached when you login as another one. Use 'identity use' to switch between them, or the global --as flag to run a single command as another identity.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Print --help if called without subcommand
+			cmd.Help()
+		},
+	}
+
+	identityCmd.AddCommand(newIdentityListCmd(funcs))
+	identityCmd.AddCommand(newIdentityUseCmd(funcs))
+
+	return identityCmd
+}
+
+func newIdentityListCmd(funcs identityCmdFuncs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: "List the cached identities",
+		Long: `List the identities whose credentials are cached. The logged in identity is marked with an asterisk.
+
+Examples:
+- conjur identity list`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			config, err := funcs.LoadAndValidateConjurConfig(0)
+			if err != nil {
+				return err
+			}
+			cliConfig, err := funcs.LoadCLIConfig()
+			if err != nil {
+				return err
+			}
+			active, err := funcs.ActiveIdentity(config)
+			if err != nil {
+				return err
+			}
+
+			identities := cliConfig.Identities
+			if active != "" && !slices.Contains(identities, active) {
+				// Logged in before identities were recorded
+				identities = append([]string{active}, identities...)
+			}
+			if len(identities) == 0 {
+				cmd.Println("No identities are cached. Use 'conjur login' to cache one.")
+				return nil
+			}
+
+			for _, identity := range identities {
+				if identity == active {
+					cmd.Println("* " + identity)
+				} else {
+					cmd.Println("  " + identity)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+func newIdentityUseCmd(funcs identityCmdFuncs) *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <identity>",
+		Short: "Switch to a cached identity",
+		Long: `Switch to the cached [identity], which subsequent commands authenticate as.
+
+Examples:
+- conjur identity use alice
+- conjur identity use host/app/web-01`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				cmd.Help()
+				return nil
+			}
+
+			config, err := funcs.LoadAndValidateConjurConfig(0)
+			if err != nil {
+				return err
+			}
+			if err = funcs.UseIdentity(config, args[0]); err != nil {
+				return err
+			}
+
+			cmd.Printf("Switched to identity %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func init() {
+	identityCmd := newIdentityCmd(defaultIdentityCmdFuncs)
+	rootCmd.AddCommand(identityCmd)
+}
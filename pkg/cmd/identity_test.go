@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/stretchr/testify/assert"
+)
+
+var identityCmdTestCases = []struct {
+	name        string
+	args        []string
+	identities  []string
+	active      string
+	useIdentity func(t *testing.T, identity string) error
+	assert      func(t *testing.T, stdout string, stderr string, err error)
+}{
+	{
+		name: "identity command help",
+		args: []string{"identity", "--help"},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stdout, "HELP LONG")
+		},
+	},
+	{
+		name:       "identity list",
+		args:       []string{"identity", "list"},
+		identities: []string{"alice", "host/app/web-01"},
+		active:     "host/app/web-01",
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Equal(t, "  alice\n* host/app/web-01\n", stdout)
+		},
+	},
+	{
+		name:   "identity list includes an unrecorded logged in identity",
+		args:   []string{"identity", "list"},
+		active: "alice",
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Equal(t, "* alice\n", stdout)
+		},
+	},
+	{
+		name: "identity list without identities",
+		args: []string{"identity", "list"},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "No identities are cached")
+		},
+	},
+	{
+		name: "identity use",
+		args: []string{"identity", "use", "host/app/web-01"},
+		useIdentity: func(t *testing.T, identity string) error {
+			assert.Equal(t, "host/app/web-01", identity)
+			return nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Equal(t, "Switched to identity host/app/web-01\n", stdout)
+		},
+	},
+	{
+		name: "identity use with an unknown identity",
+		args: []string{"identity", "use", "bob"},
+		useIdentity: func(t *testing.T, identity string) error {
+			return errors.New("No credentials are cached for bob")
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Error(t, err)
+			assert.Equal(t, "Error: No credentials are cached for bob\n", stderr)
+		},
+	},
+	{
+		name: "identity use without an identity",
+		args: []string{"identity", "use"},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "HELP LONG")
+		},
+	},
+}
+
+func TestIdentityCmd(t *testing.T) {
+	for _, tc := range identityCmdTestCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cmd := newIdentityCmd(identityCmdFuncs{
+				LoadAndValidateConjurConfig: func(time.Duration) (conjurapi.Config, error) {
+					return defaultConjurConfig, nil
+				},
+				LoadCLIConfig: func() (clients.CLIConfig, error) {
+					return clients.CLIConfig{Identities: tc.identities}, nil
+				},
+				ActiveIdentity: func(conjurapi.Config) (string, error) {
+					return tc.active, nil
+				},
+				UseIdentity: func(config conjurapi.Config, identity string) error {
+					return tc.useIdentity(t, identity)
+				},
+			})
+
+			stdout, stderr, err := executeCommandForTest(t, cmd, tc.args...)
+			tc.assert(t, stdout, stderr, err)
+		})
+	}
+}
@@ -2,11 +2,13 @@ package cmd
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
@@ -18,23 +20,32 @@ import (
 )
 
 type initCmdFuncs struct {
-	JWTAuthenticate func(conjurClient clients.ConjurClient) error
+	JWTAuthenticate       func(conjurClient clients.ConjurClient) error
+	IAMLogin              func(conjurClient clients.ConjurClient) (clients.ConjurClient, error)
+	InstanceMetadataLogin func(conjurClient clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error)
 }
 
 var defaultInitCmdFuncs = initCmdFuncs{
-	JWTAuthenticate: clients.JWTAuthenticate,
+	JWTAuthenticate:       clients.JWTAuthenticate,
+	IAMLogin:              clients.IAMLogin,
+	InstanceMetadataLogin: clients.InstanceMetadataLogin,
 }
 
-func writeConjurrc(config conjurapi.Config, conjurrcFilePath string, forceFileOverwrite bool) error {
-	fileContents := config.Conjurrc()
+func writeConjurrc(config conjurapi.Config, cliConfig clients.CLIConfig, conjurrcFilePath string, forceFileOverwrite, prompt bool) error {
+	fileContents := clients.Conjurrc(config, cliConfig)
 
-	return writeFile(conjurrcFilePath, fileContents, forceFileOverwrite)
+	return writeFile(conjurrcFilePath, fileContents, forceFileOverwrite, prompt)
 }
 
-func writeFile(filePath string, fileContents []byte, forceFileOverwrite bool) error {
+// writeFile writes a file, asking before overwriting an existing one unless forceFileOverwrite is
+// set. Without prompt, an existing file is only overwritten with forceFileOverwrite.
+func writeFile(filePath string, fileContents []byte, forceFileOverwrite, prompt bool) error {
 	if !forceFileOverwrite {
-		err := prompts.MaybeAskToOverwriteFile(filePath)
-		if err != nil {
+		if !prompt {
+			if _, err := os.Stat(filePath); err == nil {
+				return fmt.Errorf("not overwriting the existing file %s without force", filePath)
+			}
+		} else if err := prompts.MaybeAskToOverwriteFile(filePath); err != nil {
 			return err
 		}
 	}
@@ -52,6 +63,14 @@ func newInitCommand() *cobra.Command {
 		PreRunE: func(cmd *cobra.Command, args []string) error {
 			// flags are not parsed yet, any flag from subcommand would fail the parsing on the init command
 			env := getEnv(args)
+			// without the env flag, a bootstrap determines the environment so that it isn't prompted for
+			if len(env) == 0 {
+				bootstrapEnv, err := initBootstrapEnv(args)
+				if err != nil {
+					return err
+				}
+				env = bootstrapEnv
+			}
 			// if the env flag is not set, check if the user provided it as an argument
 			env, err := prompts.MaybeAskForEnvironment(env)
 			if err != nil {
@@ -94,20 +113,32 @@ func newInitCommand() *cobra.Command {
 }
 
 func getEnv(args []string) string {
+	return getFlagValue(args, "env")
+}
+
+// getFlagValue returns the value of the flag name in args, which aren't parsed yet
+func getFlagValue(args []string, name string) string {
 	for i, arg := range args {
-		if strings.HasPrefix(arg, "--env=") {
-			return strings.TrimPrefix(arg, "--env=")
+		if strings.HasPrefix(arg, "--"+name+"=") {
+			return strings.TrimPrefix(arg, "--"+name+"=")
 		}
-		if arg == "--env" && len(args) > i+1 {
+		if arg == "--"+name && len(args) > i+1 {
 			return args[i+1]
 		}
 	}
 	return ""
 }
 
-func fetchCertIfNeeded(config *conjurapi.Config, insecure, selfSigned, forceFileOverwrite bool, certFilePath string) error {
-	// If user has specified a cert file, don't fetch it from the server
-	if config.SSLCertPath != "" {
+// fetchCertIfNeeded fetches the certificate of the server and writes it to certFilePath unless it is
+// trusted by the system. When a fingerprint is pinned, the certificate must have that fingerprint
+// and is trusted without prompting. trustAnchor selects the certificate of the chain to trust, see
+// chooseTrustAnchor. Without prompt, a certificate that isn't trusted by the system must be trusted
+// with selfSigned or a fingerprint.
+func fetchCertIfNeeded(config *conjurapi.Config, cliConfig clients.CLIConfig, insecure, selfSigned, forceFileOverwrite bool, certFilePath, trustAnchor string, prompt bool) error {
+	certFingerprint := cliConfig.CertFingerprint
+	// If user has specified a cert file, don't fetch it from the server, unless it needs to be checked
+	// against the pinned fingerprint
+	if config.SSLCertPath != "" && certFingerprint == "" {
 		return nil
 	}
 
@@ -125,46 +156,56 @@ func fetchCertIfNeeded(config *conjurapi.Config, insecure, selfSigned, forceFile
 		return fmt.Errorf("Cannot fetch certificate from non-HTTPS URL %s", applianceUrl)
 	}
 
-	var cert utils.ServerCert
-	if len(config.Proxy) > 0 {
-		cert, err = utils.GetServerCertViaHTTPProxy(
-			context.Background(),
-			config.Proxy,
-			applianceUrl.Host,
-			time.Duration(config.GetHttpTimeout())*time.Second,
-		)
-	} else {
-		cert, err = utils.GetServerCert(applianceUrl.Host)
-		if err != nil {
-			errStr := fmt.Sprintf("Unable to retrieve and validate certificate from %s: %s", applianceUrl.Host, err)
-			return errors.New(errStr)
+	cert, err := getServerCert(*config, cliConfig, applianceUrl)
+	if err != nil {
+		return err
+	}
+
+	if certFingerprint != "" {
+		if cert.Fingerprint != certFingerprint {
+			return fmt.Errorf("The certificate of %s has the fingerprint %s, which doesn't match --cert-fingerprint %s", applianceUrl.Host, cert.Fingerprint, certFingerprint)
+		}
+		if config.SSLCertPath != "" {
+			return nil
 		}
 	}
 
 	var persistCert bool
-	// Prompt user to trust the certificate if it is self-signed and --self-signed flag is not set
+	// Initialize combined certificate content
+	combinedCert := cert.Cert
+	// Prompt user to trust the certificate if it is self-signed and neither --self-signed nor
+	// --cert-fingerprint is set
 	if cert.SelfSigned || cert.UntrustedCA {
 		persistCert = true
-		if !selfSigned {
+		askToTrust := !selfSigned && certFingerprint == ""
+		if askToTrust && !prompt {
+			return fmt.Errorf("The certificate of %s isn't trusted by the system. Set self_signed, cert_file or cert_fingerprint to trust it.", applianceUrl.Host)
+		}
+		if askToTrust {
 			err = prompts.AskToTrustCert(cert)
 			if err != nil {
 				return fmt.Errorf("Based on your selection, this certificate will not be trusted")
 			}
 		}
+		anchor, err := chooseTrustAnchor(cert, trustAnchor, askToTrust)
+		if err != nil {
+			return err
+		}
+		if combinedCert, err = cert.TrustBundle(anchor); err != nil {
+			return err
+		}
 	}
 
-	// Initialize combined certificate content
-	combinedCert := cert.Cert
 	// Check if the host contains ".secretsmgr" which indicates CC and fetch certificate from the identity host
 	if strings.Contains(applianceUrl.Host, ".secretsmgr") {
-		combinedCert, err = appendConjurCloudCert(applianceUrl, selfSigned, combinedCert)
+		combinedCert, err = appendConjurCloudCert(applianceUrl, clients.IdentityProxy(*config, cliConfig), selfSigned, combinedCert, prompt)
 		if err != nil {
 			return err
 		}
 	}
 
 	if persistCert {
-		err = persistCertInConfig(config, certFilePath, combinedCert, forceFileOverwrite)
+		err = persistCertInConfig(config, certFilePath, combinedCert, forceFileOverwrite, prompt)
 		if err != nil {
 			return err
 		}
@@ -173,8 +214,100 @@ func fetchCertIfNeeded(config *conjurapi.Config, insecure, selfSigned, forceFile
 	return nil
 }
 
-func persistCertInConfig(config *conjurapi.Config, certFilePath string, combinedCert string, forceFileOverwrite bool) error {
-	err := writeFile(certFilePath, []byte(combinedCert), forceFileOverwrite)
+// trustAnchors are the values of --trust-anchor
+var trustAnchors = []string{"server", "intermediate", "root"}
+
+func validateTrustAnchor(trustAnchor string) error {
+	if trustAnchor != "" && !slices.Contains(trustAnchors, trustAnchor) {
+		return fmt.Errorf("Invalid --trust-anchor %q, must be one of %s", trustAnchor, strings.Join(trustAnchors, ", "))
+	}
+	return nil
+}
+
+const credentialStorageUsage = "Where to store the credentials: keyring, file, none or encrypted-file (defaults to keyring when available). " +
+	"Unless CONJUR_CREDENTIALS_PASSPHRASE is set, encrypted-file derives its key from the machine ID, which every local user can read, " +
+	"so it only prevents copying the file to another machine and doesn't protect it from the other users"
+
+// validateCredentialStorage validates --credential-storage, which --force-netrc can only be combined
+// with when it's file
+func validateCredentialStorage(credentialStorage string, forceNetrc bool) error {
+	if credentialStorage == "" {
+		return nil
+	}
+	setting, _ := clients.LookupSetting("credential_storage")
+	if _, err := setting.Normalize(credentialStorage); err != nil {
+		return err
+	}
+	if forceNetrc && credentialStorage != conjurapi.CredentialStorageFile {
+		return fmt.Errorf("Cannot specify both --force-netrc and --credential-storage %s", credentialStorage)
+	}
+	return nil
+}
+
+// chooseTrustAnchor returns the certificate of the server's chain to trust, as an index for
+// utils.ServerCert.TrustBundle. Without --trust-anchor, the user chooses when prompt is set and the
+// server presents its CAs, and the server's certificate is trusted otherwise.
+func chooseTrustAnchor(cert utils.ServerCert, trustAnchor string, prompt bool) (int, error) {
+	cas := cert.CACerts()
+	switch trustAnchor {
+	case "":
+		if prompt && len(cas) > 0 {
+			return prompts.AskForTrustAnchor(cert)
+		}
+		return 0, nil
+	case "server":
+		return 0, nil
+	case "intermediate":
+		if len(cas) == 0 || utils.IsSelfSigned(cas[0]) {
+			return 0, errors.New("The server doesn't present an intermediate CA certificate")
+		}
+		return 1, nil
+	case "root":
+		if len(cas) == 0 || !utils.IsSelfSigned(cas[len(cas)-1]) {
+			return 0, errors.New("The server doesn't present its root CA certificate. Pass the root CA certificate with --ca-cert instead.")
+		}
+		return len(cas), nil
+	}
+	return 0, validateTrustAnchor(trustAnchor)
+}
+
+// getServerCert fetches the certificate of the server at applianceUrl, through the proxy of the
+// configuration if it has one, and presenting the client certificate if one is configured
+func getServerCert(config conjurapi.Config, cliConfig clients.CLIConfig, applianceUrl *url.URL) (utils.ServerCert, error) {
+	clientCert, err := clients.ClientCertificate(config, cliConfig)
+	if err != nil {
+		return utils.ServerCert{}, err
+	}
+	return fetchServerCert(config.Proxy, applianceUrl.Host, time.Duration(config.GetHttpTimeout())*time.Second, clientCert)
+}
+
+// fetchServerCert fetches the certificate of host through proxy, unless proxy is empty or host
+// matches the NO_PROXY patterns of the environment
+func fetchServerCert(proxy, host string, timeout time.Duration, clientCert *tls.Certificate) (utils.ServerCert, error) {
+	if len(proxy) > 0 {
+		proxyURL, err := utils.ParseProxyURL(proxy)
+		if err != nil {
+			return utils.ServerCert{}, fmt.Errorf("Invalid proxy: %s", err)
+		}
+		proxyURL, err = utils.ProxyForURL(proxyURL, &url.URL{Scheme: "https", Host: host})
+		if err != nil {
+			return utils.ServerCert{}, err
+		}
+		if proxyURL != nil {
+			return utils.GetServerCertViaProxy(context.Background(), proxyURL.String(), host, timeout, clientCert)
+		}
+	}
+
+	cert, err := utils.GetServerCertWithClientCert(host, clientCert)
+	if err != nil {
+		errStr := fmt.Sprintf("Unable to retrieve and validate certificate from %s: %s", host, err)
+		return cert, errors.New(errStr)
+	}
+	return cert, nil
+}
+
+func persistCertInConfig(config *conjurapi.Config, certFilePath string, combinedCert string, forceFileOverwrite, prompt bool) error {
+	err := writeFile(certFilePath, []byte(combinedCert), forceFileOverwrite, prompt)
 	if err != nil {
 		return err
 	}
@@ -184,15 +317,21 @@ func persistCertInConfig(config *conjurapi.Config, certFilePath string, combined
 	return nil
 }
 
-func appendConjurCloudCert(url *url.URL, selfSigned bool, combinedCert string) (string, error) {
+// appendConjurCloudCert appends the certificate of the Identity tenant of Secrets Manager SaaS,
+// fetched through its proxy, see clients.IdentityProxy. Without prompt, a certificate that isn't
+// trusted by the system must be trusted with selfSigned.
+func appendConjurCloudCert(url *url.URL, proxy string, selfSigned bool, combinedCert string, prompt bool) (string, error) {
 	identityHost := strings.Replace(url.Host, ".secretsmgr", "", 1)
-	identityCert, err := utils.GetServerCert(identityHost)
+	identityCert, err := fetchServerCert(proxy, identityHost, 0, nil)
 	if err != nil {
-		return "", fmt.Errorf("Unable to retrieve and validate certificate from %s: %s", identityHost, err)
+		return "", err
 	}
 
 	// Prompt user to trust the identity certificate if it is self-signed and --self-signed flag is not set
 	if (identityCert.SelfSigned || identityCert.UntrustedCA) && !selfSigned {
+		if !prompt {
+			return "", fmt.Errorf("The certificate of %s isn't trusted by the system. Set self_signed to trust it.", identityHost)
+		}
 		err = prompts.AskToTrustCert(identityCert)
 		if err != nil {
 			return "", fmt.Errorf("Based on your selection, this certificate will not be trusted from %s", identityHost)
@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// initBootstrapSetting is a setting of init that is read from a bootstrap file or the environment
+// instead of its flag
+type initBootstrapSetting struct {
+	// key is the name of the setting in the bootstrap file, the same as in .conjurrc
+	key string
+	// flag is the flag of init the setting stands for
+	flag string
+	// envVar is the environment variable holding the setting with --from-env
+	envVar string
+}
+
+// initBootstrapSettings are the settings of init, in the order they are validated. The settings
+// that aren't stored in .conjurrc are read from CONJUR_INIT_* environment variables.
+var initBootstrapSettings = []initBootstrapSetting{
+	{key: "environment", envVar: "CONJUR_ENVIRONMENT"},
+	{key: "appliance_url", flag: "url", envVar: "CONJUR_APPLIANCE_URL"},
+	{key: "account", flag: "account", envVar: "CONJUR_ACCOUNT"},
+	{key: "authn_type", flag: "authn-type", envVar: "CONJUR_AUTHN_TYPE"},
+	{key: "service_id", flag: "service-id", envVar: "CONJUR_SERVICE_ID"},
+	{key: "cert_file", flag: "ca-cert", envVar: "CONJUR_CERT_FILE"},
+	{key: "cert_fingerprint", flag: "cert-fingerprint", envVar: "CONJUR_CERT_FINGERPRINT"},
+	{key: "trust_anchor", flag: "trust-anchor", envVar: "CONJUR_INIT_TRUST_ANCHOR"},
+	{key: "self_signed", flag: "self-signed", envVar: "CONJUR_INIT_SELF_SIGNED"},
+	{key: "insecure", flag: "insecure", envVar: "CONJUR_INIT_INSECURE"},
+	{key: "client_cert_file", flag: "client-cert", envVar: "CONJUR_AUTHN_CERT_FILE"},
+	{key: "client_cert_key_file", flag: "client-key", envVar: "CONJUR_AUTHN_CERT_KEY_FILE"},
+	{key: "client_cert_bundle", flag: "client-cert-bundle", envVar: "CONJUR_CLIENT_CERT_BUNDLE"},
+	{key: "jwt_file", flag: "jwt-file", envVar: "JWT_TOKEN_PATH"},
+	{key: "jwt_source", flag: "jwt-source", envVar: "CONJUR_AUTHN_JWT_SOURCE"},
+	{key: "jwt_host_id", flag: "jwt-host-id", envVar: "CONJUR_AUTHN_JWT_HOST_ID"},
+	{key: "metadata_url", flag: "metadata-url", envVar: "CONJUR_AUTHN_METADATA_URL"},
+	{key: "azure_client_id", flag: "azure-client-id", envVar: "CONJUR_AUTHN_AZURE_CLIENT_ID"},
+	{key: "proxy", flag: "proxy", envVar: "CONJUR_INIT_PROXY"},
+	{key: "identity_proxy", flag: "identity-proxy", envVar: "CONJUR_IDENTITY_PROXY"},
+	{key: "credential_storage", flag: "credential-storage", envVar: "CONJUR_CREDENTIAL_STORAGE"},
+	{key: "force", flag: "force", envVar: "CONJUR_INIT_FORCE"},
+}
+
+// initBootstrapHelp documents --from-file and --from-env in the help of the init commands
+const initBootstrapHelp = `With --from-file or --from-env, the settings are read from a YAML bootstrap file or from environment variables instead, and the command never prompts, which suits unattended provisioning such as golden images. The bootstrap file uses the keys of .conjurrc, such as appliance_url, account, authn_type, service_id, cert_file, cert_fingerprint, jwt_source or credential_storage, plus trust_anchor, self_signed, insecure and force. The environment variables are those overriding .conjurrc, such as CONJUR_APPLIANCE_URL, plus CONJUR_INIT_TRUST_ANCHOR, CONJUR_INIT_SELF_SIGNED, CONJUR_INIT_INSECURE, CONJUR_INIT_PROXY and CONJUR_INIT_FORCE. Flags take precedence over the bootstrap. Every missing or invalid setting is reported at once. The passphrase of an encrypted client certificate key is read from CONJUR_CLIENT_KEY_PASSPHRASE.`
+
+func lookupInitBootstrapSetting(key string) (initBootstrapSetting, bool) {
+	for _, setting := range initBootstrapSettings {
+		if setting.key == key {
+			return setting, true
+		}
+	}
+	return initBootstrapSetting{}, false
+}
+
+// addInitBootstrapFlags adds the flags reading the settings of init from a bootstrap file or the
+// environment
+func addInitBootstrapFlags(cmd *cobra.Command) {
+	cmd.Flags().String("from-file", "", "YAML bootstrap file holding the settings to initialize with, keyed as in .conjurrc. Never prompts.")
+	cmd.Flags().Bool("from-env", false, "Read the settings to initialize with from environment variables. Never prompts.")
+}
+
+// initBootstrap holds the settings of init read from the bootstrap file of --from-file, or from the
+// environment with --from-env
+type initBootstrap struct {
+	// file is the bootstrap file, or empty for the environment
+	file   string
+	values map[string]string
+	// problems are the problems found while reading the bootstrap
+	problems []string
+}
+
+// name is how the problems of the bootstrap refer to a setting
+func (b initBootstrap) name(setting initBootstrapSetting) string {
+	if b.file == "" {
+		return setting.envVar
+	}
+	return setting.key
+}
+
+func loadInitBootstrap(file string, fromEnv bool) (initBootstrap, error) {
+	if file != "" && fromEnv {
+		return initBootstrap{}, errors.New("Cannot specify both --from-file and --from-env")
+	}
+	bootstrap := initBootstrap{file: file, values: map[string]string{}}
+	if fromEnv {
+		for _, setting := range initBootstrapSettings {
+			if value := os.Getenv(setting.envVar); value != "" {
+				bootstrap.values[setting.key] = value
+			}
+		}
+		return bootstrap, nil
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return initBootstrap{}, fmt.Errorf("Unable to read the bootstrap file: %s", err)
+	}
+	var settings map[string]interface{}
+	if err = yaml.Unmarshal(data, &settings); err != nil {
+		return initBootstrap{}, fmt.Errorf("Unable to parse the bootstrap file %s: %s", file, err)
+	}
+	for _, key := range sortedKeys(settings) {
+		if _, ok := lookupInitBootstrapSetting(key); !ok {
+			bootstrap.problems = append(bootstrap.problems, fmt.Sprintf("Unknown setting %s", key))
+			continue
+		}
+		switch value := settings[key].(type) {
+		case nil:
+		case string, bool, int, float64:
+			bootstrap.values[key] = fmt.Sprint(value)
+		default:
+			bootstrap.problems = append(bootstrap.problems, fmt.Sprintf("Invalid %s: must be a single value", key))
+		}
+	}
+	return bootstrap, nil
+}
+
+// initBootstrapEnv returns the environment to initialize according to the bootstrap of --from-file
+// or --from-env in args, which aren't parsed yet: its environment setting, else saas when its
+// appliance_url is a Secrets Manager SaaS URL, else self-hosted. It returns an empty string
+// without a bootstrap.
+func initBootstrapEnv(args []string) (string, error) {
+	file := getFlagValue(args, "from-file")
+	fromEnv := slices.Contains(args, "--from-env") || slices.Contains(args, "--from-env=true")
+	if file == "" && !fromEnv {
+		return "", nil
+	}
+	bootstrap, err := loadInitBootstrap(file, fromEnv)
+	if err != nil {
+		return "", err
+	}
+
+	// An invalid environment is reported along with the other problems of the bootstrap
+	environmentSetting, _ := clients.LookupSetting("environment")
+	if environment, err := environmentSetting.Normalize(bootstrap.values["environment"]); err == nil {
+		return environment, nil
+	}
+	if applianceURL := bootstrap.values["appliance_url"]; applianceURL != "" {
+		if _, err := clients.ParseCloudURL(normalizeCloudURL(applianceURL)); err == nil {
+			return string(conjurapi.EnvironmentSaaS), nil
+		}
+	}
+	return string(conjurapi.EnvironmentSH), nil
+}
+
+// applyInitBootstrap sets the flags of an init command from the bootstrap of --from-file or
+// --from-env, for the environment the command initializes. The flags that are set explicitly take
+// precedence. Every unknown, invalid, unsupported or missing setting is reported at once. It returns
+// whether a bootstrap is used, in which case the command must not prompt.
+func applyInitBootstrap(cmd *cobra.Command, environment conjurapi.EnvironmentType) (bool, error) {
+	file, err := cmd.Flags().GetString("from-file")
+	if err != nil {
+		return false, err
+	}
+	fromEnv, err := cmd.Flags().GetBool("from-env")
+	if err != nil {
+		return false, err
+	}
+	if file == "" && !fromEnv {
+		return false, nil
+	}
+	bootstrap, err := loadInitBootstrap(file, fromEnv)
+	if err != nil {
+		return true, err
+	}
+
+	problems := bootstrap.problems
+	// invalid are the keys of the invalid settings, which aren't reported as missing as well
+	invalid := map[string]bool{}
+	for _, setting := range initBootstrapSettings {
+		value, ok := bootstrap.values[setting.key]
+		if !ok {
+			continue
+		}
+		name := bootstrap.name(setting)
+		invalid[setting.key] = true
+		if conjurrcSetting, err := clients.LookupSetting(setting.key); err == nil {
+			if value, err = conjurrcSetting.Normalize(value); err != nil {
+				// Normalize names the setting by its key
+				problems = append(problems, strings.Replace(err.Error(), setting.key, name, 1))
+				continue
+			}
+		}
+
+		if setting.key == "environment" {
+			if (value == string(conjurapi.EnvironmentSaaS)) != (environment == conjurapi.EnvironmentSaaS) {
+				problems = append(problems, fmt.Sprintf("Invalid %s: %s can't be initialized as %s", name, value, environment))
+				continue
+			}
+			if cmd.Annotations == nil {
+				cmd.Annotations = map[string]string{}
+			}
+			cmd.Annotations["env"] = value
+			continue
+		}
+
+		flag := cmd.Flags().Lookup(setting.flag)
+		if flag == nil {
+			problems = append(problems, fmt.Sprintf("%s isn't supported by %s", name, environment))
+			continue
+		}
+		if flag.Value.Type() == "bool" {
+			if _, err := strconv.ParseBool(value); err != nil {
+				problems = append(problems, fmt.Sprintf("Invalid %s: must be true or false", name))
+				continue
+			}
+		}
+		if setting.key == "trust_anchor" && !slices.Contains(trustAnchors, value) {
+			problems = append(problems, fmt.Sprintf("Invalid %s: must be one of %s", name, strings.Join(trustAnchors, ", ")))
+			continue
+		}
+		if flag.Changed {
+			continue
+		}
+		if err := cmd.Flags().Set(setting.flag, value); err != nil {
+			problems = append(problems, fmt.Sprintf("Invalid %s: %s", name, err))
+			continue
+		}
+		delete(invalid, setting.key)
+	}
+
+	flagValue := func(flag string) string {
+		if f := cmd.Flags().Lookup(flag); f != nil {
+			return f.Value.String()
+		}
+		return ""
+	}
+	settingName := func(key string) string {
+		setting, _ := lookupInitBootstrapSetting(key)
+		return bootstrap.name(setting)
+	}
+	require := func(key, requiredBy string) {
+		setting, _ := lookupInitBootstrapSetting(key)
+		if flagValue(setting.flag) == "" && !invalid[key] {
+			problems = append(problems, "Missing "+bootstrap.name(setting)+requiredBy)
+		}
+	}
+	isSet := func(key string) bool {
+		setting, _ := lookupInitBootstrapSetting(key)
+		if flag := cmd.Flags().Lookup(setting.flag); flag != nil && flag.Value.Type() == "bool" {
+			value, _ := strconv.ParseBool(flag.Value.String())
+			return value
+		}
+		return flagValue(setting.flag) != ""
+	}
+
+	require("appliance_url", "")
+	authnType := flagValue("authn-type")
+	if environment != conjurapi.EnvironmentSaaS {
+		require("account", "")
+		requiredBy := fmt.Sprintf(", which %s %s requires", settingName("authn_type"), authnType)
+		if slices.Contains([]string{"ldap", "oidc", "jwt", "iam", "azure", "cert"}, authnType) {
+			require("service_id", requiredBy)
+		}
+		switch authnType {
+		case "jwt":
+			if flagValue("jwt-file") == "" && flagValue("jwt-source") == "" {
+				problems = append(problems, fmt.Sprintf("Missing %s or %s%s", settingName("jwt_file"), settingName("jwt_source"), requiredBy))
+			}
+		case "iam", "azure", "gcp":
+			require("jwt_host_id", requiredBy)
+		case "cert":
+			require("client_cert_file", requiredBy)
+			require("client_cert_key_file", requiredBy)
+		}
+	}
+
+	// The settings that can't be combined, which validateCmdFlags reports one at a time
+	if isSet("insecure") && isSet("self_signed") {
+		problems = append(problems, fmt.Sprintf("Cannot specify both %s and %s", settingName("insecure"), settingName("self_signed")))
+	}
+	if (isSet("insecure") || isSet("self_signed")) && isSet("cert_file") {
+		problems = append(problems, fmt.Sprintf("Cannot specify %s when using %s or %s", settingName("cert_file"), settingName("insecure"), settingName("self_signed")))
+	}
+	if isSet("insecure") && isSet("cert_fingerprint") {
+		problems = append(problems, fmt.Sprintf("Cannot specify both %s and %s", settingName("insecure"), settingName("cert_fingerprint")))
+	}
+	// authn_type cert already requires both
+	if authnType != "cert" && isSet("client_cert_file") != isSet("client_cert_key_file") {
+		problems = append(problems, fmt.Sprintf("Must specify both %s and %s", settingName("client_cert_file"), settingName("client_cert_key_file")))
+	}
+	if isSet("client_cert_bundle") && isSet("client_cert_file") {
+		problems = append(problems, fmt.Sprintf("Cannot specify both %s and %s", settingName("client_cert_bundle"), settingName("client_cert_file")))
+	}
+	if isSet("insecure") && (isSet("client_cert_file") || isSet("client_cert_bundle")) {
+		problems = append(problems, fmt.Sprintf("Cannot specify a client certificate when using %s", settingName("insecure")))
+	}
+	if isSet("trust_anchor") && (isSet("insecure") || isSet("cert_file")) {
+		problems = append(problems, fmt.Sprintf("Cannot specify %s when using %s or %s", settingName("trust_anchor"), settingName("insecure"), settingName("cert_file")))
+	}
+	if isSet("jwt_source") {
+		if authnType != "jwt" {
+			problems = append(problems, fmt.Sprintf("Cannot specify %s unless %s is jwt", settingName("jwt_source"), settingName("authn_type")))
+		}
+		if isSet("jwt_file") {
+			problems = append(problems, fmt.Sprintf("Cannot specify both %s and %s", settingName("jwt_file"), settingName("jwt_source")))
+		}
+	}
+	if isSet("metadata_url") && authnType != "azure" && authnType != "gcp" {
+		problems = append(problems, fmt.Sprintf("Cannot specify %s unless %s is azure or gcp", settingName("metadata_url"), settingName("authn_type")))
+	}
+	if isSet("azure_client_id") && authnType != "azure" {
+		problems = append(problems, fmt.Sprintf("Cannot specify %s unless %s is azure", settingName("azure_client_id"), settingName("authn_type")))
+	}
+
+	if force, _ := strconv.ParseBool(flagValue("force")); !force {
+		if conjurrc := flagValue("file"); conjurrc != "" {
+			if _, err := os.Stat(conjurrc); err == nil {
+				problems = append(problems, fmt.Sprintf("The file %s exists, set %s to overwrite it", conjurrc, settingName("force")))
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		source := "bootstrap file " + file
+		if fromEnv {
+			source = "bootstrap environment"
+		}
+		return true, fmt.Errorf("The %s is invalid:\n- %s", source, strings.Join(problems, "\n- "))
+	}
+	return true, nil
+}
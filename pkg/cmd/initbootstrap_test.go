@@ -0,0 +1,219 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInitBootstrap(t *testing.T) {
+	newInitCmd := func() *cobra.Command {
+		initCmd := newInitCommand()
+		initCmd.AddCommand(newCloudInitCmd())
+		initCmd.AddCommand(newInitEnterpriseCommand(defaultInitCmdFuncs))
+		return initCmd
+	}
+	writeBootstrap := func(t *testing.T, contents string) string {
+		path := t.TempDir() + "/bootstrap.yaml"
+		assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+		return path
+	}
+	initArgs := func(tempDir string, args ...string) []string {
+		return append([]string{"init", "--file=" + tempDir + "/.conjurrc", "--cert-file=" + tempDir + "/conjur-server.pem"}, args...)
+	}
+
+	t.Run("initializes from a bootstrap file without prompting", func(t *testing.T) {
+		tempDir := t.TempDir()
+		defer startSelfSignedServer(t, 8080)()
+		bootstrap := writeBootstrap(t, `
+appliance_url: https://localhost:8080
+account: test-account
+self_signed: true
+credential_storage: file
+`)
+
+		stdout, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-file="+bootstrap)...)
+		assert.NoError(t, err)
+		assertCertWritten(t, tempDir+"/.conjurrc", stdout)
+		data, _ := os.ReadFile(tempDir + "/.conjurrc")
+		assert.Contains(t, string(data), "account: test-account")
+		assert.Contains(t, string(data), "environment: self-hosted")
+		assert.Contains(t, string(data), "credential_storage: file")
+	})
+
+	t.Run("flags take precedence over the bootstrap", func(t *testing.T) {
+		tempDir := t.TempDir()
+		bootstrap := writeBootstrap(t, `
+environment: oss
+appliance_url: http://localhost:8080
+account: test-account
+insecure: true
+`)
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-file="+bootstrap, "--account=other-account")...)
+		assert.NoError(t, err)
+		data, _ := os.ReadFile(tempDir + "/.conjurrc")
+		assert.Contains(t, string(data), "account: other-account")
+		assert.Contains(t, string(data), "environment: oss")
+	})
+
+	t.Run("reports every problem of the bootstrap file at once", func(t *testing.T) {
+		tempDir := t.TempDir()
+		assert.NoError(t, os.WriteFile(tempDir+"/.conjurrc", []byte("account: existing\n"), 0600))
+		bootstrap := writeBootstrap(t, `
+appliance_url: conjur.example.com
+authn_type: jwt
+proxy: http://proxy:3128
+self_signed: maybe
+trust_anchor: leaf
+follower_urls: [https://follower.example.com]
+`)
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-file="+bootstrap)...)
+		assert.EqualError(t, err, `The bootstrap file `+bootstrap+` is invalid:
+- Unknown setting follower_urls
+- Invalid appliance_url: must be an http:// or https:// URL
+- Invalid trust_anchor: must be one of server, intermediate, root
+- Invalid self_signed: must be true or false
+- proxy isn't supported by self-hosted
+- Missing account
+- Missing service_id, which authn_type jwt requires
+- Missing jwt_file or jwt_source, which authn_type jwt requires
+- The file `+tempDir+`/.conjurrc exists, set force to overwrite it`)
+		data, _ := os.ReadFile(tempDir + "/.conjurrc")
+		assert.Equal(t, "account: existing\n", string(data))
+	})
+
+	t.Run("reports the missing environment variables at once", func(t *testing.T) {
+		tempDir := t.TempDir()
+		for _, setting := range initBootstrapSettings {
+			t.Setenv(setting.envVar, "")
+		}
+		t.Setenv("CONJUR_AUTHN_TYPE", "iam")
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-env")...)
+		assert.EqualError(t, err, `The bootstrap environment is invalid:
+- Missing CONJUR_APPLIANCE_URL
+- Missing CONJUR_ACCOUNT
+- Missing CONJUR_SERVICE_ID, which CONJUR_AUTHN_TYPE iam requires
+- Missing CONJUR_AUTHN_JWT_HOST_ID, which CONJUR_AUTHN_TYPE iam requires`)
+	})
+
+	t.Run("requires the host ID for gcp", func(t *testing.T) {
+		tempDir := t.TempDir()
+		bootstrap := writeBootstrap(t, "appliance_url: https://conjur.example.com\naccount: test-account\nauthn_type: gcp\n")
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-file="+bootstrap)...)
+		assert.EqualError(t, err, "The bootstrap file "+bootstrap+" is invalid:\n- Missing jwt_host_id, which authn_type gcp requires")
+	})
+
+	t.Run("reports every conflicting setting at once", func(t *testing.T) {
+		tempDir := t.TempDir()
+		for _, setting := range initBootstrapSettings {
+			t.Setenv(setting.envVar, "")
+		}
+		t.Setenv("CONJUR_APPLIANCE_URL", "https://conjur.example.com")
+		t.Setenv("CONJUR_ACCOUNT", "test-account")
+		t.Setenv("CONJUR_INIT_INSECURE", "true")
+		t.Setenv("CONJUR_INIT_SELF_SIGNED", "true")
+		t.Setenv("CONJUR_CERT_FILE", "/etc/conjur.pem")
+		t.Setenv("CONJUR_INIT_TRUST_ANCHOR", "root")
+		t.Setenv("CONJUR_AUTHN_CERT_FILE", "/etc/client.pem")
+		t.Setenv("CONJUR_CLIENT_CERT_BUNDLE", "/etc/client.p12")
+		t.Setenv("CONJUR_AUTHN_JWT_SOURCE", "env:JWT")
+		t.Setenv("CONJUR_AUTHN_METADATA_URL", "http://metadata")
+		t.Setenv("CONJUR_AUTHN_AZURE_CLIENT_ID", "client-id")
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-env")...)
+		assert.EqualError(t, err, `The bootstrap environment is invalid:
+- Cannot specify both CONJUR_INIT_INSECURE and CONJUR_INIT_SELF_SIGNED
+- Cannot specify CONJUR_CERT_FILE when using CONJUR_INIT_INSECURE or CONJUR_INIT_SELF_SIGNED
+- Must specify both CONJUR_AUTHN_CERT_FILE and CONJUR_AUTHN_CERT_KEY_FILE
+- Cannot specify both CONJUR_CLIENT_CERT_BUNDLE and CONJUR_AUTHN_CERT_FILE
+- Cannot specify a client certificate when using CONJUR_INIT_INSECURE
+- Cannot specify CONJUR_INIT_TRUST_ANCHOR when using CONJUR_INIT_INSECURE or CONJUR_CERT_FILE
+- Cannot specify CONJUR_AUTHN_JWT_SOURCE unless CONJUR_AUTHN_TYPE is jwt
+- Cannot specify CONJUR_AUTHN_METADATA_URL unless CONJUR_AUTHN_TYPE is azure or gcp
+- Cannot specify CONJUR_AUTHN_AZURE_CLIENT_ID unless CONJUR_AUTHN_TYPE is azure`)
+	})
+
+	t.Run("infers Secrets Manager SaaS from the URL", func(t *testing.T) {
+		tempDir := t.TempDir()
+		for _, setting := range initBootstrapSettings {
+			t.Setenv(setting.envVar, "")
+		}
+		t.Setenv("CONJUR_APPLIANCE_URL", "https://tenant.secretsmgr.cyberark.cloud")
+		t.Setenv("CONJUR_ACCOUNT", "conjur")
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-env")...)
+		assert.EqualError(t, err, "The bootstrap environment is invalid:\n- CONJUR_ACCOUNT isn't supported by saas")
+	})
+
+	t.Run("fails instead of prompting to trust the certificate", func(t *testing.T) {
+		tempDir := t.TempDir()
+		defer startSelfSignedServer(t, 8080)()
+		bootstrap := writeBootstrap(t, "appliance_url: https://localhost:8080\naccount: test-account\n")
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "--from-file="+bootstrap)...)
+		assert.EqualError(t, err, "The certificate of localhost:8080 isn't trusted by the system. Set self_signed, cert_file or cert_fingerprint to trust it.")
+		assertFetchCertFailed(t, tempDir+"/.conjurrc")
+	})
+
+	t.Run("fails for an environment the subcommand doesn't initialize", func(t *testing.T) {
+		tempDir := t.TempDir()
+		bootstrap := writeBootstrap(t, "environment: saas\nappliance_url: https://conjur.example.com\naccount: test-account\n")
+
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(tempDir, "self-hosted", "--from-file="+bootstrap)...)
+		assert.EqualError(t, err, "The bootstrap file "+bootstrap+" is invalid:\n- Invalid environment: saas can't be initialized as self-hosted")
+	})
+
+	t.Run("fails for an unreadable bootstrap file", func(t *testing.T) {
+		_, _, err := executeCommandForTest(t, newInitCmd(), initArgs(t.TempDir(), "--from-file=/does/not/exist.yaml")...)
+		assert.EqualError(t, err, "Unable to read the bootstrap file: open /does/not/exist.yaml: no such file or directory")
+	})
+}
+
+func Test_initBootstrapEnv(t *testing.T) {
+	writeBootstrap := func(t *testing.T, contents string) string {
+		path := t.TempDir() + "/bootstrap.yaml"
+		assert.NoError(t, os.WriteFile(path, []byte(contents), 0600))
+		return path
+	}
+
+	tests := []struct {
+		name      string
+		bootstrap string
+		want      string
+	}{{
+		"environment setting",
+		"environment: oss\nappliance_url: https://tenant.secretsmgr.cyberark.cloud/api\n",
+		"oss",
+	}, {
+		"SaaS URL",
+		"appliance_url: https://tenant.secretsmgr.cyberark.cloud\n",
+		"saas",
+	}, {
+		"self-hosted URL",
+		"appliance_url: https://conjur.example.com\n",
+		"self-hosted",
+	}, {
+		"invalid environment",
+		"environment: mainframe\n",
+		"self-hosted",
+	}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env, err := initBootstrapEnv([]string{"--from-file", writeBootstrap(t, tt.bootstrap)})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, env)
+		})
+	}
+
+	t.Run("without bootstrap", func(t *testing.T) {
+		env, err := initBootstrapEnv([]string{"--env", "oss"})
+		assert.NoError(t, err)
+		assert.Equal(t, "", env)
+	})
+}
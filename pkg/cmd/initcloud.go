@@ -11,6 +11,7 @@ import (
 	"github.com/cyberark/conjur-api-go/conjurapi"
 	"github.com/cyberark/conjur-cli-go/pkg/clients"
 	"github.com/cyberark/conjur-cli-go/pkg/prompts"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -21,9 +22,11 @@ type initCloudCmdFlagValues struct {
 	certFilePath       string
 	caCert             string
 	proxy              *url.URL
+	identityProxy      string
 	forceFileOverwrite bool
 	selfSigned         bool
 	forceNetrc         bool
+	credentialStorage  string
 	ccTimeout          time.Duration
 }
 
@@ -56,6 +59,10 @@ func getInitCloudCmdFlagValues(cmd *cobra.Command) (initCloudCmdFlagValues, erro
 	if err != nil {
 		return initCloudCmdFlagValues{}, fmt.Errorf("invalid proxy URL: %s", proxyS)
 	}
+	identityProxy, err := cmd.Flags().GetString("identity-proxy")
+	if err != nil {
+		return initCloudCmdFlagValues{}, err
+	}
 	selfSigned, err := cmd.Flags().GetBool("self-signed")
 	if err != nil {
 		return initCloudCmdFlagValues{}, err
@@ -64,6 +71,10 @@ func getInitCloudCmdFlagValues(cmd *cobra.Command) (initCloudCmdFlagValues, erro
 	if err != nil {
 		return initCloudCmdFlagValues{}, err
 	}
+	credentialStorage, err := cmd.Flags().GetString("credential-storage")
+	if err != nil {
+		return initCloudCmdFlagValues{}, err
+	}
 	ccTimeout, err := cmd.Flags().GetDuration("cc-timeout")
 	if err != nil {
 		return initCloudCmdFlagValues{}, err
@@ -75,9 +86,11 @@ func getInitCloudCmdFlagValues(cmd *cobra.Command) (initCloudCmdFlagValues, erro
 		certFilePath:       certFilePath,
 		caCert:             caCert,
 		proxy:              proxy,
+		identityProxy:      identityProxy,
 		forceFileOverwrite: forceFileOverwrite,
 		selfSigned:         selfSigned,
 		forceNetrc:         forceNetrc,
+		credentialStorage:  credentialStorage,
 		ccTimeout:          ccTimeout,
 	}, nil
 }
@@ -87,6 +100,21 @@ func validateCloudCmdFlags(cmdFlagVals initCloudCmdFlagValues, cmd *cobra.Comman
 		return fmt.Errorf("Secrets Manager SaaS URL must use HTTPS")
 	}
 
+	if cmdFlagVals.proxy.String() != "" {
+		if _, err := utils.ParseProxyURL(cmdFlagVals.proxy.String()); err != nil {
+			return fmt.Errorf("Invalid --proxy: %s", err)
+		}
+	}
+	if cmdFlagVals.identityProxy != "" {
+		if _, err := utils.ParseProxyURL(cmdFlagVals.identityProxy); err != nil {
+			return fmt.Errorf("Invalid --identity-proxy: %s", err)
+		}
+	}
+
+	if err := validateCredentialStorage(cmdFlagVals.credentialStorage, cmdFlagVals.forceNetrc); err != nil {
+		return err
+	}
+
 	if cmdFlagVals.selfSigned {
 		cmd.PrintErrln("Warning: Using self-signed certificates is not recommended and could lead to exposure of sensitive data")
 	}
@@ -101,6 +129,12 @@ func validateCloudCmdFlags(cmdFlagVals initCloudCmdFlagValues, cmd *cobra.Comman
 func runInitCloudCommand(cmd *cobra.Command) error {
 	var err error
 
+	bootstrapped, err := applyInitBootstrap(cmd, conjurapi.EnvironmentSaaS)
+	if err != nil {
+		return err
+	}
+	prompt := !bootstrapped
+
 	cmdFlagVals, err := getInitCloudCmdFlagValues(cmd)
 	if err != nil {
 		return err
@@ -133,6 +167,9 @@ func runInitCloudCommand(cmd *cobra.Command) error {
 	if cmdFlagVals.forceNetrc {
 		config.CredentialStorage = conjurapi.CredentialStorageFile
 	}
+	if cmdFlagVals.credentialStorage != "" {
+		config.CredentialStorage = cmdFlagVals.credentialStorage
+	}
 
 	// If user has specified a cert file, read it and set it on the config
 	if cmdFlagVals.caCert != "" {
@@ -152,12 +189,17 @@ func runInitCloudCommand(cmd *cobra.Command) error {
 		return err
 	}
 
+	cliConfig := clients.CLIConfig{IdentityProxy: cmdFlagVals.identityProxy}
+
 	err = fetchCertIfNeeded(
 		&config,
+		cliConfig,
 		false,
 		cmdFlagVals.selfSigned,
 		cmdFlagVals.forceFileOverwrite,
 		cmdFlagVals.certFilePath,
+		"",
+		prompt,
 	)
 	if err != nil {
 		return err
@@ -168,8 +210,10 @@ func runInitCloudCommand(cmd *cobra.Command) error {
 
 	err = writeConjurrc(
 		config,
+		cliConfig,
 		cmdFlagVals.conjurrcFilePath,
 		cmdFlagVals.forceFileOverwrite,
+		prompt,
 	)
 	if err != nil {
 		return err
@@ -194,7 +238,13 @@ func newCloudInitCmd() *cobra.Command {
 		Short:   "Initialize the Secrets Manager CLI with a Secrets Manager SaaS server",
 		Long: `Initialize the Secrets Manager CLI with a Secrets Manager SaaS server.
 
-The init command creates a configuration file (.conjurrc) that contains the details for connecting to Secrets Manager SaaS. This file is located under the user's root directory.`,
+The init command creates a configuration file (.conjurrc) that contains the details for connecting to Secrets Manager SaaS. This file is located under the user's root directory.
+
+` + initBootstrapHelp + `
+
+Examples:
+- conjur init saas -u https://tenant.secretsmgr.cyberark.cloud/api
+- conjur init saas --from-file bootstrap.yaml`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runInitCloudCommand(cmd)
@@ -212,11 +262,14 @@ The init command creates a configuration file (.conjurrc) that contains the deta
 	cmd.Flags().StringP("ca-cert", "c", "", "Secrets Manager SSL certificate (will be obtained from host unless provided by this option)")
 	cmd.Flags().StringP("file", "f", defaultConjurRC(userHomeDir), "File to write the configuration to. You must set the CONJURRC environment variable to the same value for this file to be used for further commands.")
 	cmd.Flags().String("cert-file", filepath.Join(userHomeDir, "conjur-server.pem"), "File to write the server's certificate to")
-	cmd.Flags().StringP("proxy", "p", "", "Proxy URL to use for connecting to Secrets Manager SaaS")
+	cmd.Flags().StringP("proxy", "p", "", "Proxy URL to use for connecting to Secrets Manager SaaS, http://, https:// or socks5:// with optional credentials. Hosts matching NO_PROXY are reached directly.")
+	cmd.Flags().String("identity-proxy", "", "Proxy URL to use for connecting to the Identity tenant, when it differs from --proxy")
 	cmd.Flags().BoolP("self-signed", "s", false, "Allow self-signed certificates (insecure)")
 	cmd.Flags().Bool("force-netrc", false, "Use a file-based credential storage rather than OS-native keystore (for compatibility with Summon)")
+	cmd.Flags().String("credential-storage", "", credentialStorageUsage)
 	cmd.Flags().Bool("force", false, "Force overwrite of existing configuration file")
 	cmd.Flags().Duration("cc-timeout", 5*time.Minute, "Timeout for authentication operations and requests to the Identity server")
+	addInitBootstrapFlags(cmd)
 
 	return cmd
 }
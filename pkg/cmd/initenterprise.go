@@ -6,7 +6,9 @@ import (
 	"path/filepath"
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
 	"github.com/cyberark/conjur-cli-go/pkg/prompts"
+	"github.com/cyberark/conjur-cli-go/pkg/utils"
 
 	"github.com/spf13/cobra"
 )
@@ -19,8 +21,17 @@ type initEnterpriseCmdFlagValues struct {
 	conjurrcFilePath   string
 	certFilePath       string
 	caCert             string
+	certFingerprint    string
+	clientCert         string
+	clientKey          string
+	clientCertBundle   string
+	trustAnchor        string
 	jwtFilePath        string
 	jwtHostID          string
+	jwtSource          string
+	metadataURL        string
+	azureClientID      string
+	credentialStorage  string
 	forceFileOverwrite bool
 	insecure           bool
 	selfSigned         bool
@@ -56,6 +67,26 @@ func getInitEnterpriseCmdFlagValues(cmd *cobra.Command) (initEnterpriseCmdFlagVa
 	if err != nil {
 		return initEnterpriseCmdFlagValues{}, err
 	}
+	certFingerprint, err := cmd.Flags().GetString("cert-fingerprint")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
+	clientCert, err := cmd.Flags().GetString("client-cert")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
+	clientKey, err := cmd.Flags().GetString("client-key")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
+	clientCertBundle, err := cmd.Flags().GetString("client-cert-bundle")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
+	trustAnchor, err := cmd.Flags().GetString("trust-anchor")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
 	jwtFilePath, err := cmd.Flags().GetString("jwt-file")
 	if err != nil {
 		return initEnterpriseCmdFlagValues{}, err
@@ -64,6 +95,22 @@ func getInitEnterpriseCmdFlagValues(cmd *cobra.Command) (initEnterpriseCmdFlagVa
 	if err != nil {
 		return initEnterpriseCmdFlagValues{}, err
 	}
+	jwtSource, err := cmd.Flags().GetString("jwt-source")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
+	metadataURL, err := cmd.Flags().GetString("metadata-url")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
+	azureClientID, err := cmd.Flags().GetString("azure-client-id")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
+	credentialStorage, err := cmd.Flags().GetString("credential-storage")
+	if err != nil {
+		return initEnterpriseCmdFlagValues{}, err
+	}
 	selfSigned, err := cmd.Flags().GetBool("self-signed")
 	if err != nil {
 		return initEnterpriseCmdFlagValues{}, err
@@ -89,8 +136,17 @@ func getInitEnterpriseCmdFlagValues(cmd *cobra.Command) (initEnterpriseCmdFlagVa
 		conjurrcFilePath:   conjurrcFilePath,
 		certFilePath:       certFilePath,
 		caCert:             caCert,
+		certFingerprint:    certFingerprint,
+		clientCert:         clientCert,
+		clientKey:          clientKey,
+		clientCertBundle:   clientCertBundle,
+		trustAnchor:        trustAnchor,
 		jwtFilePath:        jwtFilePath,
 		jwtHostID:          jwtHostID,
+		jwtSource:          jwtSource,
+		metadataURL:        metadataURL,
+		azureClientID:      azureClientID,
+		credentialStorage:  credentialStorage,
 		selfSigned:         selfSigned,
 		insecure:           insecure,
 		forceFileOverwrite: forceFileOverwrite,
@@ -105,6 +161,51 @@ func validateCmdFlags(cmdFlagVals initEnterpriseCmdFlagValues, cmd *cobra.Comman
 	if (cmdFlagVals.insecure || cmdFlagVals.selfSigned) && cmdFlagVals.caCert != "" {
 		return fmt.Errorf("Cannot specify --ca-cert when using --insecure or --self-signed")
 	}
+	if cmdFlagVals.certFingerprint != "" {
+		if cmdFlagVals.insecure {
+			return fmt.Errorf("Cannot specify both --insecure and --cert-fingerprint")
+		}
+		if _, err := utils.NormalizeFingerprint(cmdFlagVals.certFingerprint); err != nil {
+			return fmt.Errorf("Invalid --cert-fingerprint: %s", err)
+		}
+	}
+	if (cmdFlagVals.clientCert == "") != (cmdFlagVals.clientKey == "") {
+		return fmt.Errorf("Must specify both --client-cert and --client-key")
+	}
+	if cmdFlagVals.clientCertBundle != "" && cmdFlagVals.clientCert != "" {
+		return fmt.Errorf("Cannot specify both --client-cert-bundle and --client-cert")
+	}
+	if cmdFlagVals.insecure && (cmdFlagVals.clientCert != "" || cmdFlagVals.clientCertBundle != "") {
+		return fmt.Errorf("Cannot specify a client certificate when using --insecure")
+	}
+	if err := validateTrustAnchor(cmdFlagVals.trustAnchor); err != nil {
+		return err
+	}
+	if cmdFlagVals.trustAnchor != "" && (cmdFlagVals.insecure || cmdFlagVals.caCert != "") {
+		return fmt.Errorf("Cannot specify --trust-anchor when using --insecure or --ca-cert")
+	}
+
+	if cmdFlagVals.jwtSource != "" {
+		if cmdFlagVals.authnType != "jwt" {
+			return fmt.Errorf("Cannot specify --jwt-source unless the authentication type is JWT")
+		}
+		if cmdFlagVals.jwtFilePath != "" {
+			return fmt.Errorf("Cannot specify both --jwt-file and --jwt-source")
+		}
+		if _, err := clients.ParseJWTSource(cmdFlagVals.jwtSource); err != nil {
+			return err
+		}
+	}
+
+	if cmdFlagVals.metadataURL != "" && cmdFlagVals.authnType != "azure" && cmdFlagVals.authnType != "gcp" {
+		return fmt.Errorf("Cannot specify --metadata-url unless the authentication type is Azure or GCP")
+	}
+	if cmdFlagVals.azureClientID != "" && cmdFlagVals.authnType != "azure" {
+		return fmt.Errorf("Cannot specify --azure-client-id unless the authentication type is Azure")
+	}
+	if err := validateCredentialStorage(cmdFlagVals.credentialStorage, cmdFlagVals.forceNetrc); err != nil {
+		return err
+	}
 
 	if cmdFlagVals.selfSigned {
 		cmd.PrintErrln("Warning: Using self-signed certificates is not recommended and could lead to exposure of sensitive data")
@@ -119,6 +220,12 @@ func validateCmdFlags(cmdFlagVals initEnterpriseCmdFlagValues, cmd *cobra.Comman
 func runInitEnterpriseCommand(cmd *cobra.Command, funcs initCmdFuncs) error {
 	var err error
 
+	bootstrapped, err := applyInitBootstrap(cmd, env(cmd))
+	if err != nil {
+		return err
+	}
+	prompt := !bootstrapped
+
 	cmdFlagVals, err := getInitEnterpriseCmdFlagValues(cmd)
 	if err != nil {
 		return err
@@ -139,22 +246,57 @@ func runInitEnterpriseCommand(cmd *cobra.Command, funcs initCmdFuncs) error {
 	}
 
 	config := conjurapi.Config{
-		Account:      account,
-		ApplianceURL: applianceURL,
-		AuthnType:    cmdFlagVals.authnType,
-		ServiceID:    cmdFlagVals.serviceID,
-		JWTFilePath:  cmdFlagVals.jwtFilePath,
-		JWTHostID:    cmdFlagVals.jwtHostID,
-		Environment:  env(cmd),
+		Account:       account,
+		ApplianceURL:  applianceURL,
+		AuthnType:     cmdFlagVals.authnType,
+		ServiceID:     cmdFlagVals.serviceID,
+		JWTFilePath:   cmdFlagVals.jwtFilePath,
+		JWTHostID:     cmdFlagVals.jwtHostID,
+		Environment:   env(cmd),
+		AzureClientID: cmdFlagVals.azureClientID,
+	}
+	cliConfig := clients.CLIConfig{
+		JWTSource:   cmdFlagVals.jwtSource,
+		MetadataURL: cmdFlagVals.metadataURL,
+	}
+	if cmdFlagVals.certFingerprint != "" {
+		// Validated above
+		cliConfig.CertFingerprint, _ = utils.NormalizeFingerprint(cmdFlagVals.certFingerprint)
+	}
+
+	// The client certificate is presented to the servers requiring mutual TLS
+	if cmdFlagVals.clientCert != "" {
+		if config.ClientCertFile, err = filepath.Abs(cmdFlagVals.clientCert); err != nil {
+			return err
+		}
+		if config.ClientCertKeyFile, err = filepath.Abs(cmdFlagVals.clientKey); err != nil {
+			return err
+		}
+	}
+	if cmdFlagVals.clientCertBundle != "" {
+		if cliConfig.ClientCertBundle, err = filepath.Abs(cmdFlagVals.clientCertBundle); err != nil {
+			return err
+		}
+	}
+	// Load it now to report errors, such as a wrong passphrase, before anything is written
+	loadClientCertificate := clients.ClientCertificate
+	if !prompt {
+		loadClientCertificate = clients.ClientCertificateWithoutPrompt
+	}
+	if _, err = loadClientCertificate(config, cliConfig); err != nil {
+		return err
 	}
 
 	// If using JWT auth, we need to ensure that the JWT file exists and
 	// contains a valid JWT. To do this, we'll attempt to authenticate.
 	if config.AuthnType == "jwt" {
-		client, err := conjurapi.NewClientFromJwt(config)
+		client, err := clients.NewJWTClient(config, cliConfig)
 		if err != nil {
 			return err
 		}
+		if err = clients.UseClientCertificateForClient(client, cliConfig); err != nil {
+			return err
+		}
 		err = funcs.JWTAuthenticate(client)
 		if err != nil {
 			return fmt.Errorf("Unable to authenticate with Secrets Manager using the provided JWT file: %s", err)
@@ -165,6 +307,9 @@ func runInitEnterpriseCommand(cmd *cobra.Command, funcs initCmdFuncs) error {
 	if cmdFlagVals.forceNetrc {
 		config.CredentialStorage = conjurapi.CredentialStorageFile
 	}
+	if cmdFlagVals.credentialStorage != "" {
+		config.CredentialStorage = cmdFlagVals.credentialStorage
+	}
 
 	// If user has specified a cert file, read it and set it on the config
 	if cmdFlagVals.caCert != "" {
@@ -175,12 +320,12 @@ func runInitEnterpriseCommand(cmd *cobra.Command, funcs initCmdFuncs) error {
 		config.SSLCertPath = path
 	}
 
-	err = config.Validate()
+	err = clients.ValidateConfig(config, cliConfig)
 	if err != nil {
 		return err
 	}
 
-	err = fetchCertIfNeeded(&config, cmdFlagVals.insecure, cmdFlagVals.selfSigned, cmdFlagVals.forceFileOverwrite, cmdFlagVals.certFilePath)
+	err = fetchCertIfNeeded(&config, cliConfig, cmdFlagVals.insecure, cmdFlagVals.selfSigned, cmdFlagVals.forceFileOverwrite, cmdFlagVals.certFilePath, cmdFlagVals.trustAnchor, prompt)
 	if err != nil {
 		return err
 	}
@@ -188,10 +333,38 @@ func runInitEnterpriseCommand(cmd *cobra.Command, funcs initCmdFuncs) error {
 		cmd.Printf("Wrote certificate to %s\n", config.SSLCertPath)
 	}
 
+	// If using IAM auth, ensure that the credentials from the AWS credential chain are accepted.
+	// This needs the server certificate, so it's done once the certificate has been fetched.
+	if config.AuthnType == "iam" {
+		client, err := newInitCheckClient(config, cliConfig)
+		if err != nil {
+			return err
+		}
+		_, err = funcs.IAMLogin(client)
+		if err != nil {
+			return fmt.Errorf("Unable to authenticate with Secrets Manager using AWS IAM: %s", err)
+		}
+	}
+
+	// Likewise for Azure and GCP, ensure that the identity token from the instance metadata
+	// service is accepted
+	if config.AuthnType == "azure" || config.AuthnType == "gcp" {
+		client, err := newInitCheckClient(config, cliConfig)
+		if err != nil {
+			return err
+		}
+		_, err = funcs.InstanceMetadataLogin(client, cliConfig)
+		if err != nil {
+			return fmt.Errorf("Unable to authenticate with Secrets Manager using the instance metadata service: %s", err)
+		}
+	}
+
 	err = writeConjurrc(
 		config,
+		cliConfig,
 		cmdFlagVals.conjurrcFilePath,
 		cmdFlagVals.forceFileOverwrite,
+		prompt,
 	)
 	if err != nil {
 		return err
@@ -223,7 +396,14 @@ func newInitEnterpriseCommand(funcs initCmdFuncs) *cobra.Command {
 		Short:   "Initialize the Secrets Manager CLI with a Secrets Manager Self-Hosted or Conjur OSS server",
 		Long: `Initialize the Secrets Manager CLI with a Secrets Manager Self-Hosted or Conjur OSS server.
 
-The init command creates a configuration file (.conjurrc) that contains the details for connecting to Secrets Manager. This file is located under the user's root directory.`,
+The init command creates a configuration file (.conjurrc) that contains the details for connecting to Secrets Manager. This file is located under the user's root directory.
+
+` + initBootstrapHelp + `
+
+Examples:
+- conjur init self-hosted -u https://conjur.example.com -a myorg
+- conjur init --from-file bootstrap.yaml
+- CONJUR_APPLIANCE_URL=https://conjur.example.com CONJUR_ACCOUNT=myorg conjur init --from-env`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			return runInitEnterpriseCommand(cmd, funcs)
@@ -240,16 +420,46 @@ The init command creates a configuration file (.conjurrc) that contains the deta
 	cmd.Flags().StringP("account", "a", "", "Secrets Manager organization account name")
 	cmd.Flags().StringP("url", "u", "", "URL of the Secrets Manager service. Will prompt if omitted.")
 	cmd.Flags().StringP("ca-cert", "c", "", "Secrets Manager SSL certificate (will be obtained from host unless provided by this option)")
+	cmd.Flags().String("cert-fingerprint", "", "SHA-256 fingerprint the server's certificate must have, which is enforced by every subsequent command")
+	cmd.Flags().String("client-cert", "", "Client certificate (PEM) to present to servers requiring mutual TLS")
+	cmd.Flags().String("client-key", "", "Private key (PEM) of --client-cert, you are prompted for its passphrase if it is encrypted")
+	cmd.Flags().String("client-cert-bundle", "", "PKCS#12 bundle holding the client certificate and its key, as an alternative to --client-cert and --client-key")
+	cmd.Flags().String("trust-anchor", "", "Certificate of the server's chain to trust when the server's certificate isn't trusted by the system: server, intermediate or root. You are prompted to choose if omitted.")
 	cmd.Flags().StringP("file", "f", defaultConjurRC(userHomeDir), "File to write the configuration to. You must set the CONJURRC environment variable to the same value for this file to be used for further commands.")
 	cmd.Flags().String("cert-file", filepath.Join(userHomeDir, "conjur-server.pem"), "File to write the server's certificate to")
-	cmd.Flags().StringP("authn-type", "t", "", "Authentication type to use (e.g. LDAP, OIDC, JWT)")
+	cmd.Flags().StringP("authn-type", "t", "", "Authentication type to use (e.g. LDAP, OIDC, JWT, IAM, Azure, GCP)")
 	cmd.Flags().String("service-id", "", "Service ID if using alternative authentication type")
 	cmd.Flags().String("jwt-file", "", "Path to the JWT file if using authn-jwt")
-	cmd.Flags().String("jwt-host-id", "", "Host ID for authn-jwt (not required if JWT contains host ID)")
+	cmd.Flags().String("jwt-source", "", "Where to obtain the JWT from if using authn-jwt: file:<path>, env:<name>, exec:<command> or k8s[:<path>]")
+	cmd.Flags().String("metadata-url", "", "Base URL of the instance metadata service if using authn-azure or authn-gcp (defaults to the cloud's standard endpoint)")
+	cmd.Flags().String("azure-client-id", "", "Client ID of the user-assigned managed identity if using authn-azure")
+	cmd.Flags().String("jwt-host-id", "", "Host ID for authn-jwt (not required if JWT contains host ID), authn-iam, authn-azure or authn-gcp")
 	cmd.Flags().BoolP("self-signed", "s", false, "Allow self-signed certificates (insecure)")
 	cmd.Flags().BoolP("insecure", "i", false, "Allow non-HTTPS connections (insecure)")
 	cmd.Flags().Bool("force-netrc", false, "Use a file-based credential storage rather than OS-native keystore (for compatibility with Summon)")
+	cmd.Flags().String("credential-storage", "", credentialStorageUsage)
 	cmd.Flags().Bool("force", false, "Force overwrite of existing configuration file")
+	addInitBootstrapFlags(cmd)
 
 	return cmd
 }
+
+// newInitCheckClient creates the client used to check that the credentials of the host are accepted. It
+// reaches Conjur the same way later commands do, through the proxy, with the client certificate and the
+// pinned certificate fingerprint.
+func newInitCheckClient(config conjurapi.Config, cliConfig clients.CLIConfig) (*conjurapi.Client, error) {
+	client, err := conjurapi.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+	if err = clients.UseProxyForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	if err = clients.UseClientCertificateForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	if err = clients.PinCertificateForClient(client, cliConfig); err != nil {
+		return nil, err
+	}
+	return client, nil
+}
@@ -4,9 +4,11 @@ import (
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/hex"
 	"encoding/pem"
 	"fmt"
 	"math/big"
@@ -15,6 +17,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
@@ -26,14 +29,24 @@ import (
 )
 
 type mockInitClient struct {
-	t               *testing.T
-	jwtAuthenticate func(t *testing.T, client clients.ConjurClient) error
+	t                     *testing.T
+	jwtAuthenticate       func(t *testing.T, client clients.ConjurClient) error
+	iamLogin              func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error)
+	instanceMetadataLogin func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error)
 }
 
 func (m mockInitClient) JWTAuthenticate(client clients.ConjurClient) error {
 	return m.jwtAuthenticate(m.t, client)
 }
 
+func (m mockInitClient) IAMLogin(client clients.ConjurClient) (clients.ConjurClient, error) {
+	return m.iamLogin(m.t, client)
+}
+
+func (m mockInitClient) InstanceMetadataLogin(client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error) {
+	return m.instanceMetadataLogin(m.t, client, cliConfig)
+}
+
 var initEnterpriseCmdTestCases = []struct {
 	name string
 	// NOTE: -f defaults to conjurrcInTmpDir in the args slice.
@@ -44,10 +57,12 @@ var initEnterpriseCmdTestCases = []struct {
 	// Being unable to pipe responses to prompts is a known shortcoming of Survey.
 	// https://github.com/go-survey/survey/issues/394
 	// This flag is used to enable Pipe-based, and not PTY-based, tests.
-	pipe            bool
-	beforeTest      func(t *testing.T, conjurrcInTmpDir string) func()
-	assert          func(t *testing.T, conjurrcInTmpDir string, stdout string)
-	jwtAuthenticate func(t *testing.T, client clients.ConjurClient) error
+	pipe                  bool
+	beforeTest            func(t *testing.T, conjurrcInTmpDir string) func()
+	assert                func(t *testing.T, conjurrcInTmpDir string, stdout string)
+	jwtAuthenticate       func(t *testing.T, client clients.ConjurClient) error
+	iamLogin              func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error)
+	instanceMetadataLogin func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error)
 }{
 	{
 		name: "help",
@@ -117,6 +132,127 @@ environment: self-hosted
 			assert.Contains(t, stdout, "Wrote configuration to "+conjurrcInTmpDir)
 		},
 	},
+	{
+		name: "writes conjurrc for jwt with a jwt source",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=jwt", "--service-id=test", "--jwt-source=exec:echo header.payload.signature", "-i"},
+		jwtAuthenticate: func(t *testing.T, client clients.ConjurClient) error {
+			assert.Equal(t, "header.payload.signature", client.GetConfig().JWTContent)
+			return nil
+		},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			data, _ := os.ReadFile(conjurrcInTmpDir)
+			expectedConjurrc := `account: test-account
+appliance_url: http://host
+authn_type: jwt
+service_id: test
+environment: self-hosted
+jwt_source: exec:echo header.payload.signature
+`
+
+			assert.Equal(t, expectedConjurrc, string(data))
+		},
+	},
+	{
+		name: "fails with an invalid jwt source",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=jwt", "--service-id=test", "--jwt-source=ftp://token", "-i"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Error: invalid JWT source \"ftp://token\"")
+		},
+	},
+	{
+		name: "fails with a jwt source for another authn type",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "--jwt-source=env:TOKEN", "-i"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Error: Cannot specify --jwt-source unless the authentication type is JWT")
+		},
+	},
+	{
+		name: "writes conjurrc for iam",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=iam", "--service-id=prod", "--jwt-host-id=host/app", "-i"},
+		iamLogin: func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error) {
+			assert.Equal(t, "iam", client.GetConfig().AuthnType)
+			return client, nil
+		},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			data, _ := os.ReadFile(conjurrcInTmpDir)
+			expectedConjurrc := `account: test-account
+appliance_url: http://host
+authn_type: iam
+service_id: prod
+jwt_host_id: host/app
+environment: self-hosted
+`
+
+			assert.Equal(t, expectedConjurrc, string(data))
+			assert.Contains(t, stdout, "Wrote configuration to "+conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails for iam without a host id",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=iam", "--service-id=prod", "-i"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Must specify a HostID when using iam authentication")
+		},
+	},
+	{
+		name: "fails when iam authentication fails",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=iam", "--service-id=prod", "--jwt-host-id=host/app", "-i"},
+		iamLogin: func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error) {
+			return nil, fmt.Errorf("Unable to obtain AWS credentials")
+		},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Error: Unable to authenticate with Secrets Manager using AWS IAM: Unable to obtain AWS credentials")
+			_, err := os.Stat(conjurrcInTmpDir)
+			assert.True(t, os.IsNotExist(err))
+		},
+	},
+	{
+		name: "writes conjurrc for azure",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=azure", "--service-id=prod", "--jwt-host-id=host/app",
+			"--azure-client-id=client-id", "--metadata-url=http://127.0.0.1:8080", "-i"},
+		instanceMetadataLogin: func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error) {
+			assert.Equal(t, "http://127.0.0.1:8080", cliConfig.MetadataURL)
+			return client, nil
+		},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			data, _ := os.ReadFile(conjurrcInTmpDir)
+			expectedConjurrc := `account: test-account
+appliance_url: http://host
+authn_type: azure
+service_id: prod
+jwt_host_id: host/app
+environment: self-hosted
+azure_client_id: client-id
+metadata_url: http://127.0.0.1:8080
+`
+
+			assert.Equal(t, expectedConjurrc, string(data))
+		},
+	},
+	{
+		name: "fails for gcp without a host id",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=gcp", "-i"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Error: Must specify a HostID when using gcp authentication")
+		},
+	},
+	{
+		name: "fails with a metadata url for another authn type",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=iam", "--service-id=prod", "--metadata-url=http://127.0.0.1:8080", "-i"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Error: Cannot specify --metadata-url unless the authentication type is Azure or GCP")
+		},
+	},
+	{
+		name: "fails when gcp authentication fails",
+		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=gcp", "--jwt-host-id=host/app", "-i"},
+		instanceMetadataLogin: func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error) {
+			return nil, fmt.Errorf("unable to reach the instance metadata service")
+		},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Error: Unable to authenticate with Secrets Manager using the instance metadata service: unable to reach the instance metadata service")
+		},
+	},
 	{
 		name: "fails when jwt authentication fails",
 		args: []string{"init", "enterprise", "-u=http://host", "-a=test-account", "-t=jwt", "--service-id=test", "--jwt-file=/path/to/jwt", "--jwt-host-id=host-id", "-i"},
@@ -295,6 +431,84 @@ environment: self-hosted
 			assertCertWritten(t, conjurrcInTmpDir, stdout)
 		},
 	},
+	{
+		name: "fails for a certificate that doesn't match --cert-fingerprint",
+		args: []string{"init", "enterprise", "-u=https://localhost:8080", "-a=test-account", "--cert-fingerprint=" + strings.Repeat("00", 32)},
+		beforeTest: func(t *testing.T, conjurrcInTmpDir string) func() {
+			return startSelfSignedServer(t, 8080)
+		},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "which doesn't match --cert-fingerprint "+strings.Repeat("00", 32))
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails for an invalid --cert-fingerprint",
+		args: []string{"init", "enterprise", "-u=https://localhost:8080", "-a=test-account", "--cert-fingerprint=AB:CD"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Invalid --cert-fingerprint")
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails if both --insecure and --cert-fingerprint are specified",
+		args: []string{"init", "enterprise", "-u=http://example.com", "-a=test-account", "--insecure", "--cert-fingerprint=" + strings.Repeat("00", 32)},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Cannot specify both --insecure and --cert-fingerprint")
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails if only --client-cert is specified",
+		args: []string{"init", "enterprise", "-u=https://localhost:8080", "-a=test-account", "--client-cert=client.crt"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Must specify both --client-cert and --client-key")
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails if both --client-cert-bundle and --client-cert are specified",
+		args: []string{"init", "enterprise", "-u=https://localhost:8080", "-a=test-account", "--client-cert-bundle=client.p12", "--client-cert=client.crt", "--client-key=client.key"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Cannot specify both --client-cert-bundle and --client-cert")
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails if both --insecure and a client certificate are specified",
+		args: []string{"init", "enterprise", "-u=http://example.com", "-a=test-account", "--insecure", "--client-cert-bundle=client.p12"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Cannot specify a client certificate when using --insecure")
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails for a missing client certificate",
+		args: []string{"init", "enterprise", "-u=https://localhost:8080", "-a=test-account", "--client-cert=missing.crt", "--client-key=missing.key"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "Unable to load the client certificate")
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails for an invalid --trust-anchor",
+		args: []string{"init", "enterprise", "-u=https://localhost:8080", "-a=test-account", "--trust-anchor=leaf"},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, `Invalid --trust-anchor "leaf"`)
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
+	{
+		name: "fails if the server doesn't present its root CA",
+		args: []string{"init", "enterprise", "-u=https://localhost:8080", "-a=test-account", "--self-signed", "--trust-anchor=root"},
+		beforeTest: func(t *testing.T, conjurrcInTmpDir string) func() {
+			return startSelfSignedServer(t, 8080)
+		},
+		assert: func(t *testing.T, conjurrcInTmpDir string, stdout string) {
+			assert.Contains(t, stdout, "The server doesn't present its root CA certificate")
+			assertFetchCertFailed(t, conjurrcInTmpDir)
+		},
+	},
 	{
 		name: "fails for http urls",
 		args: []string{"init", "enterprise", "-u=http://example.com", "-a=test-account"},
@@ -401,10 +615,17 @@ func TestInitEnterpriseCmd(t *testing.T) {
 			args = append(args, tc.args...)
 
 			// Create command tree for init
-			mockClient := mockInitClient{t: t, jwtAuthenticate: tc.jwtAuthenticate}
+			mockClient := mockInitClient{
+				t:                     t,
+				jwtAuthenticate:       tc.jwtAuthenticate,
+				iamLogin:              tc.iamLogin,
+				instanceMetadataLogin: tc.instanceMetadataLogin,
+			}
 
 			cmd := newInitEnterpriseCommand(initCmdFuncs{
-				JWTAuthenticate: mockClient.JWTAuthenticate,
+				JWTAuthenticate:       mockClient.JWTAuthenticate,
+				IAMLogin:              mockClient.IAMLogin,
+				InstanceMetadataLogin: mockClient.InstanceMetadataLogin,
 			})
 			rootCmd := newRootCommand()
 			initCmd := newInitCommand()
@@ -431,6 +652,109 @@ func TestInitEnterpriseCmd(t *testing.T) {
 	}
 
 	// Other tests
+	t.Run("pins the certificate matching --cert-fingerprint", func(t *testing.T) {
+		tempDir := t.TempDir()
+		conjurrcInTmpDir := tempDir + "/.conjurrc"
+		certDir := t.TempDir()
+		defer startSelfSignedServerIn(t, 8080, certDir)()
+
+		data, err := os.ReadFile(filepath.Join(certDir, "localhost.crt"))
+		assert.NoError(t, err)
+		block, _ := pem.Decode(data)
+		sum := sha256.Sum256(block.Bytes)
+		// Fingerprints are accepted in lowercase and with colons
+		fingerprint := hex.EncodeToString(sum[:])
+		colonFingerprint := ""
+		for i := 0; i < len(fingerprint); i += 2 {
+			if i > 0 {
+				colonFingerprint += ":"
+			}
+			colonFingerprint += fingerprint[i : i+2]
+		}
+
+		initCmd := newInitCommand()
+		initCmd.AddCommand(newInitEnterpriseCommand(defaultInitCmdFuncs))
+		out, _, err := executeCommandForTest(t, initCmd,
+			"--file="+conjurrcInTmpDir,
+			"--cert-file="+tempDir+"/conjur-server.pem",
+			"init", "enterprise", "-u=https://localhost:8080", "-a=test-account",
+			"--cert-fingerprint="+colonFingerprint,
+		)
+		assert.NoError(t, err)
+
+		// The certificate is trusted without prompting
+		assert.NotContains(t, out, "Do you want to trust this certificate?")
+		assertCertWritten(t, conjurrcInTmpDir, out)
+		data, _ = os.ReadFile(conjurrcInTmpDir)
+		assert.Contains(t, string(data), "cert_fingerprint: "+strings.ToUpper(fingerprint))
+	})
+
+	t.Run("persists the client certificate", func(t *testing.T) {
+		tempDir := t.TempDir()
+		conjurrcInTmpDir := tempDir + "/.conjurrc"
+		defer startSelfSignedServerIn(t, 8080, tempDir)()
+
+		// Any certificate and key pair will do
+		certFile, keyFile := tempDir+"/client.crt", tempDir+"/client.key"
+		data, err := os.ReadFile(filepath.Join(tempDir, "localhost.crt"))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(certFile, data, 0600))
+		data, err = os.ReadFile(filepath.Join(tempDir, "localhost.key"))
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(keyFile, data, 0600))
+
+		initCmd := newInitCommand()
+		initCmd.AddCommand(newInitEnterpriseCommand(defaultInitCmdFuncs))
+		out, _, err := executeCommandForTest(t, initCmd,
+			"--file="+conjurrcInTmpDir,
+			"--cert-file="+tempDir+"/conjur-server.pem",
+			"init", "enterprise", "-u=https://localhost:8080", "-a=test-account",
+			"--client-cert="+certFile, "--client-key="+keyFile,
+			"--ca-cert="+tempDir+"/client.crt",
+		)
+		assert.NoError(t, err)
+		assert.Contains(t, out, "Wrote configuration to "+conjurrcInTmpDir)
+
+		data, _ = os.ReadFile(conjurrcInTmpDir)
+		assert.Contains(t, string(data), "client_cert_file: "+certFile)
+		assert.Contains(t, string(data), "client_cert_key_file: "+keyFile)
+	})
+
+	t.Run("trusts the CA selected by --trust-anchor", func(t *testing.T) {
+		chain, key := newTestCertChain(t)
+		server := httptest.NewUnstartedServer(http.NotFoundHandler())
+		l, err := net.Listen("tcp", "localhost:8080")
+		assert.NoError(t, err)
+		server.Listener = l
+		server.TLS = &tls.Config{Certificates: []tls.Certificate{{
+			Certificate: [][]byte{chain[0].Raw, chain[1].Raw, chain[2].Raw},
+			PrivateKey:  key,
+		}}}
+		server.StartTLS()
+		defer server.Close()
+
+		for anchor, expected := range map[string]string{
+			"server":       encodeTestCerts(chain[0]),
+			"intermediate": encodeTestCerts(chain[1]),
+			"root":         encodeTestCerts(chain[1:]...),
+		} {
+			tempDir := t.TempDir()
+			conjurrcInTmpDir := tempDir + "/.conjurrc"
+			initCmd := newInitCommand()
+			initCmd.AddCommand(newInitEnterpriseCommand(defaultInitCmdFuncs))
+			out, _, err := executeCommandForTest(t, initCmd,
+				"--file="+conjurrcInTmpDir,
+				"--cert-file="+tempDir+"/conjur-server.pem",
+				"init", "enterprise", "-u=https://localhost:8080", "-a=test-account",
+				"--self-signed", "--trust-anchor="+anchor,
+			)
+			assert.NoError(t, err)
+			assertCertWritten(t, conjurrcInTmpDir, out)
+			data, _ := os.ReadFile(tempDir + "/conjur-server.pem")
+			assert.Equal(t, expected, string(data), anchor)
+		}
+	})
+
 	t.Run("default flags", func(t *testing.T) {
 
 		cmd := newInitEnterpriseCommand(defaultInitCmdFuncs)
@@ -474,10 +798,16 @@ func assertCertWritten(t *testing.T, conjurrcInTmpDir string, stdout string) {
 }
 
 func startSelfSignedServer(t *testing.T, port int) func() {
-	err := generateSelfSignedCert()
+	return startSelfSignedServerIn(t, port, t.TempDir())
+}
+
+// startSelfSignedServerIn starts a server with a self-signed certificate, which is written to
+// localhost.crt and localhost.key in certDir
+func startSelfSignedServerIn(t *testing.T, port int, certDir string) func() {
+	err := generateSelfSignedCert(certDir)
 	assert.NoError(t, err, "failed to generate self-signed certificate")
 	// Load your custom cert and key
-	cert, err := tls.LoadX509KeyPair("localhost.crt", "localhost.key")
+	cert, err := tls.LoadX509KeyPair(filepath.Join(certDir, "localhost.crt"), filepath.Join(certDir, "localhost.key"))
 	assert.NoError(t, err, "failed to load cert/key")
 
 	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -497,7 +827,9 @@ func startSelfSignedServer(t *testing.T, port int) func() {
 	return func() { server.Close() }
 }
 
-func generateSelfSignedCert() error {
+// generateSelfSignedCert writes a self-signed certificate for localhost and its key to
+// localhost.crt and localhost.key in dir
+func generateSelfSignedCert(dir string) error {
 	// Generate a private key
 	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -528,7 +860,7 @@ func generateSelfSignedCert() error {
 	}
 
 	// Save the certificate to a file
-	certFile, err := os.Create("localhost.crt")
+	certFile, err := os.Create(filepath.Join(dir, "localhost.crt"))
 	if err != nil {
 		return err
 	}
@@ -540,7 +872,7 @@ func generateSelfSignedCert() error {
 	}
 
 	// Save the private key to a file
-	keyFile, err := os.Create("localhost.key")
+	keyFile, err := os.Create(filepath.Join(dir, "localhost.key"))
 	if err != nil {
 		return err
 	}
@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"strings"
 	"time"
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
@@ -13,24 +16,41 @@ import (
 
 type loginCmdFuncs struct {
 	LoadAndValidateConjurConfig func(timeout time.Duration) (conjurapi.Config, error)
+	LoadCLIConfig               func() (clients.CLIConfig, error)
 	LoginWithPromptFallback     func(client clients.ConjurClient, username string, password string) (*authn.LoginPair, error)
-	OidcLogin                   func(conjurClient clients.ConjurClient, username string, password string) (clients.ConjurClient, error)
+	OidcLogin                   func(conjurClient clients.ConjurClient, username string, password string, out io.Writer) (clients.ConjurClient, error)
+	OidcDeviceLogin             func(conjurClient clients.ConjurClient, cliConfig clients.CLIConfig, issuer string, out io.Writer) (clients.ConjurClient, error)
+	OidcManualLogin             func(conjurClient clients.ConjurClient, out io.Writer) (clients.ConjurClient, error)
 	JWTAuthenticate             func(conjurClient clients.ConjurClient) error
 	CloudLogin                  func(conjurClient clients.ConjurClient, username string, password string) (clients.ConjurClient, error)
+	IAMLogin                    func(conjurClient clients.ConjurClient) (clients.ConjurClient, error)
+	InstanceMetadataLogin       func(conjurClient clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error)
+	SaveActiveIdentity          func(config conjurapi.Config) error
 }
 
 var defaultLoginCmdFuncs = loginCmdFuncs{
 	LoadAndValidateConjurConfig: clients.LoadAndValidateConjurConfig,
+	LoadCLIConfig:               clients.LoadCLIConfig,
 	LoginWithPromptFallback:     clients.LoginWithPromptFallback,
 	OidcLogin:                   clients.OidcLogin,
+	OidcDeviceLogin:             clients.OidcDeviceLogin,
+	OidcManualLogin:             clients.OidcManualLogin,
 	JWTAuthenticate:             clients.JWTAuthenticate,
 	CloudLogin:                  clients.CloudLogin,
+	IAMLogin:                    clients.IAMLogin,
+	InstanceMetadataLogin:       clients.InstanceMetadataLogin,
+	SaveActiveIdentity:          clients.SaveActiveIdentity,
 }
 
 type loginCmdFlagValues struct {
-	identity string
-	password string
-	debug    bool
+	identity      string
+	password      string
+	passwordStdin bool
+	apiKeyFile    string
+	deviceCode    bool
+	oidcIssuer    string
+	noBrowser     bool
+	debug         bool
 }
 
 func getLoginCmdFlagValues(cmd *cobra.Command) (loginCmdFlagValues, error) {
@@ -46,6 +66,31 @@ func getLoginCmdFlagValues(cmd *cobra.Command) (loginCmdFlagValues, error) {
 		return loginCmdFlagValues{}, err
 	}
 
+	passwordStdin, err := cmd.Flags().GetBool("password-stdin")
+	if err != nil {
+		return loginCmdFlagValues{}, err
+	}
+
+	apiKeyFile, err := cmd.Flags().GetString("api-key-file")
+	if err != nil {
+		return loginCmdFlagValues{}, err
+	}
+
+	deviceCode, err := cmd.Flags().GetBool("device-code")
+	if err != nil {
+		return loginCmdFlagValues{}, err
+	}
+
+	oidcIssuer, err := cmd.Flags().GetString("oidc-issuer")
+	if err != nil {
+		return loginCmdFlagValues{}, err
+	}
+
+	noBrowser, err := cmd.Flags().GetBool("no-browser")
+	if err != nil {
+		return loginCmdFlagValues{}, err
+	}
+
 	debug, err := cmd.Flags().GetBool("debug")
 
 	if err != nil {
@@ -53,26 +98,78 @@ func getLoginCmdFlagValues(cmd *cobra.Command) (loginCmdFlagValues, error) {
 	}
 
 	return loginCmdFlagValues{
-		identity: identity,
-		password: password,
-		debug:    debug,
+		identity:      identity,
+		password:      password,
+		passwordStdin: passwordStdin,
+		apiKeyFile:    apiKeyFile,
+		deviceCode:    deviceCode,
+		oidcIssuer:    oidcIssuer,
+		noBrowser:     noBrowser,
+		debug:         debug,
 	}, nil
 }
 
+// readLoginPassword returns the password or API key passed with --password-stdin or --api-key-file,
+// which unlike --password keep it out of the process list and shell history
+func readLoginPassword(cmd *cobra.Command, cmdFlagVals loginCmdFlagValues) (string, error) {
+	sources := 0
+	for _, set := range []bool{cmdFlagVals.password != "", cmdFlagVals.passwordStdin, cmdFlagVals.apiKeyFile != ""} {
+		if set {
+			sources++
+		}
+	}
+	if sources > 1 {
+		return "", fmt.Errorf("Only one of --password, --password-stdin and --api-key-file can be specified")
+	}
+
+	switch {
+	case cmdFlagVals.passwordStdin:
+		data, err := io.ReadAll(cmd.InOrStdin())
+		if err != nil {
+			return "", err
+		}
+		password := strings.TrimRight(string(data), "\r\n")
+		if password == "" {
+			return "", fmt.Errorf("No password or API key was provided on stdin")
+		}
+		return password, nil
+	case cmdFlagVals.apiKeyFile != "":
+		data, err := os.ReadFile(cmdFlagVals.apiKeyFile)
+		if err != nil {
+			return "", err
+		}
+		apiKey := strings.TrimSpace(string(data))
+		if apiKey == "" {
+			return "", fmt.Errorf("API key file %s is empty", cmdFlagVals.apiKeyFile)
+		}
+		return apiKey, nil
+	default:
+		return cmdFlagVals.password, nil
+	}
+}
+
 func newLoginCmd(funcs loginCmdFuncs) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "login",
 		Short: "Authenticate with Secrets Manager using the provided identity and password",
 		Long: `Authenticate with Secrets Manager using the provided identity and password.
 
-The command will prompt for identity and password if they are not provided via flags.
+The command will prompt for identity and password if they are not provided via flags or the CONJUR_AUTHN_LOGIN and CONJUR_AUTHN_API_KEY environment variables. In non-interactive sessions, such as scripts and CI jobs, it fails instead of prompting. Use --password-stdin or --api-key-file rather than --password there, to keep the secret out of the process list and shell history.
+
+When using the AWS IAM authenticator, login verifies that the credentials from the standard AWS credential chain are accepted by Secrets Manager. Nothing is cached, every command authenticates with a newly signed request. The Azure and GCP authenticators work the same way, with an identity token from the instance metadata service of the VM.
+
+When using the OIDC authenticator, login opens a browser to authenticate with the identity provider. On machines without a browser, such as over SSH or in a remote container, use --device-code to get a code to enter on another device instead, or --no-browser to open the login URL yourself and paste back the URL you are redirected to. The latter is also offered automatically when the browser can't be opened or the local callback port is in use.
 
-On successful login, the password is exchanged for the user's API key, which is cached in the operating system user's credential storage or .netrc file. Subsequent commands will authenticate using the cached credentials. To switch users, login again using new credentials. To erase credentials, use the 'logout' command.
+On successful login, the password is exchanged for the user's API key, which is cached in the operating system user's credential storage or .netrc file. Subsequent commands will authenticate using the cached credentials. To switch users, login again using new credentials. The credentials of the previous identity remain cached, use 'conjur identity use' to switch back to them or the global --as flag to run a single command as another identity. To erase credentials, use the 'logout' command.
 
 Examples:
 
 - conjur login -i alice -p My$ecretPass
-- conjur login`,
+- conjur login
+- echo "$API_KEY" | conjur login -i host/ci/runner --password-stdin
+- conjur login -i host/ci/runner --api-key-file /run/secrets/conjur-api-key
+- conjur login --device-code
+- conjur login --no-browser`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var err error
@@ -81,6 +178,10 @@ Examples:
 			if err != nil {
 				return err
 			}
+			cmdFlagVals.password, err = readLoginPassword(cmd, cmdFlagVals)
+			if err != nil {
+				return err
+			}
 
 			timeout, err := clients.GetTimeout(cmd)
 			if err != nil {
@@ -92,28 +193,73 @@ Examples:
 				return err
 			}
 
+			cliConfig, err := funcs.LoadCLIConfig()
+			if err != nil {
+				return err
+			}
+			if warning := clients.CertExpiryWarning(config, cliConfig, time.Now()); warning != "" {
+				cmd.PrintErrln(warning)
+			}
+
 			// TODO: I should be able to create a client and unauthenticated client
-			conjurClient, err := conjurapi.NewClient(config)
+			conjurClient, err := conjurapi.NewClient(clients.APIConfig(config))
 			if err != nil {
 				return err
 			}
 
-			if cmdFlagVals.debug {
-				clients.MaybeDebugLoggingForClient(cmdFlagVals.debug, cmd, conjurClient)
+			if err = clients.UseProxyForClient(conjurClient, cliConfig); err != nil {
+				return err
+			}
+			if err = clients.UseClientCertificateForClient(conjurClient, cliConfig); err != nil {
+				return err
+			}
+			if err = clients.PinCertificateForClient(conjurClient, cliConfig); err != nil {
+				return err
+			}
+			clients.RecordHTTPForClient(cmd, conjurClient)
+			if err = clients.RetryTransientErrorsForClient(cmd, conjurClient); err != nil {
+				return err
+			}
+			clients.MaybeDebugLoggingForClient(cmdFlagVals.debug, cmd, conjurClient)
+
+			if cmdFlagVals.oidcIssuer != "" && !cmdFlagVals.deviceCode {
+				return fmt.Errorf("--oidc-issuer can only be used with --device-code")
+			}
+			if cmdFlagVals.deviceCode && cmdFlagVals.noBrowser {
+				return fmt.Errorf("--device-code and --no-browser cannot be used together")
+			}
+			if (cmdFlagVals.deviceCode || cmdFlagVals.noBrowser) && config.AuthnType != "oidc" {
+				return fmt.Errorf("--device-code and --no-browser are only supported with the OIDC authenticator")
+			}
+
+			// Keep the credentials of the identity that is logged in, so that they aren't overwritten
+			if err = funcs.SaveActiveIdentity(config); err != nil {
+				return fmt.Errorf("Unable to cache the credentials of the logged in identity: %s", err)
 			}
 
 			if config.AuthnType == "" || config.AuthnType == "authn" || config.AuthnType == "ldap" {
-				_, err = funcs.LoginWithPromptFallback(conjurClient, cmdFlagVals.identity, cmdFlagVals.password)
+				var loginPair *authn.LoginPair
+				loginPair, err = funcs.LoginWithPromptFallback(conjurClient, cmdFlagVals.identity, cmdFlagVals.password)
+				if err == nil {
+					err = clients.StoreCredentials(config, *loginPair)
+				}
+			} else if config.AuthnType == "oidc" && cmdFlagVals.deviceCode {
+				_, err = funcs.OidcDeviceLogin(conjurClient, cliConfig, cmdFlagVals.oidcIssuer, cmd.ErrOrStderr())
+			} else if config.AuthnType == "oidc" && cmdFlagVals.noBrowser {
+				_, err = funcs.OidcManualLogin(conjurClient, cmd.ErrOrStderr())
 			} else if config.AuthnType == "oidc" {
-				_, err = funcs.OidcLogin(conjurClient, cmdFlagVals.identity, cmdFlagVals.password)
+				_, err = funcs.OidcLogin(conjurClient, cmdFlagVals.identity, cmdFlagVals.password, cmd.ErrOrStderr())
 			} else if config.AuthnType == "jwt" {
 				// We have to recreate the client with the JWT method so it
 				// attaches a JWTAuthenticator to the client otherwise
 				// conjurClient.GetAuthenticator() will return nil
-				conjurClient, err = conjurapi.NewClientFromJwt(config)
+				httpClient := conjurClient.GetHttpClient()
+				conjurClient, err = clients.NewJWTClient(config, cliConfig)
 				if err != nil {
 					return err
 				}
+				// Keep the pinned certificate and the HTTP logs
+				conjurClient.SetHttpClient(httpClient)
 				// Just run authenticate to validate the jwt. This isn't
 				// necessary (since the JWT path is set in the `init` command)
 				// but is provided as a convenience to the user, to allow them
@@ -122,19 +268,42 @@ Examples:
 				if err != nil {
 					err = fmt.Errorf("Unable to authenticate with Secrets Manager using the provided JWT file: %s", err)
 				}
+			} else if config.AuthnType == "iam" {
+				// Authenticate with a signed request from the AWS credential chain. No credentials
+				// are stored, subsequent commands sign a new request.
+				_, err = funcs.IAMLogin(conjurClient)
+				if err != nil {
+					err = fmt.Errorf("Unable to authenticate with Secrets Manager using AWS IAM: %s", err)
+				}
+			} else if config.AuthnType == "azure" || config.AuthnType == "gcp" {
+				// Authenticate with an identity token from the instance metadata service. Like IAM,
+				// nothing is stored and subsequent commands fetch a new token.
+				_, err = funcs.InstanceMetadataLogin(conjurClient, cliConfig)
+				if err != nil {
+					err = fmt.Errorf("Unable to authenticate with Secrets Manager using the instance metadata service: %s", err)
+				}
 			} else if config.AuthnType == "cloud" {
 				// If the user is using the cloud authn type, we need to
 				// authenticate with the cloud login method.
-				_, err := funcs.CloudLogin(conjurClient, cmdFlagVals.identity, cmdFlagVals.password)
+				cloudClient, err := funcs.CloudLogin(conjurClient, cmdFlagVals.identity, cmdFlagVals.password)
 				if err != nil {
 					return fmt.Errorf("Unable to authenticate with Secrets Manager SaaS: %s", err)
 				}
+				// Hosts login with an API key
+				if authenticator, ok := cloudClient.GetAuthenticator().(*authn.APIKeyAuthenticator); ok {
+					if err = clients.StoreCredentials(config, authenticator.LoginPair); err != nil {
+						return err
+					}
+				}
 			} else {
 				return fmt.Errorf("unsupported authentication type: %s", config.AuthnType)
 			}
 			if err != nil {
 				return err
 			}
+			if err = funcs.SaveActiveIdentity(config); err != nil {
+				return fmt.Errorf("Unable to cache the credentials of the logged in identity: %s", err)
+			}
 
 			cmd.Println("Logged in")
 			return nil
@@ -143,6 +312,11 @@ Examples:
 
 	cmd.Flags().StringP("id", "i", "", "The identity to authenticate with. For hosts: 'host/<full path>'.")
 	cmd.Flags().StringP("password", "p", "", "Password or API key for the specified identity.")
+	cmd.Flags().Bool("password-stdin", false, "Read the password or API key from stdin.")
+	cmd.Flags().String("api-key-file", "", "Read the API key from a file.")
+	cmd.Flags().Bool("device-code", false, "Use the OAuth 2.0 device authorization grant to login with OIDC, without opening a browser.")
+	cmd.Flags().Bool("no-browser", false, "Login with OIDC by opening the login URL yourself and pasting back the URL you are redirected to.")
+	cmd.Flags().String("oidc-issuer", "", "Issuer URL of the OIDC provider, used to discover its endpoints with --device-code.")
 
 	return cmd
 }
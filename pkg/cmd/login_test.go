@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -15,21 +18,41 @@ type mockLoginClient struct {
 	t                       *testing.T
 	loginWithPromptFallback func(t *testing.T, client clients.ConjurClient, username string, password string) (*authn.LoginPair, error)
 	oidcLogin               func(t *testing.T, client clients.ConjurClient, username string, password string) (clients.ConjurClient, error)
+	oidcDeviceLogin         func(t *testing.T, client clients.ConjurClient, issuer string) (clients.ConjurClient, error)
+	oidcManualLogin         func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error)
 	jwtAuthenticate         func(t *testing.T, client clients.ConjurClient) error
+	iamLogin                func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error)
+	instanceMetadataLogin   func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error)
 }
 
 func (m mockLoginClient) LoginWithPromptFallback(client clients.ConjurClient, username string, password string) (*authn.LoginPair, error) {
 	return m.loginWithPromptFallback(m.t, client, username, password)
 }
 
-func (m mockLoginClient) OidcLogin(client clients.ConjurClient, username string, password string) (clients.ConjurClient, error) {
+func (m mockLoginClient) OidcLogin(client clients.ConjurClient, username string, password string, out io.Writer) (clients.ConjurClient, error) {
 	return m.oidcLogin(m.t, client, username, password)
 }
 
+func (m mockLoginClient) OidcDeviceLogin(client clients.ConjurClient, cliConfig clients.CLIConfig, issuer string, out io.Writer) (clients.ConjurClient, error) {
+	return m.oidcDeviceLogin(m.t, client, issuer)
+}
+
+func (m mockLoginClient) OidcManualLogin(client clients.ConjurClient, out io.Writer) (clients.ConjurClient, error) {
+	return m.oidcManualLogin(m.t, client)
+}
+
 func (m mockLoginClient) JWTAuthenticate(client clients.ConjurClient) error {
 	return m.jwtAuthenticate(m.t, client)
 }
 
+func (m mockLoginClient) IAMLogin(client clients.ConjurClient) (clients.ConjurClient, error) {
+	return m.iamLogin(m.t, client)
+}
+
+func (m mockLoginClient) InstanceMetadataLogin(client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error) {
+	return m.instanceMetadataLogin(m.t, client, cliConfig)
+}
+
 var defaultConjurConfig = conjurapi.Config{
 	Account:      "dev",
 	ApplianceURL: "https://conjur",
@@ -50,13 +73,38 @@ var jwtConjurConfig = conjurapi.Config{
 	JWTFilePath:  "jwt-file",
 }
 
+var iamConjurConfig = conjurapi.Config{
+	Account:      "dev",
+	ApplianceURL: "https://conjur",
+	AuthnType:    "iam",
+	ServiceID:    "test-service",
+	JWTHostID:    "host/app",
+}
+
+var azureConjurConfig = conjurapi.Config{
+	Account:      "dev",
+	ApplianceURL: "https://conjur",
+	AuthnType:    "azure",
+	ServiceID:    "test-service",
+	JWTHostID:    "host/app",
+}
+
 var loginTestCases = []struct {
 	name                    string
 	args                    []string
 	conjurConfig            conjurapi.Config
+	cliConfig               clients.CLIConfig
+	stdin                   string
+	apiKeyFile              string // written to a temporary file passed with --api-key-file
 	oidcLogin               func(t *testing.T, client clients.ConjurClient, username string, password string) (clients.ConjurClient, error)
+	oidcDeviceLogin         func(t *testing.T, client clients.ConjurClient, issuer string) (clients.ConjurClient, error)
+	oidcManualLogin         func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error)
 	jwtAuthenticate         func(t *testing.T, client clients.ConjurClient) error
+	iamLogin                func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error)
+	instanceMetadataLogin   func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error)
 	loginWithPromptFallback func(t *testing.T, client clients.ConjurClient, username string, password string) (*authn.LoginPair, error)
+	saveActiveIdentityErr   error
+	savedIdentities         int // expected calls to SaveActiveIdentity, if not zero
 	assert                  func(t *testing.T, stdout string, stderr string, err error)
 }{
 	{
@@ -77,12 +125,84 @@ var loginTestCases = []struct {
 
 			return &authn.LoginPair{}, nil
 		},
+		// The previous and the new identity are cached
+		savedIdentities: 2,
 		assert: func(t *testing.T, stdout, stderr string, err error) {
 			assert.NoError(t, err)
 			assert.Empty(t, stderr)
 			assert.Contains(t, stdout, "Logged in")
 		},
 	},
+	{
+		name:         "login fails to cache the previous identity",
+		args:         []string{"login", "-i", "alice", "-p", "secret"},
+		conjurConfig: defaultConjurConfig,
+		loginWithPromptFallback: func(t *testing.T, client clients.ConjurClient, username string, password string) (*authn.LoginPair, error) {
+			t.Error("login should not be attempted")
+			return nil, nil
+		},
+		saveActiveIdentityErr: fmt.Errorf("keyring is locked"),
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Error(t, err)
+			assert.Contains(t, stderr, "Error: Unable to cache the credentials of the logged in identity: keyring is locked\n")
+		},
+	},
+	{
+		name:         "login with password from stdin",
+		args:         []string{"login", "-i", "alice", "--password-stdin"},
+		conjurConfig: defaultConjurConfig,
+		stdin:        "secret\n",
+		loginWithPromptFallback: func(t *testing.T, client clients.ConjurClient, username string, password string) (*authn.LoginPair, error) {
+			assert.Equal(t, "alice", username)
+			assert.Equal(t, "secret", password)
+			return &authn.LoginPair{}, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "Logged in")
+		},
+	},
+	{
+		name:         "login with empty stdin",
+		args:         []string{"login", "-i", "alice", "--password-stdin"},
+		conjurConfig: defaultConjurConfig,
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: No password or API key was provided on stdin\n")
+		},
+	},
+	{
+		name:         "login with api key file",
+		args:         []string{"login", "-i", "host/ci/runner"},
+		conjurConfig: defaultConjurConfig,
+		apiKeyFile:   "api-key\n",
+		loginWithPromptFallback: func(t *testing.T, client clients.ConjurClient, username string, password string) (*authn.LoginPair, error) {
+			assert.Equal(t, "host/ci/runner", username)
+			assert.Equal(t, "api-key", password)
+			return &authn.LoginPair{}, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "Logged in")
+		},
+	},
+	{
+		name:         "login with empty api key file",
+		args:         []string{"login", "-i", "host/ci/runner"},
+		conjurConfig: defaultConjurConfig,
+		apiKeyFile:   "\n",
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stderr, "is empty\n")
+		},
+	},
+	{
+		name:         "login with several password sources",
+		args:         []string{"login", "-i", "alice", "-p", "secret", "--password-stdin"},
+		conjurConfig: defaultConjurConfig,
+		stdin:        "secret\n",
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: Only one of --password, --password-stdin and --api-key-file can be specified\n")
+		},
+	},
 	{
 		name:         "login returns error",
 		args:         []string{"login", "-i", "alice", "-p", "secret"},
@@ -127,6 +247,60 @@ var loginTestCases = []struct {
 			assert.Contains(t, stdout, "Logged in")
 		},
 	},
+	{
+		name:         "login with oidc device code",
+		args:         []string{"login", "--device-code", "--oidc-issuer", "https://idp.example.com"},
+		conjurConfig: oidcConjurConfig,
+		oidcLogin: func(t *testing.T, client clients.ConjurClient, username string, password string) (clients.ConjurClient, error) {
+			t.Error("browser based OIDC login should not be used")
+			return nil, nil
+		},
+		oidcDeviceLogin: func(t *testing.T, client clients.ConjurClient, issuer string) (clients.ConjurClient, error) {
+			assert.Equal(t, "https://idp.example.com", issuer)
+			return client, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Empty(t, stderr)
+			assert.Contains(t, stdout, "Logged in")
+		},
+	},
+	{
+		name:         "login with device code requires oidc",
+		args:         []string{"login", "--device-code"},
+		conjurConfig: defaultConjurConfig,
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: --device-code and --no-browser are only supported with the OIDC authenticator\n")
+		},
+	},
+	{
+		name:         "login with oidc without a browser",
+		args:         []string{"login", "--no-browser"},
+		conjurConfig: oidcConjurConfig,
+		oidcManualLogin: func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error) {
+			return client, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "Logged in")
+		},
+	},
+	{
+		name:         "login with device code and no browser",
+		args:         []string{"login", "--device-code", "--no-browser"},
+		conjurConfig: oidcConjurConfig,
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: --device-code and --no-browser cannot be used together\n")
+		},
+	},
+	{
+		name:         "login with oidc issuer requires device code",
+		args:         []string{"login", "--oidc-issuer", "https://idp.example.com"},
+		conjurConfig: oidcConjurConfig,
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: --oidc-issuer can only be used with --device-code\n")
+		},
+	},
 	{
 		name:         "login with jwt",
 		args:         []string{"login"},
@@ -141,6 +315,29 @@ var loginTestCases = []struct {
 			assert.Contains(t, stdout, "Logged in")
 		},
 	},
+	{
+		name:         "login with jwt source",
+		args:         []string{"login"},
+		conjurConfig: jwtConjurConfig,
+		cliConfig:    clients.CLIConfig{JWTSource: "exec:echo header.payload.signature"},
+		jwtAuthenticate: func(t *testing.T, client clients.ConjurClient) error {
+			assert.Equal(t, "header.payload.signature", client.GetConfig().JWTContent)
+			return nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "Logged in")
+		},
+	},
+	{
+		name:         "login with invalid jwt source",
+		args:         []string{"login"},
+		conjurConfig: jwtConjurConfig,
+		cliConfig:    clients.CLIConfig{JWTSource: "env:"},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Contains(t, stderr, "Error: JWT source 'env' requires a variable name, e.g. env:CI_JOB_JWT\n")
+		},
+	},
 	{
 		name:         "login with jwt fails",
 		args:         []string{"login"},
@@ -154,6 +351,58 @@ var loginTestCases = []struct {
 			assert.Contains(t, stderr, "Unable to authenticate with Secrets Manager using the provided JWT file: jwt authentication failed")
 		},
 	},
+	{
+		name:         "login with iam",
+		args:         []string{"login"},
+		conjurConfig: iamConjurConfig,
+		iamLogin: func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error) {
+			assert.Equal(t, "test-service", client.GetConfig().ServiceID)
+			return client, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "Logged in")
+		},
+	},
+	{
+		name:         "login with iam fails",
+		args:         []string{"login"},
+		conjurConfig: iamConjurConfig,
+		iamLogin: func(t *testing.T, client clients.ConjurClient) (clients.ConjurClient, error) {
+			return nil, fmt.Errorf("Unable to obtain AWS credentials")
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Error(t, err)
+			assert.Contains(t, stderr, "Error: Unable to authenticate with Secrets Manager using AWS IAM: Unable to obtain AWS credentials\n")
+		},
+	},
+	{
+		name:         "login with azure",
+		args:         []string{"login"},
+		conjurConfig: azureConjurConfig,
+		cliConfig:    clients.CLIConfig{MetadataURL: "http://127.0.0.1:8080"},
+		instanceMetadataLogin: func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error) {
+			assert.Equal(t, "azure", client.GetConfig().AuthnType)
+			assert.Equal(t, "http://127.0.0.1:8080", cliConfig.MetadataURL)
+			return client, nil
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.NoError(t, err)
+			assert.Contains(t, stdout, "Logged in")
+		},
+	},
+	{
+		name:         "login with azure fails",
+		args:         []string{"login"},
+		conjurConfig: azureConjurConfig,
+		instanceMetadataLogin: func(t *testing.T, client clients.ConjurClient, cliConfig clients.CLIConfig) (clients.ConjurClient, error) {
+			return nil, fmt.Errorf("unable to reach the instance metadata service")
+		},
+		assert: func(t *testing.T, stdout, stderr string, err error) {
+			assert.Error(t, err)
+			assert.Contains(t, stderr, "Error: Unable to authenticate with Secrets Manager using the instance metadata service: unable to reach the instance metadata service\n")
+		},
+	},
 }
 
 func TestLoginCmd(t *testing.T) {
@@ -161,26 +410,52 @@ func TestLoginCmd(t *testing.T) {
 
 	for _, tc := range loginTestCases {
 		t.Run(tc.name, func(t *testing.T) {
+			savedIdentities := 0
 			mockClient := mockLoginClient{
 				t:                       t,
 				loginWithPromptFallback: tc.loginWithPromptFallback,
 				oidcLogin:               tc.oidcLogin,
+				oidcDeviceLogin:         tc.oidcDeviceLogin,
+				oidcManualLogin:         tc.oidcManualLogin,
 				jwtAuthenticate:         tc.jwtAuthenticate,
+				iamLogin:                tc.iamLogin,
+				instanceMetadataLogin:   tc.instanceMetadataLogin,
 			}
 
 			cmd := newLoginCmd(
 				loginCmdFuncs{
 					LoginWithPromptFallback: mockClient.LoginWithPromptFallback,
 					OidcLogin:               mockClient.OidcLogin,
+					OidcDeviceLogin:         mockClient.OidcDeviceLogin,
+					OidcManualLogin:         mockClient.OidcManualLogin,
 					JWTAuthenticate:         mockClient.JWTAuthenticate,
+					IAMLogin:                mockClient.IAMLogin,
+					InstanceMetadataLogin:   mockClient.InstanceMetadataLogin,
 					LoadAndValidateConjurConfig: func(time.Duration) (conjurapi.Config, error) {
 						return tc.conjurConfig, nil
 					},
+					LoadCLIConfig: func() (clients.CLIConfig, error) {
+						return tc.cliConfig, nil
+					},
+					SaveActiveIdentity: func(conjurapi.Config) error {
+						savedIdentities++
+						return tc.saveActiveIdentityErr
+					},
 				},
 			)
 
-			stdout, stderr, err := executeCommandForTest(t, cmd, tc.args...)
+			args := tc.args
+			if tc.apiKeyFile != "" {
+				apiKeyFile := filepath.Join(t.TempDir(), "api-key")
+				assert.NoError(t, os.WriteFile(apiKeyFile, []byte(tc.apiKeyFile), 0600))
+				args = append(args, "--api-key-file", apiKeyFile)
+			}
+
+			stdout, stderr, err := executeCommandForTestWithStdin(t, cmd, tc.stdin, args...)
 			tc.assert(t, stdout, stderr, err)
+			if tc.savedIdentities > 0 {
+				assert.Equal(t, tc.savedIdentities, savedIdentities)
+			}
 		})
 	}
 }
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
 
 	"github.com/spf13/cobra"
 )
@@ -10,9 +11,11 @@ type configLoaderFn func() (conjurapi.Config, error)
 
 func newLogoutCmd(loadConfig configLoaderFn) *cobra.Command {
 	cmd := &cobra.Command{
-		Use:          "logout",
-		Short:        "Log out the user and delete cached credentials.",
-		Long:         `Log out the user and delete the credentials cached in the operating system user's credential storage or .netrc file.`,
+		Use:   "logout",
+		Short: "Log out the user and delete cached credentials.",
+		Long: `Log out the user and delete the credentials and access token cached in the operating system user's credential storage or .netrc file.
+
+Use the global --as flag to delete the cached credentials of another identity instead, see 'conjur identity list'.`,
 		SilenceUsage: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			config, err := loadConfig()
@@ -20,7 +23,7 @@ func newLogoutCmd(loadConfig configLoaderFn) *cobra.Command {
 				return err
 			}
 
-			err = conjurapi.PurgeCredentials(config)
+			err = clients.PurgeIdentity(config, clients.GetIdentityFlag(cmd))
 			if err != nil {
 				return err
 			}
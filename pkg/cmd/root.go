@@ -30,7 +30,12 @@ func newRootCommand() *cobra.Command {
 	}
 
 	rootCmd.PersistentFlags().BoolP("debug", "d", false, "Debug logging enabled")
+	rootCmd.PersistentFlags().Bool("debug-unsafe", false, "Debug logging without redacting credentials and secret values, for local troubleshooting only")
 	rootCmd.PersistentFlags().Duration("timeout", time.Minute, "HTTP timeout duration, between 1s and 10m")
+	rootCmd.PersistentFlags().Int("retries", clients.DefaultRetries, "Number of times to retry read requests failing with a transient error, such as a 502 or a reset connection. Other requests are only retried when the connection can't be established.")
+	rootCmd.PersistentFlags().Duration("retry-max-wait", clients.DefaultRetryMaxWait, "Maximum wait between retries, the retries still have to finish within --timeout")
+	rootCmd.PersistentFlags().String("http-log", "", "Record the HTTP requests and responses in a HAR file, with credentials redacted as in --debug")
+	rootCmd.PersistentFlags().String("as", "", "Run the command as another cached identity, see 'conjur identity list'")
 	rootCmd.SetVersionTemplate("Secrets Manager CLI version {{.Version}}\n")
 	return rootCmd
 }
@@ -40,7 +45,8 @@ func newRootCommand() *cobra.Command {
 func Execute() {
 	rootCmd.SetOut(os.Stdout)
 	rootCmd.SetErr(os.Stderr)
-	err := style.Execute(rootCmd)
+	// Commands share a single authenticated client
+	err := style.Execute(clients.WithClientProvider(context.Background()), rootCmd)
 	if err != nil {
 		// check if error is about x509 unknown certificate signing authority
 		if errors.As(err, &x509.UnknownAuthorityError{}) {
@@ -49,7 +55,7 @@ func Execute() {
 					"This means one of the following:\n\n" +
 					"    The server's certificate has been rotated\n" +
 					"    The server or your device may have been compromised\n\n" +
-					"If needed, verify the change with your administrator before reinitializing the CLI and accepting the new certificate.")
+					"If needed, verify the change with your administrator, then run 'conjur cert refresh' to review and accept the new certificate.")
 		}
 		if errors.Is(err, context.DeadlineExceeded) {
 			rootCmd.PrintErrln(
@@ -0,0 +1,299 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/version"
+
+	"github.com/spf13/cobra"
+)
+
+type serverCmdFuncs struct {
+	LoadAndValidateConjurConfig func(timeout time.Duration) (conjurapi.Config, error)
+	LoadCLIConfig               func() (clients.CLIConfig, error)
+	GetServerInfo               func(config conjurapi.Config, cliConfig clients.CLIConfig) (*conjurapi.EnterpriseInfoResponse, error)
+	GetServerHealth             func(config conjurapi.Config, cliConfig clients.CLIConfig) (*clients.ServerHealth, error)
+}
+
+var defaultServerCmdFuncs = serverCmdFuncs{
+	LoadAndValidateConjurConfig: clients.LoadAndValidateConjurConfig,
+	LoadCLIConfig:               clients.LoadCLIConfig,
+	GetServerInfo:               clients.GetServerInfo,
+	GetServerHealth:             clients.GetServerHealth,
+}
+
+func loadServerConfig(cmd *cobra.Command, funcs serverCmdFuncs) (conjurapi.Config, clients.CLIConfig, error) {
+	timeout, err := clients.GetTimeout(cmd)
+	if err != nil {
+		return conjurapi.Config{}, clients.CLIConfig{}, err
+	}
+	config, err := funcs.LoadAndValidateConjurConfig(timeout)
+	if err != nil {
+		return config, clients.CLIConfig{}, err
+	}
+	cliConfig, err := funcs.LoadCLIConfig()
+	return config, cliConfig, err
+}
+
+// serverRole returns the role of a self-hosted server as it is named in the documentation
+func serverRole(role string) string {
+	if role == "master" {
+		return "leader"
+	}
+	return role
+}
+
+func printJSON(cmd *cobra.Command, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	cmd.Println(string(data))
+	return nil
+}
+
+func newServerCmd(funcs serverCmdFuncs) *cobra.Command {
+	serverCmd := &cobra.Command{
+		Use:   "server",
+		Short: "Self-hosted server commands (info, health)",
+		Long: `Inspect a Secrets Manager Self-Hosted leader, standby or follower.
+
+The server is reached through the configured proxy and TLS trust. No credentials are needed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			// Print --help if called without subcommand
+			cmd.Help()
+		},
+	}
+
+	serverCmd.AddCommand(newServerInfoCmd(funcs))
+	serverCmd.AddCommand(newServerHealthCmd(funcs))
+
+	return serverCmd
+}
+
+func newServerInfoCmd(funcs serverCmdFuncs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "info",
+		Short: "Show the version and role of the server",
+		Long: `Show the release, role (leader, standby or follower) and services of the server, from its /info endpoint.
+
+A warning is printed when this version of the CLI is known to be incompatible with the server.
+
+Examples:
+- conjur server info
+- conjur server info --json`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOutput, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+			config, cliConfig, err := loadServerConfig(cmd, funcs)
+			if err != nil {
+				return err
+			}
+			info, err := funcs.GetServerInfo(config, cliConfig)
+			if err != nil {
+				return err
+			}
+
+			// The Conjur OSS version, which the known incompatibilities refer to
+			serverVersion := info.Services["possum"].Version
+			for _, reason := range version.ServerIncompatibilities(version.Version, serverVersion) {
+				cmd.PrintErrf("Warning: CLI version %s is incompatible with server version %s, %s\n", version.Version, serverVersion, reason)
+			}
+
+			if jsonOutput {
+				return printJSON(cmd, info)
+			}
+
+			cmd.Printf("Appliance URL: %s\n", config.ApplianceURL)
+			cmd.Printf("Release:       %s\n", info.Release)
+			cmd.Printf("Version:       %s\n", info.Version)
+			cmd.Printf("Role:          %s\n", serverRole(info.Role))
+			if info.Container != "" {
+				cmd.Printf("Container:     %s\n", info.Container)
+			}
+			if info.FipsMode != "" {
+				cmd.Printf("FIPS mode:     %s\n", info.FipsMode)
+			}
+			if len(info.Services) == 0 {
+				return nil
+			}
+
+			names := make([]string, 0, len(info.Services))
+			nameWidth, versionWidth := len("SERVICE"), len("VERSION")
+			for name, service := range info.Services {
+				names = append(names, name)
+				nameWidth = max(nameWidth, len(name))
+				versionWidth = max(versionWidth, len(service.Version))
+			}
+			sort.Strings(names)
+			cmd.Printf("\n%-*s  %-*s  %s\n", nameWidth, "SERVICE", versionWidth, "VERSION", "STATUS")
+			for _, name := range names {
+				service := info.Services[name]
+				status := service.Status
+				if service.Err != "" {
+					status = fmt.Sprintf("%s (%s)", status, service.Err)
+				}
+				cmd.Printf("%-*s  %-*s  %s\n", nameWidth, name, versionWidth, service.Version, status)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "Output the server info as JSON")
+
+	return cmd
+}
+
+func newServerHealthCmd(funcs serverCmdFuncs) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Check the health of the server",
+		Long: `Check the health of the server's services and database, and the database replication status, from its /health endpoint.
+
+The command fails when the server is unhealthy or can't be reached, or when --max-lag is set and replication lags behind by more bytes. This makes it usable as a monitoring probe.
+
+Examples:
+- conjur server health
+- conjur server health --max-lag 1048576
+- conjur server health --json`,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			jsonOutput, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+			maxLag, err := cmd.Flags().GetInt64("max-lag")
+			if err != nil {
+				return err
+			}
+			config, cliConfig, err := loadServerConfig(cmd, funcs)
+			if err != nil {
+				return err
+			}
+			health, err := funcs.GetServerHealth(config, cliConfig)
+			if err != nil {
+				return err
+			}
+
+			if jsonOutput {
+				err = printJSON(cmd, health)
+			} else {
+				printServerHealth(cmd, health)
+			}
+			if err != nil {
+				return err
+			}
+
+			if !health.OK {
+				return fmt.Errorf("The server is unhealthy")
+			}
+			if lag := replicationLag(health); maxLag > 0 && lag > maxLag {
+				return fmt.Errorf("Replication lags behind by %d bytes, more than %d", lag, maxLag)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "Output the server health as JSON")
+	cmd.Flags().Int64("max-lag", 0, "Fail when replication lags behind by more bytes (0 means no limit)")
+
+	return cmd
+}
+
+// replicationLag returns the largest replication lag in bytes, of the server or of its replicas
+func replicationLag(health *clients.ServerHealth) int64 {
+	status := health.Database.ReplicationStatus
+	if status == nil {
+		return 0
+	}
+	var lag int64
+	if status.LagBytes != nil {
+		lag = *status.LagBytes
+	}
+	for _, replica := range status.Replicas {
+		lag = max(lag, replica.LagBytes)
+	}
+	return lag
+}
+
+// healthStatus returns how a status reported by the server is printed
+func healthStatus(ok bool) string {
+	if ok {
+		return "ok"
+	}
+	return "failing"
+}
+
+func printServerHealth(cmd *cobra.Command, health *clients.ServerHealth) {
+	if health.OK {
+		cmd.Println("Status:      healthy")
+	} else {
+		cmd.Println("Status:      unhealthy")
+	}
+	if health.Role != "" {
+		cmd.Printf("Role:        %s\n", serverRole(health.Role))
+	}
+
+	// The services are listed with their status, next to the overall "ok" flag
+	services := []string{}
+	for name, status := range health.Services {
+		if name != "ok" {
+			services = append(services, fmt.Sprintf("%s %v", name, status))
+		}
+	}
+	sort.Strings(services)
+	if len(services) > 0 {
+		cmd.Printf("Services:    %s\n", strings.Join(services, ", "))
+	}
+
+	database := health.Database
+	cmd.Printf("Database:    %s\n", healthStatus(database.OK))
+	for _, name := range sortedKeys(database.Connect) {
+		cmd.Printf("  Connection %s: %s\n", name, database.Connect[name])
+	}
+	for _, name := range sortedKeys(database.FreeSpace) {
+		space := database.FreeSpace[name]
+		cmd.Printf("  Free space %s: %d KB, %d inodes\n", name, space.KBytes, space.Inodes)
+	}
+
+	status := database.ReplicationStatus
+	if status == nil {
+		return
+	}
+	if status.LagBytes != nil {
+		cmd.Printf("Replication: %d bytes behind the leader", *status.LagBytes)
+		if status.LastReplayTimestamp != "" {
+			cmd.Printf(", last replayed at %s", status.LastReplayTimestamp)
+		}
+		cmd.Println()
+	}
+	if len(status.Replicas) > 0 {
+		cmd.Printf("Replication: %d replicas\n", len(status.Replicas))
+		for _, replica := range status.Replicas {
+			cmd.Printf("  %s (%s): %s, %s, %d bytes behind\n", replica.ApplicationName, replica.ClientAddr, replica.State, replica.SyncState, replica.LagBytes)
+		}
+	}
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func init() {
+	serverCmd := newServerCmd(defaultServerCmdFuncs)
+	rootCmd.AddCommand(serverCmd)
+}
@@ -0,0 +1,230 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-cli-go/pkg/clients"
+	"github.com/cyberark/conjur-cli-go/pkg/version"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerCmd(t *testing.T) {
+	lag := int64(2048)
+	info := &conjurapi.EnterpriseInfoResponse{
+		Release:   "13.5.0",
+		Version:   "13.5.0-12",
+		Role:      "master",
+		Container: "conjur-leader",
+		FipsMode:  "enabled",
+		Services: map[string]conjurapi.EnterpriseInfoService{
+			"possum": {Status: "running", Version: "1.21.0-12"},
+			"ui":     {Status: "down", Version: "13.5.0", Err: "exited"},
+		},
+	}
+	leaderHealth := &clients.ServerHealth{
+		OK:       true,
+		Services: map[string]interface{}{"possum": "ok", "ui": "ok", "ok": true},
+		Database: clients.DatabaseHealth{
+			OK:        true,
+			Connect:   map[string]string{"main": "ok"},
+			FreeSpace: map[string]clients.DiskSpace{"main": {KBytes: 40461280, Inodes: 5098514}},
+			ReplicationStatus: &clients.ReplicationStatus{
+				Replicas: []clients.ReplicaStatus{
+					{ApplicationName: "standby1", ClientAddr: "10.0.0.3", State: "streaming", SyncState: "sync", LagBytes: 0},
+					{ApplicationName: "standby2", ClientAddr: "10.0.0.4", State: "streaming", SyncState: "async", LagBytes: 4096},
+				},
+			},
+		},
+	}
+	followerHealth := &clients.ServerHealth{
+		Role:     "follower",
+		Services: map[string]interface{}{"possum": "ok", "ok": true},
+		Database: clients.DatabaseHealth{
+			ReplicationStatus: &clients.ReplicationStatus{LagBytes: &lag, LastReplayTimestamp: "2026-10-18 10:00:00+00"},
+		},
+	}
+
+	testCases := []struct {
+		name       string
+		args       []string
+		cliVersion string
+		info       *conjurapi.EnterpriseInfoResponse
+		health     *clients.ServerHealth
+		serverErr  error
+		assert     func(t *testing.T, stdout, stderr string, err error)
+	}{
+		{
+			name: "server command help",
+			args: []string{"server", "--help"},
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Contains(t, stdout, "HELP LONG")
+			},
+		},
+		{
+			name:       "server info",
+			args:       []string{"server", "info"},
+			cliVersion: "9.2.0",
+			info:       info,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, ""+
+					"Appliance URL: https://conjur.example.com\n"+
+					"Release:       13.5.0\n"+
+					"Version:       13.5.0-12\n"+
+					"Role:          leader\n"+
+					"Container:     conjur-leader\n"+
+					"FIPS mode:     enabled\n"+
+					"\n"+
+					"SERVICE  VERSION    STATUS\n"+
+					"possum   1.21.0-12  running\n"+
+					"ui       13.5.0     down (exited)\n",
+					stdout)
+				assert.Equal(t, "Warning: CLI version 9.2.0 is incompatible with server version 1.21.0-12, policy dry runs and 'policy fetch' require server version 1.21.1 or later\n", stderr)
+			},
+		},
+		{
+			name:       "server info of a compatible server",
+			args:       []string{"server", "info"},
+			cliVersion: "9.2.0",
+			info: &conjurapi.EnterpriseInfoResponse{
+				Role:     "standby",
+				Services: map[string]conjurapi.EnterpriseInfoService{"possum": {Version: "1.21.1-359"}},
+			},
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Contains(t, stdout, "Role:          standby\n")
+				assert.Empty(t, stderr)
+			},
+		},
+		{
+			name:       "server info of a development build",
+			args:       []string{"server", "info"},
+			cliVersion: "unset",
+			info:       info,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Empty(t, stderr)
+			},
+		},
+		{
+			name: "server info --json",
+			args: []string{"server", "info", "--json"},
+			info: info,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				var printed conjurapi.EnterpriseInfoResponse
+				assert.NoError(t, json.Unmarshal([]byte(stdout), &printed))
+				assert.Equal(t, *info, printed)
+			},
+		},
+		{
+			name:      "server info of a server without /info",
+			args:      []string{"server", "info"},
+			serverErr: errors.New("Unable to retrieve the server info: 404 Not Found"),
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, "Error: Unable to retrieve the server info: 404 Not Found\n", stderr)
+			},
+		},
+		{
+			name:   "server health of a leader",
+			args:   []string{"server", "health"},
+			health: leaderHealth,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+				assert.Equal(t, ""+
+					"Status:      healthy\n"+
+					"Services:    possum ok, ui ok\n"+
+					"Database:    ok\n"+
+					"  Connection main: ok\n"+
+					"  Free space main: 40461280 KB, 5098514 inodes\n"+
+					"Replication: 2 replicas\n"+
+					"  standby1 (10.0.0.3): streaming, sync, 0 bytes behind\n"+
+					"  standby2 (10.0.0.4): streaming, async, 4096 bytes behind\n",
+					stdout)
+			},
+		},
+		{
+			name:   "server health of an unhealthy follower",
+			args:   []string{"server", "health"},
+			health: followerHealth,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, stdout, "Status:      unhealthy\n")
+				assert.Contains(t, stdout, "Role:        follower\n")
+				assert.Contains(t, stdout, "Database:    failing\n")
+				assert.Contains(t, stdout, "Replication: 2048 bytes behind the leader, last replayed at 2026-10-18 10:00:00+00\n")
+				assert.Equal(t, "Error: The server is unhealthy\n", stderr)
+			},
+		},
+		{
+			name:   "server health with replication lagging behind",
+			args:   []string{"server", "health", "--max-lag", "1024"},
+			health: leaderHealth,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Equal(t, "Error: Replication lags behind by 4096 bytes, more than 1024\n", stderr)
+			},
+		},
+		{
+			name:   "server health within the replication lag",
+			args:   []string{"server", "health", "--max-lag", "8192"},
+			health: leaderHealth,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.NoError(t, err)
+			},
+		},
+		{
+			name:   "server health --json",
+			args:   []string{"server", "health", "--json"},
+			health: followerHealth,
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				var printed clients.ServerHealth
+				assert.NoError(t, json.Unmarshal([]byte(stdout), &printed))
+				assert.Equal(t, *followerHealth, printed)
+				assert.Equal(t, "Error: The server is unhealthy\n", stderr)
+			},
+		},
+		{
+			name:      "server health of an unreachable server",
+			args:      []string{"server", "health"},
+			serverErr: errors.New("Unable to connect to https://conjur.example.com: connection refused"),
+			assert: func(t *testing.T, stdout, stderr string, err error) {
+				assert.Error(t, err)
+				assert.Empty(t, stdout)
+				assert.Equal(t, "Error: Unable to connect to https://conjur.example.com: connection refused\n", stderr)
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cliVersion := version.Version
+			version.Version = tc.cliVersion
+			defer func() { version.Version = cliVersion }()
+
+			cmd := newServerCmd(serverCmdFuncs{
+				LoadAndValidateConjurConfig: func(time.Duration) (conjurapi.Config, error) {
+					return conjurapi.Config{Account: "myorg", ApplianceURL: "https://conjur.example.com"}, nil
+				},
+				LoadCLIConfig: func() (clients.CLIConfig, error) {
+					return clients.CLIConfig{}, nil
+				},
+				GetServerInfo: func(config conjurapi.Config, cliConfig clients.CLIConfig) (*conjurapi.EnterpriseInfoResponse, error) {
+					return tc.info, tc.serverErr
+				},
+				GetServerHealth: func(config conjurapi.Config, cliConfig clients.CLIConfig) (*clients.ServerHealth, error) {
+					return tc.health, tc.serverErr
+				},
+			})
+
+			stdout, stderr, err := executeCommandForTest(t, cmd, tc.args...)
+			tc.assert(t, stdout, stderr, err)
+		})
+	}
+}
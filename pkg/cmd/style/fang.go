@@ -22,9 +22,9 @@ func huhColorScheme(lightDark lipgloss.LightDarkFunc) fang.ColorScheme {
 	}
 }
 
-func Execute(cmd *cobra.Command) error {
+func Execute(ctx context.Context, cmd *cobra.Command) error {
 	return fang.Execute(
-		context.Background(),
+		ctx,
 		cmd,
 		fang.WithoutVersion(),
 		fang.WithColorSchemeFunc(huhColorScheme),
@@ -26,6 +26,11 @@ type promptResponse struct {
 // executeCommandForTest executes a cobra command in-memory and returns stdout, stderr and error
 func executeCommandForTest(t *testing.T, c *cobra.Command, args ...string) (string, string, error) {
 	t.Helper()
+	return executeCommandForTestWithStdin(t, c, "", args...)
+}
+
+func executeCommandForTestWithStdin(t *testing.T, c *cobra.Command, stdin string, args ...string) (string, string, error) {
+	t.Helper()
 
 	cmd := newRootCommand()
 	cmd.AddCommand(c)
@@ -39,7 +44,7 @@ func executeCommandForTest(t *testing.T, c *cobra.Command, args ...string) (stri
 	cmd.SetOut(stdoutBuf)
 	cmd.SetErr(stderrBuf)
 
-	cmd.SetIn(bytes.NewReader(nil))
+	cmd.SetIn(strings.NewReader(stdin))
 	err := cmd.Execute()
 
 	return stdoutBuf.String(), stderrBuf.String(), err
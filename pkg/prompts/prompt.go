@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -75,6 +77,50 @@ func AskForPrompts(ctx context.Context, title string, messages []string, timeout
 	return answers, err
 }
 
+// Setting represents a named value to be collected from the user as part of a larger form
+type Setting struct {
+	Title    string
+	Value    *string
+	Required bool
+	Secret   bool
+}
+
+// AskForSettings presents a single form to retrieve a group of settings from the user.
+// Values already present on the settings are used as defaults.
+func AskForSettings(title string, settings []Setting) error {
+	fields := make([]huh.Field, 0, len(settings)+1)
+	fields = append(fields, huh.NewNote().Title(title))
+	for _, setting := range settings {
+		field := huh.NewInput().
+			Title(setting.Title).
+			Value(setting.Value)
+		if setting.Required {
+			field = field.Validate(validateNotEmpty())
+		}
+		if setting.Secret {
+			field = field.EchoMode(huh.EchoModePassword)
+		}
+		fields = append(fields, field)
+	}
+
+	err := huh.NewForm(huh.NewGroup(fields...)).
+		WithTheme(style.GetTheme()).
+		WithAccessible(useAccessibleForm()).
+		Run()
+	for _, setting := range settings {
+		*setting.Value = strings.TrimSpace(*setting.Value)
+	}
+	return err
+}
+
+// AskForOidcRedirect writes the OIDC authorization URL to out and presents a prompt to paste back the URL the
+// browser was redirected to after logging in
+func AskForOidcRedirect(out io.Writer, authURL string) (string, error) {
+	// The URL is printed rather than shown in the form so that it isn't wrapped, which would break copying it
+	fmt.Fprintf(out, "Open the following URL in a browser to login:\n\n%s\n\n", authURL)
+	return input("After logging in, paste the full URL you were redirected to:")
+}
+
 // AskForMFAMechanism presents a prompt to select MFA mechanism to use
 func AskForMFAMechanism(options []Option) (string, error) {
 	o := make([]huh.Option[string], len(options))
@@ -88,6 +134,11 @@ func AskForMFAMechanism(options []Option) (string, error) {
 	)
 }
 
+// IsInteractive returns whether prompts can be presented to the user, which requires stdin to be a terminal
+func IsInteractive() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
 // MaybeAskForCredentials optionally presents a prompt to retrieve missing username and/or password from the user
 func MaybeAskForCredentials(username, password string) (string, string, error) {
 	var err error
@@ -118,6 +169,17 @@ func MaybeAskForPassword(password string) (string, error) {
 	return passwordInput("Please enter your password (it will not be echoed):")
 }
 
+// AskForPassphrase presents a prompt to retrieve the passphrase of the encrypted credentials file
+func AskForPassphrase() (string, error) {
+	return passwordInput("Please enter the passphrase of the encrypted credentials file (it will not be echoed):")
+}
+
+// AskForClientKeyPassphrase presents a prompt to retrieve the passphrase of an encrypted client
+// certificate key or PKCS#12 bundle
+func AskForClientKeyPassphrase(path string) (string, error) {
+	return passwordInput(fmt.Sprintf("Please enter the passphrase of %s (it will not be echoed):", path))
+}
+
 // MaybeAskForChangePassword optionally presents a prompt to retrieve missing new password from the user
 func MaybeAskForChangePassword(newPassword string) (string, error) {
 	if len(newPassword) > 0 {
@@ -194,6 +256,14 @@ func AskToTrustCert(cert utils.ServerCert) error {
 		fmt.Sprintf("Certificate creation date: %s\n", cert.CreationDate) +
 		fmt.Sprintf("Certificate expiration date: %s\n", cert.ExpirationDate)
 
+	if len(cert.Chain) > 1 {
+		warning += "\nCertificate chain presented by the server:\n"
+		for i, chainCert := range cert.Chain {
+			warning += fmt.Sprintf("[%d] %s\n    issued by %s, expires %s\n    Sha256 fingerprint %s\n",
+				i, chainCert.Subject, chainCert.Issuer, chainCert.NotAfter.Format(time.RFC1123), utils.CertificateFingerprint(chainCert))
+		}
+	}
+
 	ok, err := confirm("Do you want to trust this certificate?", warning)
 	if !ok {
 		return errors.New("Based on your selection, this certificate will not be trusted")
@@ -201,6 +271,32 @@ func AskToTrustCert(cert utils.ServerCert) error {
 	return err
 }
 
+// AskForTrustAnchor presents a prompt to select the certificate of the server's chain to trust: the
+// server's certificate itself, at 0, or one of the CAs of cert.CACerts()
+func AskForTrustAnchor(cert utils.ServerCert) (int, error) {
+	cas := cert.CACerts()
+	options := []huh.Option[string]{
+		huh.NewOption(fmt.Sprintf("The server's certificate only (%s)", cert.Chain[0].Subject), "0"),
+	}
+	for i, ca := range cas {
+		kind := "Intermediate CA"
+		if i == len(cas)-1 && utils.IsSelfSigned(ca) {
+			kind = "Root CA"
+		}
+		options = append(options, huh.NewOption(fmt.Sprintf("%s %s", kind, ca.Subject), strconv.Itoa(i+1)))
+	}
+
+	selected, err := option(
+		options,
+		"Which certificate do you want to trust?",
+		"Trusting a CA keeps the server trusted when its certificate is renewed by the same CA.",
+	)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(selected)
+}
+
 // MaybeAskForEnvironment presents a prompt to retrieve missing environment from the user
 func MaybeAskForEnvironment(env string) (string, error) {
 	if len(env) > 0 {
@@ -219,7 +315,7 @@ func MaybeAskForEnvironment(env string) (string, error) {
 }
 
 func passwordInput(title string) (string, error) {
-	if !term.IsTerminal(int(os.Stdin.Fd())) {
+	if !IsInteractive() {
 		return "", fmt.Errorf("user password cannot be requested in non-interactive mode")
 	}
 	var password string
@@ -0,0 +1,230 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// ErrIncorrectPassphrase is returned when a private key or a PKCS#12 bundle can't be decrypted
+// with the given passphrase
+var ErrIncorrectPassphrase = errors.New("incorrect passphrase")
+
+// LoadClientCertificate loads a client certificate and its private key from PEM files. The
+// passphrase function is only called when the key is encrypted, either in the PKCS#8 format of
+// "openssl pkcs8 -topk8" or in the legacy format of "openssl genrsa -aes256".
+func LoadClientCertificate(certFile, keyFile string, passphrase func() (string, error)) (tls.Certificate, error) {
+	certPEM, err := os.ReadFile(certFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	keyPEM, err := os.ReadFile(keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return tls.Certificate{}, fmt.Errorf("no PEM private key found in %s", keyFile)
+	}
+	if block.Type == "ENCRYPTED PRIVATE KEY" || x509.IsEncryptedPEMBlock(block) {
+		secret, err := passphrase()
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		var der []byte
+		if block.Type == "ENCRYPTED PRIVATE KEY" {
+			der, err = decryptPKCS8(block.Bytes, []byte(secret))
+			block = &pem.Block{Type: "PRIVATE KEY", Bytes: der}
+		} else {
+			// The legacy format is insecure, but still common
+			der, err = x509.DecryptPEMBlock(block, []byte(secret))
+			block = &pem.Block{Type: block.Type, Bytes: der}
+			if errors.Is(err, x509.IncorrectPasswordError) {
+				err = ErrIncorrectPassphrase
+			}
+		}
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		keyPEM = pem.EncodeToMemory(block)
+	}
+
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+// LoadPKCS12ClientCertificate loads a client certificate, its private key and its chain from a
+// PKCS#12 bundle. The passphrase function is only called when the bundle has a password.
+func LoadPKCS12ClientCertificate(bundleFile string, passphrase func() (string, error)) (tls.Certificate, error) {
+	data, err := os.ReadFile(bundleFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, "")
+	if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+		var secret string
+		if secret, err = passphrase(); err != nil {
+			return tls.Certificate{}, err
+		}
+		key, cert, caCerts, err = pkcs12.DecodeChain(data, secret)
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			err = ErrIncorrectPassphrase
+		}
+	}
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	tlsCert := tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key, Leaf: cert}
+	for _, caCert := range caCerts {
+		tlsCert.Certificate = append(tlsCert.Certificate, caCert.Raw)
+	}
+	return tlsCert, nil
+}
+
+// UseClientCertificate makes the transport present a client certificate to the servers requesting
+// one, for mutual TLS
+func UseClientCertificate(transport *http.Transport, cert tls.Certificate) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+	tlsConfig.Certificates = []tls.Certificate{cert}
+	tlsConfig.GetClientCertificate = nil
+	transport.TLSClientConfig = tlsConfig
+}
+
+// The ASN.1 structures of encrypted PKCS#8 private keys with PBES2, see RFC 8018
+var (
+	oidPBES2      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidAES128CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC  = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// decryptPKCS8 decrypts a PKCS#8 private key encrypted with PBES2, which is what OpenSSL uses by
+// default, and returns the unencrypted PKCS#8 private key
+func decryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, err
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported private key encryption %s, only PBES2 is supported", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, err
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function %s", params.KeyDerivationFunc.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, err
+	}
+
+	var prf func() hash.Hash
+	switch algorithm := kdfParams.PRF.Algorithm; {
+	case len(algorithm) == 0, algorithm.Equal(oidHMACSHA1):
+		prf = sha1.New
+	case algorithm.Equal(oidHMACSHA256):
+		prf = sha256.New
+	case algorithm.Equal(oidHMACSHA384):
+		prf = sha512.New384
+	case algorithm.Equal(oidHMACSHA512):
+		prf = sha512.New
+	default:
+		return nil, fmt.Errorf("unsupported key derivation function %s", algorithm)
+	}
+
+	var keyLength int
+	var newCipher func(key []byte) (cipher.Block, error)
+	switch algorithm := params.EncryptionScheme.Algorithm; {
+	case algorithm.Equal(oidAES128CBC):
+		keyLength, newCipher = 16, aes.NewCipher
+	case algorithm.Equal(oidAES192CBC):
+		keyLength, newCipher = 24, aes.NewCipher
+	case algorithm.Equal(oidAES256CBC):
+		keyLength, newCipher = 32, aes.NewCipher
+	case algorithm.Equal(oidDESEDE3CBC):
+		keyLength, newCipher = 24, des.NewTripleDESCipher
+	default:
+		return nil, fmt.Errorf("unsupported private key cipher %s", algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, err
+	}
+
+	key, err := pbkdf2.Key(prf, string(passphrase), kdfParams.Salt, kdfParams.IterationCount, keyLength)
+	if err != nil {
+		return nil, err
+	}
+	block, err := newCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != block.BlockSize() || len(info.EncryptedData)%block.BlockSize() != 0 || len(info.EncryptedData) == 0 {
+		return nil, errors.New("malformed encrypted private key")
+	}
+
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	// A wrong passphrase is detected by the padding, or by the key that doesn't parse
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, ErrIncorrectPassphrase
+	}
+	for _, b := range plaintext[len(plaintext)-padding:] {
+		if int(b) != padding {
+			return nil, ErrIncorrectPassphrase
+		}
+	}
+	plaintext = plaintext[:len(plaintext)-padding]
+	if _, err := x509.ParsePKCS8PrivateKey(plaintext); err != nil {
+		return nil, ErrIncorrectPassphrase
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,176 @@
+package utils
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func newTestClientCert(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return cert, key
+}
+
+// encryptPKCS8 encrypts a PKCS#8 private key like "openssl pkcs8 -topk8 -v2 aes-256-cbc -v2prf hmacWithSHA256"
+func encryptPKCS8(t *testing.T, der []byte, passphrase string) []byte {
+	salt := make([]byte, 8)
+	iv := make([]byte, aes.BlockSize)
+	rand.Read(salt)
+	rand.Read(iv)
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, 2048, 32)
+	assert.NoError(t, err)
+
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	plaintext := append(der, []byte(strings.Repeat(string(rune(padding)), padding))...)
+	block, _ := aes.NewCipher(key)
+	encrypted := make([]byte, len(plaintext))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, plaintext)
+
+	kdfParams, _ := asn1.Marshal(pbkdf2Params{
+		Salt: salt, IterationCount: 2048, PRF: pkix.AlgorithmIdentifier{Algorithm: oidHMACSHA256, Parameters: asn1.NullRawValue},
+	})
+	ivParams, _ := asn1.Marshal(iv)
+	params, _ := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParams}},
+	})
+	data, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: encrypted,
+	})
+	assert.NoError(t, err)
+	return data
+}
+
+func TestLoadClientCertificate(t *testing.T) {
+	cert, key := newTestClientCert(t)
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "client.crt")
+	assert.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}), 0600))
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	assert.NoError(t, err)
+	ecKey, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+	legacyBlock, err := x509.EncryptPEMBlock(rand.Reader, "EC PRIVATE KEY", ecKey, []byte("secret"), x509.PEMCipherAES256)
+	assert.NoError(t, err)
+
+	keys := map[string]*pem.Block{
+		"unencrypted key": {Type: "PRIVATE KEY", Bytes: pkcs8},
+		"PKCS#8 key":      {Type: "ENCRYPTED PRIVATE KEY", Bytes: encryptPKCS8(t, pkcs8, "secret")},
+		"legacy key":      legacyBlock,
+	}
+	for name, block := range keys {
+		t.Run(name, func(t *testing.T) {
+			keyFile := filepath.Join(dir, "client.key")
+			assert.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(block), 0600))
+			encrypted := block.Type != "PRIVATE KEY"
+
+			asked := false
+			tlsCert, err := LoadClientCertificate(certFile, keyFile, func() (string, error) {
+				asked = true
+				return "secret", nil
+			})
+			assert.NoError(t, err)
+			assert.Equal(t, encrypted, asked)
+			assert.Equal(t, cert.Raw, tlsCert.Certificate[0])
+
+			if encrypted {
+				_, err = LoadClientCertificate(certFile, keyFile, func() (string, error) { return "wrong", nil })
+				assert.ErrorIs(t, err, ErrIncorrectPassphrase)
+
+				_, err = LoadClientCertificate(certFile, keyFile, func() (string, error) { return "", errors.New("no passphrase") })
+				assert.EqualError(t, err, "no passphrase")
+			}
+		})
+	}
+}
+
+func TestLoadPKCS12ClientCertificate(t *testing.T) {
+	cert, key := newTestClientCert(t)
+	dir := t.TempDir()
+
+	for _, password := range []string{"", "secret"} {
+		data, err := pkcs12.Modern.Encode(key, cert, nil, password)
+		assert.NoError(t, err)
+		bundleFile := filepath.Join(dir, "client.p12")
+		assert.NoError(t, os.WriteFile(bundleFile, data, 0600))
+
+		asked := false
+		tlsCert, err := LoadPKCS12ClientCertificate(bundleFile, func() (string, error) {
+			asked = true
+			return "secret", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, password != "", asked)
+		assert.Equal(t, cert.Raw, tlsCert.Certificate[0])
+
+		if password != "" {
+			_, err = LoadPKCS12ClientCertificate(bundleFile, func() (string, error) { return "wrong", nil })
+			assert.ErrorIs(t, err, ErrIncorrectPassphrase)
+		}
+	}
+}
+
+func TestUseClientCertificate(t *testing.T) {
+	cert, key := newTestClientCert(t)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	tlsCert := tls.Certificate{Certificate: [][]byte{cert.Raw}, PrivateKey: key}
+
+	transport := server.Client().Transport.(*http.Transport).Clone()
+	_, err := (&http.Client{Transport: transport}).Get(server.URL)
+	assert.Error(t, err)
+
+	transport = server.Client().Transport.(*http.Transport).Clone()
+	UseClientCertificate(transport, tlsCert)
+	res, err := (&http.Client{Transport: transport}).Get(server.URL)
+	assert.NoError(t, err)
+	res.Body.Close()
+
+	// The certificate is also presented when probing the server's certificate
+	serverCert, err := GetServerCertWithClientCert(strings.TrimPrefix(server.URL, "https://"), &tlsCert)
+	assert.NoError(t, err)
+	assert.Equal(t, getSha256Fingerprint(server.Certificate().Raw), serverCert.Fingerprint)
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "os"
+
+// LockFile takes an exclusive advisory lock on the file at path, which is created if needed,
+// waiting until the other processes holding it release it. The returned function releases it.
+func LockFile(path string) (unlock func(), err error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err = lockFile(file); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		unlockFile(file)
+		file.Close()
+	}, nil
+}
@@ -90,7 +90,7 @@ func TestDumpTransport(t *testing.T) {
 				req.Header.Add(k, v)
 			}
 
-			dump := NewDumpTransport(nil, nil).dumpRequest(req)
+			dump := NewDumpTransport(nil, nil, nil).dumpRequest(req)
 			tc.assert(t, req, string(dump))
 		})
 	}
@@ -137,13 +137,13 @@ func TestDumpTransport(t *testing.T) {
 				Request: &http.Request{URL: &url.URL{Path: tc.path}},
 			}
 
-			dump := NewDumpTransport(nil, nil).dumpResponse(&resp)
+			dump := NewDumpTransport(nil, nil, nil).dumpResponse(&resp)
 			tc.assert(t, &resp, string(dump))
 		})
 	}
 }
 
-func Test_redactCookies(t *testing.T) {
+func Test_redactHeader(t *testing.T) {
 	tests := []struct {
 		name    string
 		headers http.Header
@@ -169,7 +169,7 @@ func Test_redactCookies(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			res := &http.Response{}
 			res.Header = tt.headers
-			cleanup := redactCookies(res)
+			cleanup := redactHeader(res.Header, setCookieHeader)
 			if len(tt.headers.Values(setCookieHeader)) == 0 {
 				assert.Empty(t, res.Header.Values(setCookieHeader))
 			} else {
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// followerRetryAfter is how long a follower that failed is skipped for
+const followerRetryAfter = 30 * time.Second
+
+// failoverStatusCodes are the responses of followers that are down or out of service
+var failoverStatusCodes = []int{
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+type followerTransport struct {
+	roundTripper http.RoundTripper
+	leader       *url.URL
+	followers    []*url.URL
+	isRead       func(req *http.Request) bool
+	log          func(string)
+	now          func() time.Time
+	// start is the index of the follower tried first, so that the invocations spread over followers
+	start int
+
+	mutex sync.Mutex
+	// failed are the times the followers last failed, by URL
+	failed map[string]time.Time
+}
+
+// followerURL returns the URL of the request sent to the follower instead of the leader
+func (t *followerTransport) followerURL(follower *url.URL, leaderURL *url.URL) *url.URL {
+	u := *leaderURL
+	u.Scheme = follower.Scheme
+	u.Host = follower.Host
+	// The escaped path is rewritten, as IDs hold escaped slashes
+	escapedPath := strings.TrimSuffix(follower.EscapedPath(), "/") + strings.TrimPrefix(leaderURL.EscapedPath(), strings.TrimSuffix(t.leader.EscapedPath(), "/"))
+	if path, err := url.PathUnescape(escapedPath); err == nil {
+		u.Path = path
+		u.RawPath = escapedPath
+	}
+	return &u
+}
+
+// healthyFollowers returns the followers to try in order, leaving out those that failed recently
+func (t *followerTransport) healthyFollowers() []*url.URL {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var healthy []*url.URL
+	for i := range t.followers {
+		follower := t.followers[(t.start+i)%len(t.followers)]
+		if failed, ok := t.failed[follower.String()]; ok && t.now().Sub(failed) < followerRetryAfter {
+			continue
+		}
+		healthy = append(healthy, follower)
+	}
+	return healthy
+}
+
+func (t *followerTransport) markFailed(follower *url.URL) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.failed[follower.String()] = t.now()
+}
+
+func (t *followerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != t.leader.Host || !t.isRead(req) {
+		return t.roundTripper.RoundTrip(req)
+	}
+
+	for _, follower := range t.healthyFollowers() {
+		followerReq := req.Clone(req.Context())
+		followerReq.URL = t.followerURL(follower, req.URL)
+		followerReq.Host = ""
+
+		res, err := t.roundTripper.RoundTrip(followerReq)
+		if err == nil && !slices.Contains(failoverStatusCodes, res.StatusCode) {
+			return res, nil
+		}
+		if req.Context().Err() != nil {
+			return res, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = res.Status
+			// Drain the body so that the connection can be reused
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		t.log(fmt.Sprintf("Follower %s failed, skipping it for %s: %s", follower.Redacted(), followerRetryAfter, reason))
+		t.markFailed(follower)
+	}
+
+	if len(t.followers) > 0 {
+		t.log(fmt.Sprintf("No follower is available, sending %s %s to the leader", req.Method, req.URL.Redacted()))
+	}
+	return t.roundTripper.RoundTrip(req)
+}
+
+// NewFollowerTransport creates a RoundTripper that sends the requests to the leader for which isRead
+// returns true to one of the followers instead. The next follower is tried when one can't be
+// reached or is out of service, and the leader when none is left. The followers that failed are
+// skipped for a while. Failovers are logged with logFunc, which may be nil.
+func NewFollowerTransport(roundTripper http.RoundTripper, leader *url.URL, followers []*url.URL, isRead func(req *http.Request) bool, logFunc func(string)) *followerTransport {
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	if logFunc == nil {
+		logFunc = func(string) {}
+	}
+	start := 0
+	if len(followers) > 1 {
+		start = rand.N(len(followers))
+	}
+
+	return &followerTransport{
+		roundTripper: roundTripper,
+		leader:       leader,
+		followers:    followers,
+		isRead:       isRead,
+		log:          logFunc,
+		now:          time.Now,
+		start:        start,
+		failed:       map[string]time.Time{},
+	}
+}
+
+// IsFollowerTransport reports whether the RoundTripper already routes requests to followers
+func IsFollowerTransport(roundTripper http.RoundTripper) bool {
+	_, ok := roundTripper.(*followerTransport)
+	return ok
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newStubServer returns a server responding with status, recording the paths requested from it
+func newStubServer(t *testing.T, status int) (*httptest.Server, *[]string) {
+	paths := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.RequestURI())
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return server, &paths
+}
+
+func isGet(req *http.Request) bool {
+	return req.Method == http.MethodGet
+}
+
+func TestFollowerTransport(t *testing.T) {
+	parse := func(rawURL string) *url.URL {
+		u, _ := url.Parse(rawURL)
+		return u
+	}
+
+	t.Run("sends reads to the follower and writes to the leader", func(t *testing.T) {
+		leader, leaderPaths := newStubServer(t, http.StatusOK)
+		follower, followerPaths := newStubServer(t, http.StatusOK)
+		transport := NewFollowerTransport(nil, parse(leader.URL), []*url.URL{parse(follower.URL)}, isGet, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, leader.URL+"/secrets/conjur/variable/db%2Fpassword?version=2", nil)
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+
+		req, _ = http.NewRequest(http.MethodPost, leader.URL+"/secrets/conjur/variable/db%2Fpassword", strings.NewReader("secret"))
+		_, err = transport.RoundTrip(req)
+		assert.NoError(t, err)
+
+		assert.Equal(t, []string{"/secrets/conjur/variable/db%2Fpassword?version=2"}, *followerPaths)
+		assert.Equal(t, []string{"/secrets/conjur/variable/db%2Fpassword"}, *leaderPaths)
+	})
+
+	t.Run("keeps the base path of the follower", func(t *testing.T) {
+		leader, _ := newStubServer(t, http.StatusOK)
+		follower, followerPaths := newStubServer(t, http.StatusOK)
+		transport := NewFollowerTransport(nil, parse(leader.URL+"/api"), []*url.URL{parse(follower.URL + "/conjur/")}, isGet, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, leader.URL+"/api/resources/conjur", nil)
+		_, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"/conjur/resources/conjur"}, *followerPaths)
+	})
+
+	t.Run("fails over to the next follower, then to the leader", func(t *testing.T) {
+		leader, leaderPaths := newStubServer(t, http.StatusOK)
+		unavailable, unavailablePaths := newStubServer(t, http.StatusServiceUnavailable)
+		down, _ := newStubServer(t, http.StatusOK)
+		down.Close()
+		logs := []string{}
+		transport := NewFollowerTransport(nil, parse(leader.URL), []*url.URL{parse(unavailable.URL), parse(down.URL)}, isGet, func(message string) {
+			logs = append(logs, message)
+		})
+		transport.start = 0
+
+		req, _ := http.NewRequest(http.MethodGet, leader.URL+"/roles/conjur/user/alice", nil)
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, []string{"/roles/conjur/user/alice"}, *unavailablePaths)
+		assert.Equal(t, []string{"/roles/conjur/user/alice"}, *leaderPaths)
+
+		assert.Len(t, logs, 3)
+		assert.Equal(t, "Follower "+unavailable.URL+" failed, skipping it for 30s: 503 Service Unavailable", logs[0])
+		assert.Contains(t, logs[1], "Follower "+down.URL+" failed, skipping it for 30s: ")
+		assert.Equal(t, "No follower is available, sending GET "+leader.URL+"/roles/conjur/user/alice to the leader", logs[2])
+	})
+
+	t.Run("skips the followers that failed recently", func(t *testing.T) {
+		leader, leaderPaths := newStubServer(t, http.StatusOK)
+		follower, followerPaths := newStubServer(t, http.StatusBadGateway)
+		transport := NewFollowerTransport(nil, parse(leader.URL), []*url.URL{parse(follower.URL)}, isGet, nil)
+		now := time.Now()
+		transport.now = func() time.Time { return now }
+
+		for i := 0; i < 2; i++ {
+			req, _ := http.NewRequest(http.MethodGet, leader.URL+"/resources/conjur", nil)
+			_, err := transport.RoundTrip(req)
+			assert.NoError(t, err)
+		}
+		assert.Len(t, *followerPaths, 1)
+		assert.Len(t, *leaderPaths, 2)
+
+		// The follower is tried again once it has been skipped for a while
+		now = now.Add(followerRetryAfter)
+		req, _ := http.NewRequest(http.MethodGet, leader.URL+"/resources/conjur", nil)
+		_, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Len(t, *followerPaths, 2)
+	})
+
+	t.Run("passes the errors of the follower through", func(t *testing.T) {
+		leader, leaderPaths := newStubServer(t, http.StatusOK)
+		follower, _ := newStubServer(t, http.StatusNotFound)
+		transport := NewFollowerTransport(nil, parse(leader.URL), []*url.URL{parse(follower.URL)}, isGet, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, leader.URL+"/secrets/conjur/variable/missing", nil)
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+		assert.Empty(t, *leaderPaths)
+	})
+
+	t.Run("leaves the requests to other hosts alone", func(t *testing.T) {
+		leader, _ := newStubServer(t, http.StatusOK)
+		other, otherPaths := newStubServer(t, http.StatusOK)
+		follower, followerPaths := newStubServer(t, http.StatusOK)
+		transport := NewFollowerTransport(nil, parse(leader.URL), []*url.URL{parse(follower.URL)}, isGet, nil)
+
+		req, _ := http.NewRequest(http.MethodGet, other.URL+"/secrets", nil)
+		_, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Len(t, *otherPaths, 1)
+		assert.Empty(t, *followerPaths)
+	})
+}
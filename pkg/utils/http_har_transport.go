@@ -0,0 +1,342 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync"
+	"time"
+)
+
+// The HAR 1.2 format, see http://www.softwareishard.com/blog/har-12-spec/
+type harLog struct {
+	Log harContent `json:"log"`
+}
+
+type harContent struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	// Custom fields start with an underscore
+	Error string `json:"_error,omitempty"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harBody        `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harBody struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+// harTimings are in milliseconds, -1 when they don't apply
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	SSL     float64 `json:"ssl"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harEntriesStart is where the entries go in the HAR file
+const harEntriesStart = `"entries": [`
+
+// HARRecorder writes the requests and responses of the transports it creates to a HAR file, with the
+// same redactions as the debug logs. Each entry is written over the end of the file, which is written
+// again after it, so that the file is complete even if the command fails.
+type HARRecorder struct {
+	path     string
+	redactor *Redactor
+	log      harLog
+	mu       sync.Mutex
+	// end is the offset of the end of the file, which follows the last entry
+	end     int64
+	entries int
+}
+
+// NewHARRecorder creates a recorder writing to the HAR file at the given path, redacted by the given
+// Redactor or by the built-in rules when it is nil
+func NewHARRecorder(path string, creatorVersion string, redactor *Redactor) *HARRecorder {
+	return &HARRecorder{
+		path:     path,
+		redactor: defaultRedactor(redactor),
+		log: harLog{Log: harContent{
+			Version: "1.2",
+			Creator: harCreator{Name: "conjur-cli", Version: creatorVersion},
+			Entries: []harEntry{},
+		}},
+	}
+}
+
+func (r *HARRecorder) record(entry harEntry) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.MarshalIndent(r.log, "", "  ")
+	if err != nil {
+		return err
+	}
+	entriesStart := bytes.Index(data, []byte(harEntriesStart)) + len(harEntriesStart)
+	// The closing bracket of the empty entries, and what follows
+	end := append([]byte("\n    "), data[entriesStart:]...)
+
+	flags := os.O_WRONLY
+	if r.entries == 0 {
+		// The file of a previous command is overwritten
+		flags |= os.O_CREATE | os.O_TRUNC
+	}
+	file, err := os.OpenFile(r.path, flags, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if r.entries == 0 {
+		if _, err = file.Write(data[:entriesStart]); err != nil {
+			return err
+		}
+		r.end = int64(entriesStart)
+	}
+
+	entryData, err := json.MarshalIndent(entry, "      ", "  ")
+	if err != nil {
+		return err
+	}
+	separator := "\n      "
+	if r.entries > 0 {
+		separator = "," + separator
+	}
+	entryData = append([]byte(separator), entryData...)
+	if _, err = file.WriteAt(append(entryData, end...), r.end); err != nil {
+		return err
+	}
+	r.end += int64(len(entryData))
+	r.entries++
+	return nil
+}
+
+// Transport returns a RoundTripper recording the requests and responses of the given one
+func (r *HARRecorder) Transport(roundTripper http.RoundTripper) http.RoundTripper {
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	return &harTransport{roundTripper: roundTripper, recorder: r}
+}
+
+type harTransport struct {
+	roundTripper http.RoundTripper
+	recorder     *HARRecorder
+}
+
+// IsHARTransport reports whether the RoundTripper already records requests and responses to a HAR file
+func IsHARTransport(roundTripper http.RoundTripper) bool {
+	_, ok := roundTripper.(*harTransport)
+	return ok
+}
+
+// readBody reads a body and replaces it with a copy, so that it can still be read. It must not be used on
+// the body of a request that is sent, which only the transport sending it may read.
+func readBody(rc *io.ReadCloser) []byte {
+	if *rc == nil || *rc == http.NoBody {
+		return nil
+	}
+	content, _ := io.ReadAll(*rc)
+	(*rc).Close()
+	*rc = io.NopCloser(bytes.NewReader(content))
+	return content
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	values := []harNameValue{}
+	for name, headerValues := range header {
+		for _, value := range headerValues {
+			values = append(values, harNameValue{Name: name, Value: value})
+		}
+	}
+	return values
+}
+
+func harCookies(cookies []*http.Cookie) []harNameValue {
+	values := []harNameValue{}
+	for _, cookie := range cookies {
+		values = append(values, harNameValue{Name: cookie.Name, Value: cookie.Value})
+	}
+	return values
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// since returns the milliseconds between two trace events, or -1 when they didn't happen
+func since(start, end time.Time) float64 {
+	if start.IsZero() || end.IsZero() {
+		return -1
+	}
+	return milliseconds(end.Sub(start))
+}
+
+// harRequestFor records a request with its credentials and secrets redacted. The request is left as is, a
+// copy of it is redacted instead, with its body read again with GetBody.
+func harRequestFor(req *http.Request, redactor *Redactor) harRequest {
+	bodySize := req.ContentLength
+	req = req.Clone(req.Context())
+	req.Body = nil
+	if req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			req.Body = body
+		}
+	}
+	redactor.redactRequest(req)
+
+	query := []harNameValue{}
+	for name, values := range req.URL.Query() {
+		for _, value := range values {
+			query = append(query, harNameValue{Name: name, Value: value})
+		}
+	}
+	request := harRequest{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Cookies:     harCookies(req.Cookies()),
+		Headers:     harHeaders(req.Header),
+		QueryString: query,
+		HeadersSize: -1,
+		BodySize:    max(bodySize, 0),
+	}
+	// Without GetBody, the body can't be read without consuming it, only its size is recorded
+	if body := readBody(&req.Body); body != nil {
+		request.BodySize = int64(len(body))
+		request.PostData = &harPostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return request
+}
+
+// harResponseFor records a response with its credentials and secrets redacted
+func harResponseFor(res *http.Response, redactor *Redactor) harResponse {
+	restore := redactor.redactResponse(res)
+	defer restore()
+
+	body := readBody(&res.Body)
+	return harResponse{
+		Status:      res.StatusCode,
+		StatusText:  http.StatusText(res.StatusCode),
+		HTTPVersion: res.Proto,
+		Cookies:     harCookies(res.Cookies()),
+		Headers:     harHeaders(res.Header),
+		Content: harBody{
+			Size:     int64(len(body)),
+			MimeType: res.Header.Get("Content-Type"),
+			Text:     string(body),
+		},
+		RedirectURL: res.Header.Get("Location"),
+		HeadersSize: -1,
+		BodySize:    int64(len(body)),
+	}
+}
+
+func (t *harTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var dnsStart, dnsDone, connectStart, connectDone, tlsStart, tlsDone, gotConn, wroteRequest, firstByte time.Time
+	trace := &httptrace.ClientTrace{
+		DNSStart:             func(httptrace.DNSStartInfo) { dnsStart = time.Now() },
+		DNSDone:              func(httptrace.DNSDoneInfo) { dnsDone = time.Now() },
+		ConnectStart:         func(string, string) { connectStart = time.Now() },
+		ConnectDone:          func(string, string, error) { connectDone = time.Now() },
+		TLSHandshakeStart:    func() { tlsStart = time.Now() },
+		TLSHandshakeDone:     func(tls.ConnectionState, error) { tlsDone = time.Now() },
+		GotConn:              func(httptrace.GotConnInfo) { gotConn = time.Now() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { wroteRequest = time.Now() },
+		GotFirstResponseByte: func() { firstByte = time.Now() },
+	}
+
+	entry := harEntry{Request: harRequestFor(req, t.recorder.redactor)}
+	start := time.Now()
+	entry.StartedDateTime = start.Format(time.RFC3339Nano)
+
+	res, err := t.roundTripper.RoundTrip(req.WithContext(httptrace.WithClientTrace(req.Context(), trace)))
+	if err != nil {
+		// HAR has no failed exchanges, record them without a response
+		entry.Error = err.Error()
+		entry.Response = harResponse{Cookies: []harNameValue{}, Headers: []harNameValue{}, HeadersSize: -1, BodySize: -1}
+		entry.Time = milliseconds(time.Since(start))
+		entry.Timings = harTimings{Blocked: -1, DNS: -1, Connect: -1, SSL: -1, Wait: entry.Time}
+		// Failing to record the exchange doesn't fail the command
+		_ = t.recorder.record(entry)
+		return res, err
+	}
+
+	headersDone := time.Now()
+	entry.Response = harResponseFor(res, t.recorder.redactor)
+	end := time.Now()
+
+	// In HAR, connect includes the TLS handshake
+	connectEnd := connectDone
+	if tlsDone.After(connectEnd) {
+		connectEnd = tlsDone
+	}
+	entry.Timings = harTimings{
+		Blocked: -1,
+		DNS:     since(dnsStart, dnsDone),
+		Connect: since(connectStart, connectEnd),
+		SSL:     since(tlsStart, tlsDone),
+		Send:    max(since(gotConn, wroteRequest), 0),
+		Wait:    max(since(wroteRequest, firstByte), 0),
+		Receive: milliseconds(end.Sub(headersDone)),
+	}
+	entry.Time = milliseconds(end.Sub(start))
+	_ = t.recorder.record(entry)
+
+	return res, err
+}
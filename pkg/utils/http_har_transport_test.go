@@ -0,0 +1,159 @@
+package utils
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func readHAR(t *testing.T, path string) harLog {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	var har harLog
+	assert.NoError(t, json.Unmarshal(data, &har))
+	return har
+}
+
+func TestHARRecorder(t *testing.T) {
+	token := `{"protected":"abc","payload":"def","signature":"ghi"}`
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Method == http.MethodPost && strings.Contains(r.URL.Path, "/authn") {
+			assert.Equal(t, "api-key", string(body))
+			w.Write([]byte(token))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`["dev:variable:db/password"]`))
+	}))
+	defer server.Close()
+
+	t.Run("records requests and responses with credentials redacted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "conjur.har")
+		client := &http.Client{Transport: NewHARRecorder(path, "9.2.0", nil).Transport(nil)}
+
+		res, err := client.Post(server.URL+"/authn/dev/alice/authenticate", "text/plain", strings.NewReader("api-key"))
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(res.Body)
+		assert.Equal(t, token, string(body))
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/resources/dev?kind=variable", nil)
+		req.Header.Set("Authorization", "Token token=\"secret-token\"")
+		res, err = client.Do(req)
+		assert.NoError(t, err)
+		body, _ = io.ReadAll(res.Body)
+		assert.Equal(t, `["dev:variable:db/password"]`, string(body))
+		assert.Equal(t, "Token token=\"secret-token\"", req.Header.Get("Authorization"))
+
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+		data, _ := os.ReadFile(path)
+		assert.NotContains(t, string(data), "api-key")
+		assert.NotContains(t, string(data), "secret-token")
+		assert.NotContains(t, string(data), "signature")
+
+		har := readHAR(t, path)
+		assert.Equal(t, "1.2", har.Log.Version)
+		assert.Equal(t, harCreator{Name: "conjur-cli", Version: "9.2.0"}, har.Log.Creator)
+		assert.Len(t, har.Log.Entries, 2)
+
+		authn := har.Log.Entries[0]
+		assert.Equal(t, "POST", authn.Request.Method)
+		assert.Equal(t, redactedString, authn.Request.PostData.Text)
+		assert.Equal(t, redactedString, authn.Response.Content.Text)
+
+		resources := har.Log.Entries[1]
+		assert.Equal(t, server.URL+"/resources/dev?kind=variable", resources.Request.URL)
+		assert.Contains(t, resources.Request.Headers, harNameValue{Name: "Authorization", Value: redactedString})
+		assert.Equal(t, []harNameValue{{Name: "kind", Value: "variable"}}, resources.Request.QueryString)
+		assert.Nil(t, resources.Request.PostData)
+		assert.Equal(t, 200, resources.Response.Status)
+		assert.Equal(t, "application/json", resources.Response.Content.MimeType)
+		assert.Equal(t, `["dev:variable:db/password"]`, resources.Response.Content.Text)
+		assert.NotEmpty(t, resources.StartedDateTime)
+		assert.GreaterOrEqual(t, resources.Time, 0.0)
+		assert.GreaterOrEqual(t, resources.Timings.Wait, 0.0)
+	})
+
+	t.Run("redacts the OIDC authorization code and verifier", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "conjur.har")
+		client := &http.Client{Transport: NewHARRecorder(path, "9.2.0", nil).Transport(nil)}
+
+		authenticateURL := server.URL + "/authn-oidc/okta/dev/authenticate?code=auth-code&nonce=the-nonce&code_verifier=the-verifier"
+		res, err := client.Get(authenticateURL)
+		assert.NoError(t, err)
+		res.Body.Close()
+		assert.Equal(t, authenticateURL, res.Request.URL.String())
+
+		data, _ := os.ReadFile(path)
+		for _, value := range []string{"auth-code", "the-nonce", "the-verifier"} {
+			assert.NotContains(t, string(data), value)
+		}
+		request := readHAR(t, path).Log.Entries[0].Request
+		assert.Equal(t, server.URL+"/authn-oidc/okta/dev/authenticate?code=[REDACTED]&nonce=[REDACTED]&code_verifier=[REDACTED]", request.URL)
+		assert.ElementsMatch(t, []harNameValue{
+			{Name: "code", Value: redactedString},
+			{Name: "nonce", Value: redactedString},
+			{Name: "code_verifier", Value: redactedString},
+		}, request.QueryString)
+	})
+
+	t.Run("leaves the body of the request to the transport", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "conjur.har")
+		client := &http.Client{Transport: NewHARRecorder(path, "9.2.0", nil).Transport(nil)}
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/policies/dev/policy/root", strings.NewReader("- !variable db/password"))
+		body := req.Body
+		res, err := client.Do(req)
+		assert.NoError(t, err)
+		res.Body.Close()
+		assert.True(t, body == req.Body, "the body of the request was replaced")
+
+		// The body is recorded from GetBody
+		har := readHAR(t, path)
+		assert.Len(t, har.Log.Entries, 1)
+		assert.Equal(t, "- !variable db/password", har.Log.Entries[0].Request.PostData.Text)
+		assert.Equal(t, int64(23), har.Log.Entries[0].Request.BodySize)
+	})
+
+	t.Run("appends the entries to the file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "conjur.har")
+		assert.NoError(t, os.WriteFile(path, []byte("previous command"), 0600))
+		client := &http.Client{Transport: NewHARRecorder(path, "9.2.0", nil).Transport(nil)}
+
+		for i := 1; i <= 3; i++ {
+			res, err := client.Get(server.URL + "/resources/dev")
+			assert.NoError(t, err)
+			res.Body.Close()
+			assert.Len(t, readHAR(t, path).Log.Entries, i)
+		}
+	})
+
+	t.Run("records failed requests", func(t *testing.T) {
+		closed := httptest.NewServer(http.NotFoundHandler())
+		closed.Close()
+		path := filepath.Join(t.TempDir(), "conjur.har")
+		client := &http.Client{Transport: NewHARRecorder(path, "9.2.0", nil).Transport(nil)}
+
+		_, err := client.Get(closed.URL)
+		assert.Error(t, err)
+
+		har := readHAR(t, path)
+		assert.Len(t, har.Log.Entries, 1)
+		assert.Contains(t, har.Log.Entries[0].Error, "connection refused")
+		assert.Equal(t, 0, har.Log.Entries[0].Response.Status)
+	})
+}
+
+func TestIsHARTransport(t *testing.T) {
+	assert.True(t, IsHARTransport(NewHARRecorder("conjur.har", "", nil).Transport(nil)))
+	assert.False(t, IsHARTransport(http.DefaultTransport))
+}
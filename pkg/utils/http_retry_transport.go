@@ -0,0 +1,180 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// retryBaseWait is the wait before the first retry, which doubles on each retry
+const retryBaseWait = 500 * time.Millisecond
+
+// retryableStatusCodes are the responses of overloaded or unavailable servers and load balancers
+var retryableStatusCodes = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+type retryTransport struct {
+	roundTripper http.RoundTripper
+	retries      int
+	maxWait      time.Duration
+	logRetry     func(string)
+	sleep        func(ctx context.Context, wait time.Duration) error
+}
+
+// isSafe determines whether the request only reads, so that sending it more than once has no other effect
+func isSafe(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	default:
+		return false
+	}
+}
+
+// shouldRetry determines whether a failed attempt is worth retrying. Requests that change something,
+// like POST, PUT, PATCH and DELETE, are only retried when they can't have been sent because the
+// connection couldn't be established.
+func shouldRetry(req *http.Request, res *http.Response, err error) bool {
+	if err != nil {
+		if req.Context().Err() != nil {
+			return false
+		}
+		var opErr *net.OpError
+		if errors.As(err, &opErr) && opErr.Op == "dial" {
+			return true
+		}
+		return isSafe(req) && (errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF))
+	}
+	return isSafe(req) && slices.Contains(retryableStatusCodes, res.StatusCode)
+}
+
+// retryAfter parses the Retry-After header, which is either a number of seconds or a date
+func retryAfter(res *http.Response) (time.Duration, bool) {
+	if res == nil {
+		return 0, false
+	}
+	value := res.Header.Get("Retry-After")
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return max(time.Duration(seconds)*time.Second, 0), true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return max(time.Until(date), 0), true
+	}
+	return 0, false
+}
+
+// wait returns how long to wait before the retry, using exponential backoff with jitter unless the
+// server said when to retry. It returns false when the server asked to wait longer than the maximum.
+func (t *retryTransport) wait(retry int, res *http.Response) (time.Duration, bool) {
+	if after, ok := retryAfter(res); ok {
+		return after, after <= t.maxWait
+	}
+	backoff := retryBaseWait << (retry - 1)
+	if backoff <= 0 || backoff > t.maxWait {
+		backoff = t.maxWait
+	}
+	return backoff/2 + rand.N(backoff/2+1), true
+}
+
+// rewind returns a copy of the request with its body read from the start, so that it can be sent again
+func rewind(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, errors.New("request body can't be read again")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	retryReq := req.Clone(req.Context())
+	retryReq.Body = body
+	return retryReq, nil
+}
+
+func sleepContext(ctx context.Context, wait time.Duration) error {
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	attemptReq := req
+	for retry := 1; ; retry++ {
+		res, err := t.roundTripper.RoundTrip(attemptReq)
+		if retry > t.retries || !shouldRetry(req, res, err) {
+			return res, err
+		}
+		wait, ok := t.wait(retry, res)
+		if !ok {
+			return res, err
+		}
+		retryReq, rewindErr := rewind(req)
+		if rewindErr != nil {
+			return res, err
+		}
+
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = res.Status
+			// Drain the body so that the connection can be reused
+			io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+		}
+		t.logRetry(fmt.Sprintf("Retrying %s %s in %s (%d of %d): %s", req.Method, req.URL.Redacted(), wait.Round(time.Millisecond), retry, t.retries, reason))
+
+		if err := t.sleep(req.Context(), wait); err != nil {
+			return nil, err
+		}
+		attemptReq = retryReq
+	}
+}
+
+// NewRetryTransport creates a RoundTripper that retries requests failing with a transient error up
+// to the given number of times, waiting at most maxWait between attempts. The retries are logged with
+// logFunc, which may be nil.
+func NewRetryTransport(roundTripper http.RoundTripper, retries int, maxWait time.Duration, logFunc func(string)) *retryTransport {
+	if roundTripper == nil {
+		roundTripper = http.DefaultTransport
+	}
+	if logFunc == nil {
+		logFunc = func(string) {}
+	}
+
+	return &retryTransport{
+		roundTripper: roundTripper,
+		retries:      retries,
+		maxWait:      maxWait,
+		logRetry:     logFunc,
+		sleep:        sleepContext,
+	}
+}
+
+// IsRetryTransport reports whether the RoundTripper already retries requests failing with a transient error
+func IsRetryTransport(roundTripper http.RoundTripper) bool {
+	_, ok := roundTripper.(*retryTransport)
+	return ok
+}
@@ -0,0 +1,160 @@
+package utils
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRetryTransport returns a retry transport which records its waits instead of sleeping
+func newTestRetryTransport(retries int, maxWait time.Duration) (*retryTransport, *[]time.Duration, *[]string) {
+	waits := []time.Duration{}
+	logs := []string{}
+	transport := NewRetryTransport(nil, retries, maxWait, func(message string) {
+		logs = append(logs, message)
+	})
+	transport.sleep = func(ctx context.Context, wait time.Duration) error {
+		waits = append(waits, wait)
+		return nil
+	}
+	return transport, &waits, &logs
+}
+
+// newFlakyServer returns a server responding with the given statuses, then 200
+func newFlakyServer(t *testing.T, headers http.Header, statuses ...int) (*httptest.Server, *[]string) {
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) <= len(statuses) {
+			for key, values := range headers {
+				w.Header()[key] = values
+			}
+			w.WriteHeader(statuses[len(bodies)-1])
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+func TestRetryTransport(t *testing.T) {
+	t.Run("retries safe requests on transient errors", func(t *testing.T) {
+		server, bodies := newFlakyServer(t, nil, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout)
+		transport, waits, logs := newTestRetryTransport(3, 30*time.Second)
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/secrets", nil)
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, *bodies, 4)
+
+		// Jittered exponential backoff
+		assert.Len(t, *waits, 3)
+		for i, wait := range *waits {
+			backoff := retryBaseWait << i
+			assert.GreaterOrEqual(t, wait, backoff/2)
+			assert.LessOrEqual(t, wait, backoff)
+		}
+		assert.Len(t, *logs, 3)
+		assert.Contains(t, (*logs)[0], "Retrying GET "+server.URL+"/secrets in ")
+		assert.Contains(t, (*logs)[0], "(1 of 3): 502 Bad Gateway")
+	})
+
+	t.Run("gives up after the retries", func(t *testing.T) {
+		server, bodies := newFlakyServer(t, nil, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		transport, _, _ := newTestRetryTransport(2, 30*time.Second)
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusBadGateway, res.StatusCode)
+		assert.Len(t, *bodies, 3)
+	})
+
+	t.Run("doesn't retry requests that change something", func(t *testing.T) {
+		for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete} {
+			for _, status := range []int{http.StatusTooManyRequests, http.StatusBadGateway} {
+				server, bodies := newFlakyServer(t, nil, status)
+				transport, _, _ := newTestRetryTransport(3, 30*time.Second)
+
+				req, _ := http.NewRequest(method, server.URL, strings.NewReader("policy"))
+				res, err := transport.RoundTrip(req)
+				assert.NoError(t, err)
+				assert.Equal(t, status, res.StatusCode)
+				assert.Len(t, *bodies, 1, "%s %d", method, status)
+			}
+		}
+	})
+
+	t.Run("retries requests rejected with 429 after Retry-After", func(t *testing.T) {
+		server, bodies := newFlakyServer(t, http.Header{"Retry-After": {"7"}}, http.StatusTooManyRequests)
+		transport, waits, _ := newTestRetryTransport(3, 30*time.Second)
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Len(t, *bodies, 2)
+		assert.Equal(t, []time.Duration{7 * time.Second}, *waits)
+	})
+
+	t.Run("gives up when Retry-After is longer than the maximum wait", func(t *testing.T) {
+		server, bodies := newFlakyServer(t, http.Header{"Retry-After": {"120"}}, http.StatusServiceUnavailable)
+		transport, _, _ := newTestRetryTransport(3, 30*time.Second)
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		res, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusServiceUnavailable, res.StatusCode)
+		assert.Len(t, *bodies, 1)
+	})
+
+	t.Run("caps the backoff to the maximum wait", func(t *testing.T) {
+		server, _ := newFlakyServer(t, nil, http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway)
+		transport, waits, _ := newTestRetryTransport(3, time.Second)
+
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		_, err := transport.RoundTrip(req)
+		assert.NoError(t, err)
+		for _, wait := range *waits {
+			assert.LessOrEqual(t, wait, time.Second)
+		}
+	})
+
+	t.Run("retries requests which couldn't connect", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		transport, _, logs := newTestRetryTransport(1, 30*time.Second)
+
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader("policy"))
+		_, err := transport.RoundTrip(req)
+		assert.Error(t, err)
+		assert.Len(t, *logs, 1)
+		assert.Contains(t, (*logs)[0], "connection refused")
+	})
+
+	t.Run("stops waiting when the request is canceled", func(t *testing.T) {
+		server, _ := newFlakyServer(t, nil, http.StatusBadGateway)
+		transport := NewRetryTransport(nil, 1, time.Hour, nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+		_, err := transport.RoundTrip(req)
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	})
+}
+
+func TestIsRetryTransport(t *testing.T) {
+	assert.True(t, IsRetryTransport(NewRetryTransport(nil, 1, time.Second, nil)))
+	assert.False(t, IsRetryTransport(http.DefaultTransport))
+	assert.False(t, IsRetryTransport(NewDumpTransport(nil, nil, nil)))
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// JWT represents a decoded JSON Web Token. Decoding does not verify the signature,
+// use VerifyJWTSignature for that.
+type JWT struct {
+	Header       map[string]interface{}
+	Claims       map[string]interface{}
+	SigningInput []byte
+	Signature    []byte
+}
+
+// ParseJWT decodes a compact serialized JWT
+func ParseJWT(token string) (*JWT, error) {
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("token must have 3 parts separated by '.', found %d", len(parts))
+	}
+
+	jwt := &JWT{SigningInput: []byte(parts[0] + "." + parts[1])}
+	if err := decodeJWTSegment(parts[0], &jwt.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+	if err := decodeJWTSegment(parts[1], &jwt.Claims); err != nil {
+		return nil, fmt.Errorf("invalid payload: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature encoding: %w", err)
+	}
+	jwt.Signature = signature
+
+	return jwt, nil
+}
+
+func decodeJWTSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// Algorithm returns the signing algorithm from the token header
+func (t *JWT) Algorithm() string {
+	alg, _ := t.Header["alg"].(string)
+	return alg
+}
+
+// KeyID returns the key ID from the token header
+func (t *JWT) KeyID() string {
+	kid, _ := t.Header["kid"].(string)
+	return kid
+}
+
+// StringClaim returns the value of a string claim. Nested claims can be addressed using
+// '/' as a separator, e.g. "namespace/name".
+func (t *JWT) StringClaim(path string) (string, bool) {
+	value, ok := t.claim(path)
+	if !ok {
+		return "", false
+	}
+	s, ok := value.(string)
+	return s, ok
+}
+
+// HasClaim returns whether a claim is present. Nested claims can be addressed using '/'.
+func (t *JWT) HasClaim(path string) bool {
+	_, ok := t.claim(path)
+	return ok
+}
+
+func (t *JWT) claim(path string) (interface{}, bool) {
+	var value interface{} = t.Claims
+	for _, name := range strings.Split(path, "/") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[name]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// Audience returns the values of the 'aud' claim, which may be a string or a list of strings
+func (t *JWT) Audience() []string {
+	switch aud := t.Claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		audience := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				audience = append(audience, s)
+			}
+		}
+		return audience
+	default:
+		return nil
+	}
+}
+
+// TimeClaim returns the value of a NumericDate claim such as 'exp', 'nbf' or 'iat'
+func (t *JWT) TimeClaim(name string) (time.Time, bool) {
+	value, ok := t.Claims[name].(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(value), 0), true
+}
+
+// JSONWebKey is a public key in JWK format
+type JSONWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid,omitempty"`
+	Alg string `json:"alg,omitempty"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// JSONWebKeySet is a set of public keys in JWKS format
+type JSONWebKeySet struct {
+	Keys []JSONWebKey `json:"keys"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. Besides a plain JWKS document it accepts the format of
+// the authn-jwt 'public-keys' variable, in which the key set is wrapped as {"type": "jwks", "value": {...}}.
+func ParseJWKS(data []byte) (*JSONWebKeySet, error) {
+	var wrapper struct {
+		Type  string          `json:"type"`
+		Value json.RawMessage `json:"value"`
+	}
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	if wrapper.Type != "" {
+		if wrapper.Type != "jwks" {
+			return nil, fmt.Errorf("unsupported public keys type: %s", wrapper.Type)
+		}
+		data = wrapper.Value
+	}
+
+	var jwks JSONWebKeySet
+	if err := json.Unmarshal(data, &jwks); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	if len(jwks.Keys) == 0 {
+		return nil, errors.New("JWKS does not contain any keys")
+	}
+	return &jwks, nil
+}
+
+// PublicKey converts the JWK into an RSA, ECDSA or Ed25519 public key
+func (k JSONWebKey) PublicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeJWKInt(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA modulus: %w", err)
+		}
+		e, err := decodeJWKInt(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RSA exponent: %w", err)
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported EC curve: %s", k.Crv)
+		}
+		x, err := decodeJWKInt(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC x coordinate: %w", err)
+		}
+		y, err := decodeJWKInt(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid EC y coordinate: %w", err)
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported OKP curve: %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 public key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type: %s", k.Kty)
+	}
+}
+
+func decodeJWKInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty value")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// VerifyJWTSignature verifies the signature of a token with the given public key, using the
+// algorithm declared in the token header
+func VerifyJWTSignature(token *JWT, key crypto.PublicKey) error {
+	alg := token.Algorithm()
+
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "PS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "PS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512", "PS512", "ES512":
+		hash = crypto.SHA512
+	case "EdDSA":
+		edKey, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type does not match algorithm %s", alg)
+		}
+		if !ed25519.Verify(edKey, token.SigningInput, token.Signature) {
+			return errors.New("signature is invalid")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported signing algorithm: %q", alg)
+	}
+
+	var digest []byte
+	switch hash {
+	case crypto.SHA256:
+		sum := sha256.Sum256(token.SigningInput)
+		digest = sum[:]
+	case crypto.SHA384:
+		sum := sha512.Sum384(token.SigningInput)
+		digest = sum[:]
+	default:
+		sum := sha512.Sum512(token.SigningInput)
+		digest = sum[:]
+	}
+
+	switch alg[:2] {
+	case "RS", "PS":
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type does not match algorithm %s", alg)
+		}
+		var err error
+		if alg[:2] == "RS" {
+			err = rsa.VerifyPKCS1v15(rsaKey, hash, digest, token.Signature)
+		} else {
+			err = rsa.VerifyPSS(rsaKey, hash, digest, token.Signature, nil)
+		}
+		if err != nil {
+			return errors.New("signature is invalid")
+		}
+	default:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("key type does not match algorithm %s", alg)
+		}
+		size := (ecKey.Curve.Params().BitSize + 7) / 8
+		if len(token.Signature) != 2*size {
+			return errors.New("signature is invalid")
+		}
+		r := new(big.Int).SetBytes(token.Signature[:size])
+		s := new(big.Int).SetBytes(token.Signature[size:])
+		if !ecdsa.Verify(ecKey, digest, r, s) {
+			return errors.New("signature is invalid")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func signTestJWT(t *testing.T, alg string, key crypto.Signer, claims map[string]interface{}) string {
+	header, err := json.Marshal(map[string]interface{}{"alg": alg, "kid": "test-key", "typ": "JWT"})
+	assert.NoError(t, err)
+	payload, err := json.Marshal(claims)
+	assert.NoError(t, err)
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	var signature []byte
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		signature, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		var r, s *big.Int
+		r, s, err = ecdsa.Sign(rand.Reader, k, digest[:])
+		signature = make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+	case ed25519.PrivateKey:
+		signature = ed25519.Sign(k, []byte(signingInput))
+	}
+	assert.NoError(t, err)
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestParseJWT(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	token := signTestJWT(t, "ES256", key, map[string]interface{}{
+		"iss":        "https://issuer.example.com",
+		"aud":        []string{"conjur", "other"},
+		"exp":        1700000000,
+		"repository": "cyberark/conjur",
+		"kubernetes.io": map[string]interface{}{
+			"namespace": "apps",
+		},
+	})
+
+	t.Run("decodes header and claims", func(t *testing.T) {
+		jwt, err := ParseJWT(token + "\n")
+		assert.NoError(t, err)
+		assert.Equal(t, "ES256", jwt.Algorithm())
+		assert.Equal(t, "test-key", jwt.KeyID())
+		assert.Equal(t, []string{"conjur", "other"}, jwt.Audience())
+
+		iss, ok := jwt.StringClaim("iss")
+		assert.True(t, ok)
+		assert.Equal(t, "https://issuer.example.com", iss)
+
+		namespace, ok := jwt.StringClaim("kubernetes.io/namespace")
+		assert.True(t, ok)
+		assert.Equal(t, "apps", namespace)
+		assert.False(t, jwt.HasClaim("kubernetes.io/pod"))
+
+		exp, ok := jwt.TimeClaim("exp")
+		assert.True(t, ok)
+		assert.Equal(t, time.Unix(1700000000, 0), exp)
+		_, ok = jwt.TimeClaim("nbf")
+		assert.False(t, ok)
+	})
+
+	t.Run("rejects malformed tokens", func(t *testing.T) {
+		_, err := ParseJWT("not-a-token")
+		assert.EqualError(t, err, "token must have 3 parts separated by '.', found 1")
+
+		_, err = ParseJWT("e30.!!!.e30")
+		assert.ErrorContains(t, err, "invalid payload")
+	})
+}
+
+func TestParseJWKS(t *testing.T) {
+	t.Run("plain key set", func(t *testing.T) {
+		jwks, err := ParseJWKS([]byte(`{"keys":[{"kty":"RSA","kid":"a","n":"AQAB","e":"AQAB"}]}`))
+		assert.NoError(t, err)
+		assert.Len(t, jwks.Keys, 1)
+		assert.Equal(t, "a", jwks.Keys[0].Kid)
+	})
+
+	t.Run("public-keys variable format", func(t *testing.T) {
+		jwks, err := ParseJWKS([]byte(`{"type":"jwks","value":{"keys":[{"kty":"OKP","kid":"b"}]}}`))
+		assert.NoError(t, err)
+		assert.Equal(t, "b", jwks.Keys[0].Kid)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		_, err := ParseJWKS([]byte(`{"type":"pem","value":"x"}`))
+		assert.EqualError(t, err, "unsupported public keys type: pem")
+
+		_, err = ParseJWKS([]byte(`{"keys":[]}`))
+		assert.EqualError(t, err, "JWKS does not contain any keys")
+
+		_, err = ParseJWKS([]byte(`not json`))
+		assert.ErrorContains(t, err, "invalid JWKS")
+	})
+}
+
+func TestVerifyJWTSignature(t *testing.T) {
+	claims := map[string]interface{}{"sub": "test"}
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	edPublicKey, edKey, err := ed25519.GenerateKey(rand.Reader)
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		name string
+		alg  string
+		key  crypto.Signer
+		jwk  JSONWebKey
+	}{
+		{
+			name: "RS256",
+			alg:  "RS256",
+			key:  rsaKey,
+			jwk: JSONWebKey{
+				Kty: "RSA",
+				N:   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+		},
+		{
+			name: "ES256",
+			alg:  "ES256",
+			key:  ecKey,
+			jwk: JSONWebKey{
+				Kty: "EC",
+				Crv: "P-256",
+				X:   base64.RawURLEncoding.EncodeToString(ecKey.X.Bytes()),
+				Y:   base64.RawURLEncoding.EncodeToString(ecKey.Y.Bytes()),
+			},
+		},
+		{
+			name: "EdDSA",
+			alg:  "EdDSA",
+			key:  edKey,
+			jwk: JSONWebKey{
+				Kty: "OKP",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(edPublicKey),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			jwt, err := ParseJWT(signTestJWT(t, tc.alg, tc.key, claims))
+			assert.NoError(t, err)
+			publicKey, err := tc.jwk.PublicKey()
+			assert.NoError(t, err)
+
+			assert.NoError(t, VerifyJWTSignature(jwt, publicKey))
+
+			jwt.SigningInput = append(jwt.SigningInput, 'x')
+			assert.EqualError(t, VerifyJWTSignature(jwt, publicKey), "signature is invalid")
+		})
+	}
+
+	t.Run("key type mismatch", func(t *testing.T) {
+		jwt, err := ParseJWT(signTestJWT(t, "RS256", rsaKey, claims))
+		assert.NoError(t, err)
+		assert.EqualError(t, VerifyJWTSignature(jwt, &ecKey.PublicKey), "key type does not match algorithm RS256")
+	})
+
+	t.Run("unsupported algorithm", func(t *testing.T) {
+		jwt := &JWT{Header: map[string]interface{}{"alg": "HS256"}}
+		assert.EqualError(t, VerifyJWTSignature(jwt, &rsaKey.PublicKey), "unsupported signing algorithm: \"HS256\"")
+	})
+}
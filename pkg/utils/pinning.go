@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// NormalizeFingerprint returns a SHA-256 fingerprint in the format of ServerCert.Fingerprint, accepting
+// lowercase and colon-separated fingerprints
+func NormalizeFingerprint(fingerprint string) (string, error) {
+	normalized := strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(fingerprint), ":", ""))
+	if _, err := hex.DecodeString(normalized); err != nil || len(normalized) != 64 {
+		return "", fmt.Errorf("%q is not a SHA-256 fingerprint, which has 64 hexadecimal characters", fingerprint)
+	}
+	return normalized, nil
+}
+
+// PinCertificate makes the transport reject the TLS connections to host whose certificate doesn't
+// have the given SHA-256 fingerprint, in addition to the usual verification. Connections to any other
+// host are rejected, except to the proxies, which are reached with the same TLS configuration.
+func PinCertificate(transport *http.Transport, host string, fingerprint string, proxyHosts ...string) {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if transport.TLSClientConfig != nil {
+		tlsConfig = transport.TLSClientConfig.Clone()
+	}
+
+	verifyConnection := tlsConfig.VerifyConnection
+	tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		if verifyConnection != nil {
+			if err := verifyConnection(state); err != nil {
+				return err
+			}
+		}
+		if state.ServerName != "" && state.ServerName != host {
+			for _, proxyHost := range proxyHosts {
+				if state.ServerName == proxyHost {
+					return nil
+				}
+			}
+			return fmt.Errorf("Refusing to connect to %s, only the certificate of %s is pinned", state.ServerName, host)
+		}
+		if len(state.PeerCertificates) == 0 {
+			return fmt.Errorf("%s didn't present a certificate", host)
+		}
+		if actual := getSha256Fingerprint(state.PeerCertificates[0].Raw); actual != fingerprint {
+			return fmt.Errorf("The certificate of %s has the fingerprint %s, which doesn't match the pinned fingerprint %s", host, actual, fingerprint)
+		}
+		return nil
+	}
+	transport.TLSClientConfig = tlsConfig
+}
@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeFingerprint(t *testing.T) {
+	fingerprint := strings.Repeat("AB", 32)
+
+	normalized, err := NormalizeFingerprint(fingerprint)
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint, normalized)
+
+	normalized, err = NormalizeFingerprint(" " + strings.TrimSuffix(strings.Repeat("ab:", 32), ":") + " ")
+	assert.NoError(t, err)
+	assert.Equal(t, fingerprint, normalized)
+
+	for _, invalid := range []string{"", "AB:CD", strings.Repeat("AB", 20), strings.Repeat("XY", 32)} {
+		_, err = NormalizeFingerprint(invalid)
+		assert.ErrorContains(t, err, "is not a SHA-256 fingerprint")
+	}
+}
+
+func TestPinCertificate(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+	fingerprint := getSha256Fingerprint(server.Certificate().Raw)
+
+	t.Run("accepts the pinned certificate", func(t *testing.T) {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		client := &http.Client{Transport: transport}
+		PinCertificate(transport, serverURL.Hostname(), fingerprint)
+
+		res, err := client.Get(server.URL)
+		assert.NoError(t, err)
+		res.Body.Close()
+	})
+
+	t.Run("rejects another certificate", func(t *testing.T) {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		client := &http.Client{Transport: transport}
+		PinCertificate(transport, serverURL.Hostname(), strings.Repeat("00", 32))
+
+		_, err := client.Get(server.URL)
+		assert.ErrorContains(t, err, "has the fingerprint "+fingerprint+", which doesn't match the pinned fingerprint "+strings.Repeat("00", 32))
+	})
+
+	// The connections without SNI, like to an IP address, are verified against the pinned fingerprint.
+	// The test certificate is also valid for example.com, which is dialed to the server instead.
+	exampleTransport := func() *http.Transport {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, serverURL.Host)
+		}
+		return transport
+	}
+
+	t.Run("rejects the other hosts", func(t *testing.T) {
+		transport := exampleTransport()
+		client := &http.Client{Transport: transport}
+		PinCertificate(transport, "conjur.example.com", fingerprint)
+
+		_, err := client.Get("https://example.com")
+		assert.ErrorContains(t, err, "Refusing to connect to example.com, only the certificate of conjur.example.com is pinned")
+	})
+
+	t.Run("accepts the proxies", func(t *testing.T) {
+		transport := exampleTransport()
+		client := &http.Client{Transport: transport}
+		PinCertificate(transport, "conjur.example.com", fingerprint, "example.com")
+
+		res, err := client.Get("https://example.com")
+		assert.NoError(t, err)
+		res.Body.Close()
+	})
+
+	t.Run("keeps the existing verification", func(t *testing.T) {
+		transport := server.Client().Transport.(*http.Transport).Clone()
+		client := &http.Client{Transport: transport}
+		transport.TLSClientConfig.RootCAs = nil
+		PinCertificate(transport, serverURL.Hostname(), fingerprint)
+
+		_, err := client.Get(server.URL)
+		assert.ErrorContains(t, err, "certificate")
+	})
+}
@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http/httpproxy"
+	"golang.org/x/net/proxy"
+)
+
+// proxySchemes are the schemes of the supported proxies. socks5h is accepted for compatibility with
+// curl, host names are always resolved by SOCKS5 proxies.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// ParseProxyURL parses the URL of an HTTP or SOCKS5 proxy, which may hold credentials
+func ParseProxyURL(proxyRaw string) (*url.URL, error) {
+	proxyURL, err := url.Parse(proxyRaw)
+	if err != nil {
+		return nil, fmt.Errorf("parse proxy url: %w", err)
+	}
+	if !slices.Contains(proxySchemes, proxyURL.Scheme) {
+		return nil, fmt.Errorf("unsupported proxy scheme %q, must be one of %s", proxyURL.Scheme, strings.Join(proxySchemes, ", "))
+	}
+	if proxyURL.Host == "" {
+		return nil, errors.New("proxy host is empty")
+	}
+	return proxyURL, nil
+}
+
+// noProxy returns the NO_PROXY patterns of the environment
+func noProxy() string {
+	if patterns := os.Getenv("NO_PROXY"); patterns != "" {
+		return patterns
+	}
+	return os.Getenv("no_proxy")
+}
+
+// ProxyForURL returns the proxy to reach target through, or nil when target matches the NO_PROXY
+// patterns of the environment. Like http.ProxyFromEnvironment, localhost is never proxied.
+func ProxyForURL(proxyURL *url.URL, target *url.URL) (*url.URL, error) {
+	proxyConfig := httpproxy.Config{
+		HTTPProxy:  proxyURL.String(),
+		HTTPSProxy: proxyURL.String(),
+		NoProxy:    noProxy(),
+	}
+	return proxyConfig.ProxyFunc()(target)
+}
+
+// DialThroughProxy opens a connection to addr, in the format host:port, through an HTTP proxy with
+// CONNECT or through a SOCKS5 proxy
+func DialThroughProxy(ctx context.Context, proxyURL *url.URL, addr string, timeout time.Duration) (net.Conn, error) {
+	d := &net.Dialer{Timeout: timeout}
+
+	if strings.HasPrefix(proxyURL.Scheme, "socks5") {
+		var auth *proxy.Auth
+		if proxyURL.User != nil {
+			password, _ := proxyURL.User.Password()
+			auth = &proxy.Auth{User: proxyURL.User.Username(), Password: password}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, d)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return nil, fmt.Errorf("dial proxy: %w", err)
+		}
+		return conn, nil
+	}
+
+	raw, err := d.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, fmt.Errorf("dial proxy: %w", err)
+	}
+	_ = raw.SetDeadline(time.Now().Add(timeout))
+	if proxyURL.Scheme == "https" {
+		proxyConn := tls.Client(raw, &tls.Config{ServerName: proxyURL.Hostname(), MinVersion: tls.VersionTLS12})
+		if err := proxyConn.HandshakeContext(ctx); err != nil {
+			raw.Close()
+			return nil, fmt.Errorf("tls handshake with proxy: %w", err)
+		}
+		raw = proxyConn
+	}
+
+	req := &http.Request{
+		Method: "CONNECT",
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if proxyURL.User != nil {
+		user := proxyURL.User.Username()
+		pass, _ := proxyURL.User.Password()
+		token := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+		req.Header.Set("Proxy-Authorization", "Basic "+token)
+	}
+	req.Header.Set("Proxy-Connection", "keep-alive")
+
+	if err := req.Write(raw); err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("write CONNECT: %w", err)
+	}
+
+	br := bufio.NewReader(raw)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		raw.Close()
+		return nil, fmt.Errorf("read CONNECT response: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		raw.Close()
+		return nil, fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
+	}
+	_ = raw.SetDeadline(time.Time{})
+	return raw, nil
+}
@@ -0,0 +1,304 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RedactionRule describes what to redact from the logged requests and responses
+type RedactionRule struct {
+	// Path is matched against the URL path. The rule applies to every path when it is nil.
+	Path *regexp.Regexp
+	// Requests and Responses select what the rule applies to
+	Requests  bool
+	Responses bool
+	// Headers are the names of the headers whose values are redacted
+	Headers []string
+	// QueryParams are the names of the query parameters whose values are redacted from the URL of
+	// requests. Patterns also apply to the values of the query parameters.
+	QueryParams []string
+	// Body redacts the whole body when it matches
+	Body *regexp.Regexp
+	// JSONPaths are the dot-separated paths of the values redacted from JSON bodies. * matches any
+	// key or index, for example created_roles.*.api_key.
+	JSONPaths []string
+	// Patterns redact the parts of the bodies they match
+	Patterns []*regexp.Regexp
+}
+
+// BuiltinRedactionRules returns the rules redacting the credentials and secrets that go through the
+// Conjur API
+func BuiltinRedactionRules() []RedactionRule {
+	return []RedactionRule{
+		// Access tokens and credentials are sent in the Authorization headers
+		{Requests: true, Headers: []string{"Authorization", "Proxy-Authorization"}},
+		// OIDC authorization codes, PKCE verifiers and tokens are sent in query parameters
+		{Requests: true, QueryParams: []string{"code", "code_verifier", "nonce", "state", "token", "api_key"}},
+		// Issuers requests with a data key hold the credentials of the issuer
+		{Path: regexp.MustCompile(`/issuers`), Requests: true, Body: regexp.MustCompile(`"data":`)},
+		// Authentication requests hold passwords, API keys and JWTs
+		{Path: regexp.MustCompile(`/authn`), Requests: true, Body: regexp.MustCompile(`.*`)},
+		// Authentication with identity, including its session cookies
+		{
+			Path:      regexp.MustCompile(`/Security/`),
+			Requests:  true,
+			Responses: true,
+			Headers:   []string{"Cookie", setCookieHeader},
+			Body:      regexp.MustCompile(`.*`),
+		},
+		// Access tokens
+		{Responses: true, Body: regexp.MustCompile(`{"protected":".*","payload":".*","signature":".*"}`)},
+		// Secret values, whether they are added or retrieved
+		{Path: regexp.MustCompile(`/secrets(/|$)`), Requests: true, Responses: true, Body: regexp.MustCompile(`.*`)},
+		// API keys returned by login and rotation
+		{Path: regexp.MustCompile(`/authn[^/]*/.+/(login|api_key)$`), Responses: true, Body: regexp.MustCompile(`.*`)},
+		// API keys of the hosts created with a host factory, and host factory tokens
+		{Path: regexp.MustCompile(`/host_factories/hosts`), Responses: true, JSONPaths: []string{"api_key"}},
+		{Path: regexp.MustCompile(`/host_factory_tokens`), Responses: true, JSONPaths: []string{"*.token"}},
+		// API keys of the roles created by loading a policy
+		{Path: regexp.MustCompile(`/policies/`), Responses: true, JSONPaths: []string{"created_roles.*.api_key"}},
+	}
+}
+
+// Redactor redacts requests and responses according to its rules
+type Redactor struct {
+	rules []RedactionRule
+}
+
+// NewRedactor creates a Redactor with the given rules. Without rules nothing is redacted.
+func NewRedactor(rules ...RedactionRule) *Redactor {
+	return &Redactor{rules: rules}
+}
+
+// defaultRedactor returns the redactor to use when none is given
+func defaultRedactor(redactor *Redactor) *Redactor {
+	if redactor == nil {
+		return NewRedactor(BuiltinRedactionRules()...)
+	}
+	return redactor
+}
+
+func (r *Redactor) matchingRules(req *http.Request, response bool) []RedactionRule {
+	path := ""
+	if req != nil && req.URL != nil {
+		path = req.URL.Path
+	}
+
+	rules := []RedactionRule{}
+	for _, rule := range r.rules {
+		if (response && !rule.Responses) || (!response && !rule.Requests) {
+			continue
+		}
+		if rule.Path != nil && !rule.Path.MatchString(path) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// redactRequest redacts a request in place, and returns a function to restore it
+func (r *Redactor) redactRequest(req *http.Request) (restore func()) {
+	rules := r.matchingRules(req, false)
+	restoreQuery := redactQuery(rules, req.URL)
+	restoreMessage := redactMessage(rules, req.Header, &req.Body, &req.ContentLength)
+	return func() {
+		restoreMessage()
+		restoreQuery()
+	}
+}
+
+// redactResponse redacts a response in place, and returns a function to restore it
+func (r *Redactor) redactResponse(res *http.Response) (restore func()) {
+	return redactMessage(r.matchingRules(res.Request, true), res.Header, &res.Body, &res.ContentLength)
+}
+
+func redactMessage(rules []RedactionRule, header http.Header, rc *io.ReadCloser, contentLen *int64) func() {
+	restores := []func(){}
+	for _, rule := range rules {
+		for _, name := range rule.Headers {
+			restores = append(restores, redactHeader(header, name))
+		}
+	}
+	restores = append(restores, redactBody(rules, rc, contentLen))
+
+	return func() {
+		for i := len(restores) - 1; i >= 0; i-- {
+			restores[i]()
+		}
+	}
+}
+
+// redactHeader replaces the values of a header, and returns a function to restore them
+func redactHeader(header http.Header, name string) (restore func()) {
+	values := header.Values(name)
+	if len(values) == 0 {
+		return func() {}
+	}
+
+	header.Set(name, redactedString)
+	return func() {
+		header.Del(name)
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+}
+
+// redactQuery replaces the values of the query parameters of a URL, and returns a function to restore
+// them. The order of the parameters is kept, and the redacted values aren't escaped to stay readable.
+func redactQuery(rules []RedactionRule, u *url.URL) (restore func()) {
+	restore = func() {}
+	if u == nil || u.RawQuery == "" || len(rules) == 0 {
+		return
+	}
+
+	params := strings.Split(u.RawQuery, "&")
+	redacted := false
+	for i, param := range params {
+		rawName, rawValue, _ := strings.Cut(param, "=")
+		name, err := url.QueryUnescape(rawName)
+		if err != nil {
+			name = rawName
+		}
+		value, err := url.QueryUnescape(rawValue)
+		if err != nil {
+			value = rawValue
+		}
+		if redactedValue := redactQueryValue(rules, name, value); redactedValue != value {
+			escaped := strings.ReplaceAll(url.QueryEscape(redactedValue), url.QueryEscape(redactedString), redactedString)
+			params[i] = rawName + "=" + escaped
+			redacted = true
+		}
+	}
+	if !redacted {
+		return
+	}
+
+	rawQuery := u.RawQuery
+	u.RawQuery = strings.Join(params, "&")
+	return func() {
+		u.RawQuery = rawQuery
+	}
+}
+
+func redactQueryValue(rules []RedactionRule, name string, value string) string {
+	for _, rule := range rules {
+		if slices.Contains(rule.QueryParams, name) {
+			return redactedString
+		}
+	}
+	for _, rule := range rules {
+		for _, pattern := range rule.Patterns {
+			value = pattern.ReplaceAllString(value, redactedString)
+		}
+	}
+	return value
+}
+
+// redactBody replaces a body with its redacted copy, and returns a function to restore it
+func redactBody(rules []RedactionRule, rc *io.ReadCloser, contentLen *int64) (restore func()) {
+	restore = func() {}
+	if *rc == nil || *rc == http.NoBody || len(rules) == 0 {
+		return
+	}
+
+	content, err := io.ReadAll(*rc)
+	(*rc).Close()
+	origLength := *contentLen
+	restore = func() {
+		*rc = io.NopCloser(bytes.NewReader(content))
+		*contentLen = origLength
+	}
+	if err != nil || len(content) == 0 {
+		restore()
+		return
+	}
+
+	redacted := redactContent(rules, content)
+	*rc = io.NopCloser(bytes.NewReader(redacted))
+	*contentLen = int64(len(redacted))
+	if bytes.Equal(redacted, content) {
+		*contentLen = origLength
+	}
+	return
+}
+
+func redactContent(rules []RedactionRule, content []byte) []byte {
+	for _, rule := range rules {
+		if rule.Body != nil && rule.Body.Match(content) {
+			return []byte(redactedString)
+		}
+	}
+
+	for _, rule := range rules {
+		if len(rule.JSONPaths) > 0 {
+			content = redactJSONPaths(content, rule.JSONPaths)
+		}
+		for _, pattern := range rule.Patterns {
+			content = pattern.ReplaceAll(content, []byte(redactedString))
+		}
+	}
+	return content
+}
+
+// redactJSONPaths redacts the values at the paths of a JSON document, which is returned as is
+// when it isn't JSON or has nothing to redact
+func redactJSONPaths(content []byte, paths []string) []byte {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return content
+	}
+
+	redacted := false
+	for _, path := range paths {
+		if redactJSONPath(document, strings.Split(path, ".")) {
+			redacted = true
+		}
+	}
+	if !redacted {
+		return content
+	}
+
+	data, err := json.Marshal(document)
+	if err != nil {
+		return content
+	}
+	return data
+}
+
+func redactJSONPath(value interface{}, path []string) bool {
+	redacted := false
+	redactChild := func(key string, child interface{}, set func(interface{})) {
+		if path[0] != "*" && path[0] != key {
+			return
+		}
+		if len(path) == 1 {
+			set(redactedString)
+			redacted = true
+		} else if redactJSONPath(child, path[1:]) {
+			redacted = true
+		}
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			redactChild(key, child, func(redactedValue interface{}) { v[key] = redactedValue })
+		}
+	case []interface{}:
+		for i, child := range v {
+			redactChild(strconv.Itoa(i), child, func(redactedValue interface{}) { v[i] = redactedValue })
+		}
+	}
+	return redacted
+}
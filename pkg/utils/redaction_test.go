@@ -0,0 +1,143 @@
+package utils
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedactor(t *testing.T) {
+	custom := RedactionRule{
+		Requests:  true,
+		Responses: true,
+		Headers:   []string{"X-Custom-Token"},
+		JSONPaths: []string{"data.password"},
+		Patterns:  []*regexp.Regexp{regexp.MustCompile(`ghp_[A-Za-z0-9]+`)},
+	}
+	redactor := NewRedactor(append(BuiltinRedactionRules(), custom)...)
+
+	resTestCases := []struct {
+		description string
+		method      string
+		path        string
+		body        string
+		expected    string
+	}{
+		{
+			description: "secret values are redacted",
+			method:      "GET",
+			path:        "/secrets/dev/variable/db/password",
+			body:        "s3cr3t",
+			expected:    redactedString,
+		},
+		{
+			description: "batch secret values are redacted",
+			method:      "GET",
+			path:        "/secrets",
+			body:        `{"dev:variable:db/password":"s3cr3t"}`,
+			expected:    redactedString,
+		},
+		{
+			description: "API key returned by login is redacted",
+			method:      "GET",
+			path:        "/authn-ldap/corp/dev/login",
+			body:        "3ahcddy39rcxzh3ggac4cwk3j2r8pqwdg33059y835ys2rh2kzs2a",
+			expected:    redactedString,
+		},
+		{
+			description: "rotated API key is redacted",
+			method:      "PUT",
+			path:        "/authn/dev/api_key",
+			body:        "3ahcddy39rcxzh3ggac4cwk3j2r8pqwdg33059y835ys2rh2kzs2a",
+			expected:    redactedString,
+		},
+		{
+			description: "API key of hosts created with a host factory is redacted",
+			method:      "POST",
+			path:        "/host_factories/hosts",
+			body:        `{"id":"dev:host:web-01","api_key":"3ahcddy39rcx"}`,
+			expected:    `{"api_key":"[REDACTED]","id":"dev:host:web-01"}`,
+		},
+		{
+			description: "host factory tokens are redacted",
+			method:      "POST",
+			path:        "/host_factory_tokens",
+			body:        `[{"expiration":"2026-10-18T18:00:00Z","token":"1bcarsc2bqvsxt"}]`,
+			expected:    `[{"expiration":"2026-10-18T18:00:00Z","token":"[REDACTED]"}]`,
+		},
+		{
+			description: "API keys of the roles created by a policy are redacted",
+			method:      "POST",
+			path:        "/policies/dev/policy/root",
+			body:        `{"created_roles":{"dev:user:alice":{"id":"dev:user:alice","api_key":"3ahcddy39rcx"}},"version":2}`,
+			expected:    `{"created_roles":{"dev:user:alice":{"api_key":"[REDACTED]","id":"dev:user:alice"}},"version":2}`,
+		},
+		{
+			description: "custom JSON paths and patterns are redacted",
+			method:      "GET",
+			path:        "/resources/dev",
+			body:        `{"data":{"password":"p4ss","user":"bob"},"note":"ghp_abc123"}`,
+			expected:    `{"data":{"password":"[REDACTED]","user":"bob"},"note":"[REDACTED]"}`,
+		},
+		{
+			description: "other responses are maintained",
+			method:      "GET",
+			path:        "/resources/dev",
+			body:        `[{"id":"dev:variable:db/password"}]`,
+			expected:    `[{"id":"dev:variable:db/password"}]`,
+		},
+	}
+
+	for _, tc := range resTestCases {
+		t.Run(tc.description, func(t *testing.T) {
+			res := &http.Response{
+				Header:  http.Header{"X-Custom-Token": {"abc"}},
+				Body:    io.NopCloser(bytes.NewBufferString(tc.body)),
+				Request: &http.Request{Method: tc.method, URL: &url.URL{Path: tc.path}},
+			}
+
+			restore := redactor.redactResponse(res)
+			body, _ := io.ReadAll(res.Body)
+			assert.Equal(t, tc.expected, string(body))
+			assert.Equal(t, redactedString, res.Header.Get("X-Custom-Token"))
+
+			restore()
+			body, _ = io.ReadAll(res.Body)
+			assert.Equal(t, tc.body, string(body))
+			assert.Equal(t, "abc", res.Header.Get("X-Custom-Token"))
+		})
+	}
+
+	t.Run("secret values are redacted from requests", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "https://conjur/secrets/dev/variable/db/password", bytes.NewBufferString("s3cr3t"))
+		restore := redactor.redactRequest(req)
+		body, _ := io.ReadAll(req.Body)
+		assert.Equal(t, redactedString, string(body))
+		restore()
+		body, _ = io.ReadAll(req.Body)
+		assert.Equal(t, "s3cr3t", string(body))
+	})
+
+	t.Run("credentials are redacted from the query parameters", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "https://conjur/authn-oidc/okta/dev/authenticate?code=abc%2Fdef&nonce=n0nce&code_verifier=v3r1f13r&kind=variable&note=ghp_abc123", nil)
+		restore := redactor.redactRequest(req)
+		assert.Equal(t, "code=[REDACTED]&nonce=[REDACTED]&code_verifier=[REDACTED]&kind=variable&note=[REDACTED]", req.URL.RawQuery)
+		assert.Equal(t, redactedString, req.URL.Query().Get("code"))
+		restore()
+		assert.Equal(t, "abc/def", req.URL.Query().Get("code"))
+		assert.Equal(t, "ghp_abc123", req.URL.Query().Get("note"))
+	})
+
+	t.Run("nothing is redacted without rules", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "https://conjur/authn/dev/alice/authenticate", bytes.NewBufferString("api-key"))
+		req.Header.Set("Authorization", "Token token=\"abc\"")
+		dump := NewDumpTransport(nil, nil, NewRedactor()).dumpRequest(req)
+		assert.Contains(t, string(dump), "api-key")
+		assert.Contains(t, string(dump), "Token token=\"abc\"")
+	})
+}
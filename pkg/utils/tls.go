@@ -1,18 +1,16 @@
 package utils
 
 import (
-	"bufio"
+	"bytes"
 	"context"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"crypto/x509/pkix"
-	"encoding/base64"
 	"encoding/pem"
 	"errors"
 	"fmt"
 	"net"
-	"net/http"
 	"net/url"
 	"strings"
 	"time"
@@ -27,16 +25,80 @@ type ServerCert struct {
 	Cert           string
 	SelfSigned     bool
 	UntrustedCA    bool
+	// Chain holds the certificates presented by the server, the server's certificate first
+	Chain []*x509.Certificate
 }
 
-func isSelfSigned(cert *x509.Certificate) bool {
-	return cert.Subject.CommonName == cert.Issuer.CommonName
+// IsSelfSigned checks that the certificate is signed by its own key, and not just that its issuer
+// has the name of its subject. The certificate doesn't need to be a CA.
+func IsSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) &&
+		cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// CACerts returns the certificate authorities of the presented chain that issued the server's
+// certificate, from its issuer up to the root when the server presents it. The chain stops at the
+// first certificate that didn't issue the previous one.
+func (c ServerCert) CACerts() []*x509.Certificate {
+	cas := []*x509.Certificate{}
+	for i := 1; i < len(c.Chain); i++ {
+		if c.Chain[i-1].CheckSignatureFrom(c.Chain[i]) != nil {
+			break
+		}
+		cas = append(cas, c.Chain[i])
+	}
+	return cas
+}
+
+// TrustBundle returns the PEM bundle that trusts the server through a certificate of its chain:
+// the server's certificate itself for 0, or the CA CACerts()[anchor-1], along with the
+// intermediates below it so that the server is still verified if it stops presenting them.
+func (c ServerCert) TrustBundle(anchor int) (string, error) {
+	if anchor == 0 || len(c.Chain) == 0 {
+		return c.Cert, nil
+	}
+	cas := c.CACerts()
+	if anchor < 0 || anchor > len(cas) {
+		return "", fmt.Errorf("the server presents %d certificate authorities", len(cas))
+	}
+	var bundle []byte
+	for _, ca := range cas[:anchor] {
+		bundle = append(bundle, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw})...)
+	}
+	return string(bundle), nil
+}
+
+// IsTrustedBy checks that the server's certificate is one of certs, or is issued by one of them
+func (c ServerCert) IsTrustedBy(certs []*x509.Certificate, hostname string) bool {
+	roots := x509.NewCertPool()
+	for _, cert := range certs {
+		if getSha256Fingerprint(cert.Raw) == c.Fingerprint {
+			return true
+		}
+		roots.AddCert(cert)
+	}
+	if len(c.Chain) == 0 {
+		return false
+	}
+
+	opts := x509.VerifyOptions{Roots: roots, DNSName: hostname, Intermediates: x509.NewCertPool()}
+	for _, cert := range c.Chain[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := c.Chain[0].Verify(opts)
+	return err == nil
 }
 
 // GetServerCert returns the TLS certificate and fingerprint for a given host.
 // The host should be in the format hostname:port. If the port is not specified,
 // 443 is used.
 func GetServerCert(host string) (ServerCert, error) {
+	return GetServerCertWithClientCert(host, nil)
+}
+
+// GetServerCertWithClientCert is GetServerCert for the servers requiring mutual TLS, which are
+// presented clientCert unless it is nil
+func GetServerCertWithClientCert(host string, clientCert *tls.Certificate) (ServerCert, error) {
 	// Split host into hostname and port
 	hostParts := strings.Split(host, ":")
 	hostname := hostParts[0]
@@ -45,16 +107,25 @@ func GetServerCert(host string) (ServerCert, error) {
 		port = hostParts[1]
 	}
 
-	conn, err := tls.Dial("tcp", hostname+":"+port, &tls.Config{
+	tlsConfig := &tls.Config{
 		InsecureSkipVerify: true,
-	})
+	}
+	if clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*clientCert}
+	}
+	conn, err := tls.Dial("tcp", hostname+":"+port, tlsConfig)
 	if err != nil {
 		return ServerCert{}, err
 	}
 	defer conn.Close()
 
-	// Get the server's certificate
-	peerCerts := conn.ConnectionState().PeerCertificates
+	return newServerCert(hostname, conn.ConnectionState().PeerCertificates)
+}
+
+// newServerCert describes the certificate chain presented by a server. A certificate that is
+// self-signed or issued by a CA the system doesn't trust is flagged, but any other verification
+// failure is an error.
+func newServerCert(hostname string, peerCerts []*x509.Certificate) (ServerCert, error) {
 	if len(peerCerts) == 0 {
 		return ServerCert{}, errors.New("no peer certificates found")
 	}
@@ -63,7 +134,7 @@ func GetServerCert(host string) (ServerCert, error) {
 	if rootCAs == nil {
 		rootCAs = x509.NewCertPool()
 	}
-	selfSigned := isSelfSigned(cert)
+	selfSigned := IsSelfSigned(cert)
 
 	if selfSigned {
 		rootCAs.AddCert(cert)
@@ -81,9 +152,8 @@ func GetServerCert(host string) (ServerCert, error) {
 
 	var untrustedCA bool
 
-	_, err = cert.Verify(opts)
+	_, err := cert.Verify(opts)
 	if err != nil {
-		fmt.Println("Error verifying certificate:", err)
 		// check if error is due to unknowh authority
 		var unknownAuthorityError x509.UnknownAuthorityError
 		if errors.As(err, &unknownAuthorityError) {
@@ -102,7 +172,47 @@ func GetServerCert(host string) (ServerCert, error) {
 
 	creationDate := cert.NotBefore.Format(time.RFC1123)
 	expirationDate := cert.NotAfter.Format(time.RFC1123)
-	return ServerCert{Fingerprint: fingerprint, Issuer: cert.Issuer, CreationDate: creationDate, ExpirationDate: expirationDate, Cert: string(pem), SelfSigned: selfSigned, UntrustedCA: untrustedCA}, nil
+	return ServerCert{
+		Fingerprint:    fingerprint,
+		Issuer:         cert.Issuer,
+		CreationDate:   creationDate,
+		ExpirationDate: expirationDate,
+		Cert:           string(pem),
+		SelfSigned:     selfSigned,
+		UntrustedCA:    untrustedCA,
+		Chain:          peerCerts,
+	}, nil
+}
+
+// ParseCertificates parses the PEM certificates of a certificate file, such as the one written by
+// init, in their order in the file
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no PEM certificates found")
+	}
+	return certs, nil
+}
+
+// CertificateFingerprint returns the SHA-256 fingerprint of a certificate, in the format of
+// ServerCert.Fingerprint
+func CertificateFingerprint(cert *x509.Certificate) string {
+	return getSha256Fingerprint(cert.Raw)
 }
 
 func getSha256Fingerprint(cert []byte) string {
@@ -110,7 +220,8 @@ func getSha256Fingerprint(cert []byte) string {
 	return strings.ToUpper(fmt.Sprintf("%x", sum))
 }
 
-func GetServerCertViaHTTPProxy(ctx context.Context, proxyRaw, host string, timeout time.Duration) (ServerCert, error) {
+// GetServerCertViaProxy is GetServerCertWithClientCert through an HTTP or a SOCKS5 proxy
+func GetServerCertViaProxy(ctx context.Context, proxyRaw, host string, timeout time.Duration, clientCert *tls.Certificate) (ServerCert, error) {
 	hostname, port := splitHostPortDefault(host, "443")
 	targetAddr := net.JoinHostPort(hostname, port)
 
@@ -120,59 +231,26 @@ func GetServerCertViaHTTPProxy(ctx context.Context, proxyRaw, host string, timeo
 	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
-	proxyURL, err := url.Parse(proxyRaw)
+	proxyURL, err := ParseProxyURL(proxyRaw)
 	if err != nil {
-		return ServerCert{}, fmt.Errorf("parse proxy url: %w", err)
-	}
-	if proxyURL.Scheme != "http" {
-		return ServerCert{}, fmt.Errorf("unsupported proxy scheme: %s", proxyURL.Scheme)
-	}
-	if proxyURL.Host == "" {
-		return ServerCert{}, errors.New("proxy host is empty")
+		return ServerCert{}, err
 	}
 
-	d := &net.Dialer{Timeout: timeout}
-	raw, err := d.DialContext(ctx, "tcp", proxyURL.Host)
+	raw, err := DialThroughProxy(ctx, proxyURL, targetAddr, timeout)
 	if err != nil {
-		return ServerCert{}, fmt.Errorf("dial proxy: %w", err)
+		return ServerCert{}, err
 	}
 	defer raw.Close()
 
-	_ = raw.SetDeadline(time.Now().Add(timeout))
-
-	req := &http.Request{
-		Method: "CONNECT",
-		URL:    &url.URL{Opaque: targetAddr},
-		Host:   targetAddr,
-		Header: make(http.Header),
-	}
-	if proxyURL.User != nil {
-		user := proxyURL.User.Username()
-		pass, _ := proxyURL.User.Password()
-		token := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
-		req.Header.Set("Proxy-Authorization", "Basic "+token)
-	}
-	req.Header.Set("Proxy-Connection", "keep-alive")
-
-	if err := req.Write(raw); err != nil {
-		return ServerCert{}, fmt.Errorf("write CONNECT: %w", err)
-	}
-
-	br := bufio.NewReader(raw)
-	resp, err := http.ReadResponse(br, req)
-	if err != nil {
-		return ServerCert{}, fmt.Errorf("read CONNECT response: %w", err)
-	}
-	_ = resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return ServerCert{}, fmt.Errorf("proxy CONNECT failed: %s", resp.Status)
-	}
-
-	tlsConn := tls.Client(raw, &tls.Config{
+	tlsConfig := &tls.Config{
 		ServerName:         hostname, // SNI + hostname verification
 		InsecureSkipVerify: true,     // we will do verification ourselves below
 		MinVersion:         tls.VersionTLS12,
-	})
+	}
+	if clientCert != nil {
+		tlsConfig.Certificates = []tls.Certificate{*clientCert}
+	}
+	tlsConn := tls.Client(raw, tlsConfig)
 
 	_ = tlsConn.SetDeadline(time.Now().Add(timeout))
 	if err := tlsConn.HandshakeContext(ctx); err != nil {
@@ -184,54 +262,17 @@ func GetServerCertViaHTTPProxy(ctx context.Context, proxyRaw, host string, timeo
 	if len(state.PeerCertificates) == 0 {
 		return ServerCert{}, errors.New("no peer certificates found")
 	}
-	leaf := state.PeerCertificates[0]
 
 	// Ensure the cert matches the hostname even though we skipped verify in tls.Config.
-	if err := leaf.VerifyHostname(hostname); err != nil {
+	if err := state.PeerCertificates[0].VerifyHostname(hostname); err != nil {
 		return ServerCert{}, fmt.Errorf("certificate does not match hostname: %w", err)
 	}
 
-	rootCAs, _ := x509.SystemCertPool()
-	if rootCAs == nil {
-		rootCAs = x509.NewCertPool()
-	}
-
-	selfSigned := isSelfSigned(leaf)
-	if selfSigned {
-		rootCAs.AddCert(leaf)
-	}
-
-	opts := x509.VerifyOptions{
-		Roots:         rootCAs,
-		DNSName:       hostname,
-		Intermediates: x509.NewCertPool(),
-	}
-	for _, c := range state.PeerCertificates[1:] {
-		opts.Intermediates.AddCert(c)
-	}
-
-	untrustedCA := false
-	if _, err := leaf.Verify(opts); err != nil {
-		var unknownAuthorityError x509.UnknownAuthorityError
-		if errors.As(err, &unknownAuthorityError) {
-			untrustedCA = true
-		} else {
-			return ServerCert{}, fmt.Errorf("verify certificate: %w", err)
-		}
+	cert, err := newServerCert(hostname, state.PeerCertificates)
+	if err != nil {
+		return ServerCert{}, fmt.Errorf("verify certificate: %w", err)
 	}
-
-	fp := getSha256Fingerprint(leaf.Raw)
-	p := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw})
-
-	return ServerCert{
-		Fingerprint:    fp,
-		Issuer:         leaf.Issuer,
-		CreationDate:   leaf.NotBefore.Format(time.RFC1123),
-		ExpirationDate: leaf.NotAfter.Format(time.RFC1123),
-		Cert:           string(p),
-		SelfSigned:     selfSigned,
-		UntrustedCA:    untrustedCA,
-	}, nil
+	return cert, nil
 }
 
 // splitHostPortDefault returns host and port from an input string.
@@ -14,6 +14,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -58,11 +59,120 @@ func TestGetServerCert(t *testing.T) {
 	})
 }
 
+// newTestCertChain returns the chain of a server certificate for localhost issued by an
+// intermediate CA, itself issued by a root CA, the server's certificate first
+func newTestCertChain(t *testing.T) ([]*x509.Certificate, *ecdsa.PrivateKey) {
+	chain := []*x509.Certificate{}
+	var parent *x509.Certificate
+	var parentKey *ecdsa.PrivateKey
+	for i, name := range []string{"Root CA", "Intermediate CA", "localhost"} {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		assert.NoError(t, err)
+		template := &x509.Certificate{
+			SerialNumber:          big.NewInt(int64(i + 1)),
+			Subject:               pkix.Name{CommonName: name},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			BasicConstraintsValid: true,
+			IsCA:                  name != "localhost",
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		}
+		if name == "localhost" {
+			template.DNSNames = []string{"localhost"}
+			template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+		}
+		if parent == nil {
+			parent, parentKey = template, key
+		}
+		der, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+		assert.NoError(t, err)
+		cert, err := x509.ParseCertificate(der)
+		assert.NoError(t, err)
+		chain = append([]*x509.Certificate{cert}, chain...)
+		parent, parentKey = cert, key
+	}
+	return chain, parentKey
+}
+
+func TestGetServerCertChain(t *testing.T) {
+	chain, key := newTestCertChain(t)
+	server := httptest.NewUnstartedServer(http.NotFoundHandler())
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{{
+		Certificate: [][]byte{chain[0].Raw, chain[1].Raw, chain[2].Raw},
+		PrivateKey:  key,
+	}}}
+	server.StartTLS()
+	defer server.Close()
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+
+	cert, err := GetServerCert("localhost:" + port)
+	assert.NoError(t, err)
+	assert.False(t, cert.SelfSigned)
+	assert.True(t, cert.UntrustedCA)
+	assert.Len(t, cert.Chain, 3)
+	assert.Equal(t, chain[1:], cert.CACerts())
+
+	// The bundle of a CA holds the intermediates below it
+	for anchor, expected := range map[int][]*x509.Certificate{0: chain[:1], 1: chain[1:2], 2: chain[1:]} {
+		bundle, err := cert.TrustBundle(anchor)
+		assert.NoError(t, err)
+		certs, err := ParseCertificates([]byte(bundle))
+		assert.NoError(t, err)
+		assert.Equal(t, expected, certs)
+		assert.True(t, cert.IsTrustedBy(certs, "localhost"))
+	}
+	_, err = cert.TrustBundle(3)
+	assert.Error(t, err)
+
+	assert.False(t, cert.IsTrustedBy(chain[2:], "conjur.example.com"))
+	other, _ := newTestCertChain(t)
+	assert.False(t, cert.IsTrustedBy(other, "localhost"))
+}
+
+func TestIsSelfSigned(t *testing.T) {
+	chain, _ := newTestCertChain(t)
+	assert.True(t, IsSelfSigned(chain[2]))
+	assert.False(t, IsSelfSigned(chain[1]))
+
+	// A certificate whose issuer has its name isn't self-signed unless it signed itself
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, otherKey)
+	assert.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	assert.False(t, IsSelfSigned(cert))
+}
+
+func TestParseCertificates(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: []byte("key")})
+
+	certs, err := ParseCertificates(append(append(certPEM, keyPEM...), certPEM...))
+	assert.NoError(t, err)
+	assert.Len(t, certs, 2)
+	assert.Equal(t, getSha256Fingerprint(server.Certificate().Raw), CertificateFingerprint(certs[0]))
+
+	_, err = ParseCertificates(keyPEM)
+	assert.EqualError(t, err, "no PEM certificates found")
+}
+
 func startSelfSignedServer(t *testing.T, port int) *httptest.Server {
-	err := generateSelfSignedCert()
+	certDir := t.TempDir()
+	err := generateSelfSignedCert(certDir)
 	assert.NoError(t, err, "failed to generate self-signed certificate")
 	// Load your custom cert and key
-	cert, err := tls.LoadX509KeyPair("localhost.crt", "localhost.key")
+	cert, err := tls.LoadX509KeyPair(filepath.Join(certDir, "localhost.crt"), filepath.Join(certDir, "localhost.key"))
 	assert.NoError(t, err, "failed to load cert/key")
 
 	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -82,7 +192,9 @@ func startSelfSignedServer(t *testing.T, port int) *httptest.Server {
 	return server
 }
 
-func generateSelfSignedCert() error {
+// generateSelfSignedCert writes a self-signed certificate for localhost and its key to
+// localhost.crt and localhost.key in dir
+func generateSelfSignedCert(dir string) error {
 	// Generate a private key
 	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 	if err != nil {
@@ -113,7 +225,7 @@ func generateSelfSignedCert() error {
 	}
 
 	// Save the certificate to a file
-	certFile, err := os.Create("localhost.crt")
+	certFile, err := os.Create(filepath.Join(dir, "localhost.crt"))
 	if err != nil {
 		return err
 	}
@@ -125,7 +237,7 @@ func generateSelfSignedCert() error {
 	}
 
 	// Save the private key to a file
-	keyFile, err := os.Create("localhost.key")
+	keyFile, err := os.Create(filepath.Join(dir, "localhost.key"))
 	if err != nil {
 		return err
 	}
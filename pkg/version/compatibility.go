@@ -0,0 +1,54 @@
+package version
+
+import (
+	semver "github.com/Masterminds/semver/v3"
+)
+
+// incompatibility is a known incompatibility between versions of the CLI and of the server. The
+// versions are semver constraints, and the server version is the Conjur OSS version, which in
+// Secrets Manager Self-Hosted is the version of the 'possum' service.
+type incompatibility struct {
+	cliVersions    string
+	serverVersions string
+	reason         string
+}
+
+var incompatibilities = []incompatibility{
+	{
+		cliVersions:    ">= 8.0.17",
+		serverVersions: "< 1.21.1",
+		reason:         "policy dry runs and 'policy fetch' require server version 1.21.1 or later",
+	},
+}
+
+// ServerIncompatibilities returns the reasons why the CLI version is known to be incompatible with
+// the server version. Nothing is returned when either version can't be parsed, as in development
+// builds.
+func ServerIncompatibilities(cliVersion string, serverVersion string) []string {
+	cli, err := semver.NewVersion(cliVersion)
+	if err != nil {
+		return nil
+	}
+	server, err := semver.NewVersion(serverVersion)
+	if err != nil {
+		return nil
+	}
+	// Server version suffixes (eg. 1.21.1-359) are build numbers rather than pre-releases
+	serverRelease, _ := server.SetPrerelease("")
+
+	var reasons []string
+	for _, known := range incompatibilities {
+		cliConstraint, err := semver.NewConstraint(known.cliVersions)
+		if err != nil {
+			continue
+		}
+		serverConstraint, err := semver.NewConstraint(known.serverVersions)
+		if err != nil {
+			continue
+		}
+		if cliConstraint.Check(cli) && serverConstraint.Check(&serverRelease) {
+			reasons = append(reasons, known.reason)
+		}
+	}
+	return reasons
+}